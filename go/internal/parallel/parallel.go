@@ -0,0 +1,48 @@
+// Package parallel splits loops across goroutines for the CPU kernels.
+package parallel
+
+import (
+	"runtime"
+	"sync"
+)
+
+// minWork is the amount of work, in iterations times cost, below which a
+// loop is not worth splitting.
+const minWork = 1 << 15
+
+// For calls fn on disjoint ranges [start, end) that together cover [0, n),
+// running them on up to GOMAXPROCS goroutines and returning once all have
+// finished. cost estimates the work of a single iteration; loops with
+// little total work run on the calling goroutine. The ranges depend only
+// on n, cost and GOMAXPROCS, never on scheduling.
+func For(n, cost int, fn func(start, end int)) {
+	if n <= 0 {
+		return
+	}
+	workers := runtime.GOMAXPROCS(0)
+	if cost < 1 {
+		cost = 1
+	}
+	if w := n * cost / minWork; w < workers {
+		workers = w
+	}
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		fn(0, n)
+		return
+	}
+
+	var wg sync.WaitGroup
+	chunk := (n + workers - 1) / workers
+	for start := 0; start < n; start += chunk {
+		end := min(start+chunk, n)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(start, end)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,238 @@
+package nn
+
+import (
+	"fmt"
+
+	"gotorch/nn/functional"
+	"gotorch/tensors"
+)
+
+// ReLU applies max(x, 0) elementwise.
+type ReLU struct{ Base }
+
+// NewReLU returns a ReLU layer.
+func NewReLU() *ReLU { return &ReLU{} }
+
+func (m *ReLU) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "ReLU")
+	if err != nil {
+		return nil, err
+	}
+	return functional.ReLU(input)
+}
+
+// LeakyReLU applies x for positive x and NegativeSlope*x otherwise.
+type LeakyReLU struct {
+	Base
+	NegativeSlope float64
+}
+
+// NewLeakyReLU returns a LeakyReLU layer. PyTorch defaults negativeSlope
+// to 0.01.
+func NewLeakyReLU(negativeSlope float64) *LeakyReLU {
+	return &LeakyReLU{NegativeSlope: negativeSlope}
+}
+
+func (m *LeakyReLU) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "LeakyReLU")
+	if err != nil {
+		return nil, err
+	}
+	return functional.LeakyReLU(input, m.NegativeSlope)
+}
+
+// PReLU is a leaky ReLU whose negative slope is learned, either shared or
+// one per channel along dimension 1 of the input.
+type PReLU struct {
+	Base
+	NumParameters int
+	Weight        *Parameter // (num_parameters)
+}
+
+// NewPReLU returns a PReLU layer with numParameters slopes, 1 or the number
+// of input channels, starting at init. PyTorch defaults to a single slope
+// of 0.25.
+func NewPReLU(numParameters int, init float64) (*PReLU, error) {
+	if numParameters <= 0 {
+		return nil, fmt.Errorf("PReLU requires a positive number of parameters, got %d", numParameters)
+	}
+	w, err := full([]int{numParameters}, init)
+	if err != nil {
+		return nil, err
+	}
+	m := &PReLU{NumParameters: numParameters, Weight: NewParameter(w)}
+	return m, Register(m)
+}
+
+func (m *PReLU) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "PReLU")
+	if err != nil {
+		return nil, err
+	}
+	return functional.PReLU(input, m.Weight.Tensor)
+}
+
+// ELU applies x for positive x and Alpha*(exp(x)-1) otherwise.
+type ELU struct {
+	Base
+	Alpha float64
+}
+
+// NewELU returns an ELU layer. PyTorch defaults alpha to 1.
+func NewELU(alpha float64) *ELU { return &ELU{Alpha: alpha} }
+
+func (m *ELU) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "ELU")
+	if err != nil {
+		return nil, err
+	}
+	return functional.ELU(input, m.Alpha)
+}
+
+// SELU applies the self-normalizing scaled ELU.
+type SELU struct{ Base }
+
+// NewSELU returns a SELU layer.
+func NewSELU() *SELU { return &SELU{} }
+
+func (m *SELU) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "SELU")
+	if err != nil {
+		return nil, err
+	}
+	return functional.SELU(input)
+}
+
+// GELU applies x*Phi(x), exactly or with the tanh approximation.
+type GELU struct {
+	Base
+	Approximate string // "none" or "tanh"
+}
+
+// NewGELU returns a GELU layer. approximate is "none", PyTorch's default,
+// or "tanh".
+func NewGELU(approximate string) *GELU { return &GELU{Approximate: approximate} }
+
+func (m *GELU) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "GELU")
+	if err != nil {
+		return nil, err
+	}
+	return functional.GELU(input, m.Approximate)
+}
+
+// SiLU applies x*sigmoid(x).
+type SiLU struct{ Base }
+
+// NewSiLU returns a SiLU layer.
+func NewSiLU() *SiLU { return &SiLU{} }
+
+func (m *SiLU) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "SiLU")
+	if err != nil {
+		return nil, err
+	}
+	return functional.SiLU(input)
+}
+
+// Mish applies x*tanh(softplus(x)).
+type Mish struct{ Base }
+
+// NewMish returns a Mish layer.
+func NewMish() *Mish { return &Mish{} }
+
+func (m *Mish) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "Mish")
+	if err != nil {
+		return nil, err
+	}
+	return functional.Mish(input)
+}
+
+// Softplus applies log(1+exp(Beta*x))/Beta, reverting to x above
+// Threshold for numerical stability.
+type Softplus struct {
+	Base
+	Beta      float64
+	Threshold float64
+}
+
+// NewSoftplus returns a Softplus layer. PyTorch defaults beta to 1 and
+// threshold to 20.
+func NewSoftplus(beta, threshold float64) *Softplus {
+	return &Softplus{Beta: beta, Threshold: threshold}
+}
+
+func (m *Softplus) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "Softplus")
+	if err != nil {
+		return nil, err
+	}
+	return functional.Softplus(input, m.Beta, m.Threshold)
+}
+
+// Hardswish applies x*min(max(x+3, 0), 6)/6.
+type Hardswish struct{ Base }
+
+// NewHardswish returns a Hardswish layer.
+func NewHardswish() *Hardswish { return &Hardswish{} }
+
+func (m *Hardswish) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "Hardswish")
+	if err != nil {
+		return nil, err
+	}
+	return functional.Hardswish(input)
+}
+
+// Softmax rescales its input along Dim to positive values summing to 1.
+type Softmax struct {
+	Base
+	Dim int
+}
+
+// NewSoftmax returns a Softmax layer over dim, which may be negative to
+// count from the end.
+func NewSoftmax(dim int) *Softmax { return &Softmax{Dim: dim} }
+
+func (m *Softmax) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "Softmax")
+	if err != nil {
+		return nil, err
+	}
+	return functional.Softmax(input, m.Dim)
+}
+
+// LogSoftmax applies the logarithm of Softmax along Dim.
+type LogSoftmax struct {
+	Base
+	Dim int
+}
+
+// NewLogSoftmax returns a LogSoftmax layer over dim.
+func NewLogSoftmax(dim int) *LogSoftmax { return &LogSoftmax{Dim: dim} }
+
+func (m *LogSoftmax) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "LogSoftmax")
+	if err != nil {
+		return nil, err
+	}
+	return functional.LogSoftmax(input, m.Dim)
+}
+
+// Softmin applies Softmax to the negated input along Dim.
+type Softmin struct {
+	Base
+	Dim int
+}
+
+// NewSoftmin returns a Softmin layer over dim.
+func NewSoftmin(dim int) *Softmin { return &Softmin{Dim: dim} }
+
+func (m *Softmin) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "Softmin")
+	if err != nil {
+		return nil, err
+	}
+	return functional.Softmin(input, m.Dim)
+}
@@ -0,0 +1,297 @@
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gotorch/nn/functional"
+	"gotorch/tensors"
+)
+
+// AttentionMasks holds the optional masks of an attention call.
+type AttentionMasks struct {
+	// AttnMask is added to the attention scores. It has shape (l, s) or
+	// (batch*num_heads, l, s); use -Inf to mask a position.
+	AttnMask *tensors.Tensor
+	// KeyPaddingMask marks padded keys. It has shape (batch, s), or (s)
+	// for unbatched input. Float values are added to the scores, and
+	// non-zero int64 entries mask the key.
+	KeyPaddingMask *tensors.Tensor
+	// IsCausal masks key j for query i whenever j > i.
+	IsCausal bool
+}
+
+// MultiheadAttention projects its query, key and value into NumHeads heads,
+// runs scaled dot-product attention on each and projects the concatenated
+// result back to EmbedDim features.
+type MultiheadAttention struct {
+	Base
+	EmbedDim   int
+	NumHeads   int
+	KDim       int
+	VDim       int
+	Dropout    float64
+	BatchFirst bool
+	Generator  *tensors.Generator // source of the dropout masks; nil uses the default generator
+
+	InProjWeight *Parameter // (3*embed_dim, embed_dim), nil when kdim or vdim differ from embed_dim
+	QProjWeight  *Parameter // (embed_dim, embed_dim), nil unless kdim or vdim differ from embed_dim
+	KProjWeight  *Parameter // (embed_dim, kdim), nil unless kdim or vdim differ from embed_dim
+	VProjWeight  *Parameter // (embed_dim, vdim), nil unless kdim or vdim differ from embed_dim
+	InProjBias   *Parameter // (3*embed_dim), nil without bias
+	OutProj      *Linear
+}
+
+// NewMultiheadAttention returns a MultiheadAttention layer. embedDim must
+// be divisible by numHeads, and kdim and vdim default to embedDim when 0.
+// Like PyTorch, the input projections are Xavier uniform and the biases
+// start at zero. Dropout applies to the attention weights in training mode.
+func NewMultiheadAttention(embedDim, numHeads int, dropout float64, bias, batchFirst bool, kdim, vdim int) (*MultiheadAttention, error) {
+	if kdim == 0 {
+		kdim = embedDim
+	}
+	if vdim == 0 {
+		vdim = embedDim
+	}
+	if embedDim <= 0 || numHeads <= 0 || embedDim%numHeads != 0 || kdim <= 0 || vdim <= 0 {
+		return nil, fmt.Errorf("MultiheadAttention: embed_dim %d must be positive and divisible by %d heads", embedDim, numHeads)
+	}
+	if !(dropout >= 0 && dropout < 1) {
+		return nil, fmt.Errorf("MultiheadAttention dropout has to be in [0, 1), got %v", dropout)
+	}
+	m := &MultiheadAttention{EmbedDim: embedDim, NumHeads: numHeads, KDim: kdim, VDim: vdim, Dropout: dropout, BatchFirst: batchFirst}
+	var err error
+	if kdim == embedDim && vdim == embedDim {
+		if m.InProjWeight, err = xavierUniform([]int{3 * embedDim, embedDim}); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, p := range []struct {
+			dst *(*Parameter)
+			in  int
+		}{{&m.QProjWeight, embedDim}, {&m.KProjWeight, kdim}, {&m.VProjWeight, vdim}} {
+			if *p.dst, err = xavierUniform([]int{embedDim, p.in}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if m.OutProj, err = NewLinear(embedDim, embedDim, bias); err != nil {
+		return nil, err
+	}
+	if bias {
+		b, err := full([]int{3 * embedDim}, 0)
+		if err != nil {
+			return nil, err
+		}
+		m.InProjBias = NewParameter(b)
+		tensors.NoGrad(func() { err = m.OutProj.Bias.Zero() })
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, Register(m)
+}
+
+// Forward takes a query, key and value, or a single input for
+// self-attention, and attends without masks.
+func (m *MultiheadAttention) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	switch len(inputs) {
+	case 1:
+		return m.ForwardWithMasks(inputs[0], inputs[0], inputs[0], AttentionMasks{})
+	case 3:
+		return m.ForwardWithMasks(inputs[0], inputs[1], inputs[2], AttentionMasks{})
+	}
+	return nil, errors.New("MultiheadAttention takes one input or a query, key and value")
+}
+
+// ForwardWithMasks attends from a (l, batch, embed_dim) query to a
+// (s, batch, kdim) key and (s, batch, vdim) value, or the batch-first
+// forms with BatchFirst, or unbatched (l, embed_dim), (s, kdim) and
+// (s, vdim) inputs. The output has the layout of the query.
+func (m *MultiheadAttention) ForwardWithMasks(query, key, value *tensors.Tensor, masks AttentionMasks) (*tensors.Tensor, error) {
+	batched := len(query.Shape) == 3
+	if !batched && len(query.Shape) != 2 {
+		return nil, fmt.Errorf("MultiheadAttention expects a 2-d or 3-d query, got shape %v", query.Shape)
+	}
+	if len(key.Shape) != len(query.Shape) || len(value.Shape) != len(query.Shape) {
+		return nil, errors.New("MultiheadAttention expects query, key and value of equal rank")
+	}
+
+	// Bring every input to (batch, seq, features).
+	toBatchFirst := func(t *tensors.Tensor) (*tensors.Tensor, error) {
+		if !batched {
+			return tensors.Reshape(t, append([]int{1}, t.Shape...))
+		}
+		if !m.BatchFirst {
+			return tensors.Permute(t, []int{1, 0, 2})
+		}
+		return t, nil
+	}
+	q, err := toBatchFirst(query)
+	if err != nil {
+		return nil, err
+	}
+	k, err := toBatchFirst(key)
+	if err != nil {
+		return nil, err
+	}
+	v, err := toBatchFirst(value)
+	if err != nil {
+		return nil, err
+	}
+	n, l, s := q.Shape[0], q.Shape[1], k.Shape[1]
+	if k.Shape[0] != n || v.Shape[0] != n || v.Shape[1] != s {
+		return nil, fmt.Errorf("MultiheadAttention: query %v, key %v and value %v do not match", query.Shape, key.Shape, value.Shape)
+	}
+
+	heads, headDim := m.NumHeads, m.EmbedDim/m.NumHeads
+	project := func(x *tensors.Tensor, i, length int) (*tensors.Tensor, error) {
+		weight, bias, err := m.inProjection(i)
+		if err != nil {
+			return nil, err
+		}
+		y, err := functional.Linear(x, weight, bias)
+		if err != nil {
+			return nil, err
+		}
+		if y, err = tensors.Reshape(y, []int{n, length, heads, headDim}); err != nil {
+			return nil, err
+		}
+		return tensors.Permute(y, []int{0, 2, 1, 3})
+	}
+	if q, err = project(q, 0, l); err != nil {
+		return nil, err
+	}
+	if k, err = project(k, 1, s); err != nil {
+		return nil, err
+	}
+	if v, err = project(v, 2, s); err != nil {
+		return nil, err
+	}
+
+	mask, err := m.attentionMask(masks, n, l, s, batched, query.Dtype)
+	if err != nil {
+		return nil, err
+	}
+	opts := functional.AttentionOptions{IsCausal: masks.IsCausal, Generator: m.Generator}
+	if m.IsTraining() {
+		opts.DropoutP = m.Dropout
+	}
+	out, err := functional.ScaledDotProductAttention(q, k, v, mask, opts)
+	if err != nil {
+		return nil, err
+	}
+	if out, err = tensors.Permute(out, []int{0, 2, 1, 3}); err != nil {
+		return nil, err
+	}
+	if out, err = tensors.Reshape(out, []int{n, l, m.EmbedDim}); err != nil {
+		return nil, err
+	}
+	if out, err = Call(m.OutProj, out); err != nil {
+		return nil, err
+	}
+	if !batched {
+		return tensors.Reshape(out, []int{l, m.EmbedDim})
+	}
+	if !m.BatchFirst {
+		return tensors.Permute(out, []int{1, 0, 2})
+	}
+	return out, nil
+}
+
+// inProjection returns the weight and bias projecting the query (i = 0),
+// key (1) or value (2).
+func (m *MultiheadAttention) inProjection(i int) (*tensors.Tensor, *tensors.Tensor, error) {
+	var weight, bias *tensors.Tensor
+	var err error
+	if m.InProjWeight != nil {
+		if weight, err = tensors.Narrow(m.InProjWeight.Tensor, 0, i*m.EmbedDim, m.EmbedDim); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		weight = []*Parameter{m.QProjWeight, m.KProjWeight, m.VProjWeight}[i].Tensor
+	}
+	if m.InProjBias != nil {
+		if bias, err = tensors.Narrow(m.InProjBias.Tensor, 0, i*m.EmbedDim, m.EmbedDim); err != nil {
+			return nil, nil, err
+		}
+	}
+	return weight, bias, nil
+}
+
+// attentionMask merges the attention and key padding masks into one
+// additive mask that broadcasts to (batch, heads, l, s), or returns nil
+// without masks.
+func (m *MultiheadAttention) attentionMask(masks AttentionMasks, n, l, s int, batched bool, dtype tensors.Dtype) (*tensors.Tensor, error) {
+	var mask *tensors.Tensor
+	if a := masks.AttnMask; a != nil {
+		switch {
+		case equalShapes(a.Shape, []int{l, s}):
+			mask = a.Detach()
+		case len(a.Shape) == 3 && equalShapes(a.Shape, []int{n * m.NumHeads, l, s}):
+			var err error
+			if mask, err = tensors.Reshape(a.Detach(), []int{n, m.NumHeads, l, s}); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("MultiheadAttention expects an attention mask of shape [%d %d] or [%d %d %d], got %v", l, s, n*m.NumHeads, l, s, a.Shape)
+		}
+		if mask.Dtype != dtype {
+			return nil, errors.New("MultiheadAttention expects an attention mask of the query data type")
+		}
+	}
+	if p := masks.KeyPaddingMask; p != nil {
+		want := []int{n, s}
+		if !batched {
+			want = []int{s}
+		}
+		if !equalShapes(p.Shape, want) {
+			return nil, fmt.Errorf("MultiheadAttention expects a key padding mask of shape %v, got %v", want, p.Shape)
+		}
+		padding, err := additiveMask(p, dtype)
+		if err != nil {
+			return nil, err
+		}
+		if padding, err = tensors.Reshape(padding, []int{n, 1, 1, s}); err != nil {
+			return nil, err
+		}
+		if mask == nil {
+			return padding, nil
+		}
+		var sum *tensors.Tensor
+		tensors.NoGrad(func() { sum, err = tensors.Add(mask, padding) })
+		return sum, err
+	}
+	return mask, nil
+}
+
+// additiveMask turns an int64 mask into zeros and -Inf where non-zero, and
+// detaches a float mask.
+func additiveMask(t *tensors.Tensor, dtype tensors.Dtype) (*tensors.Tensor, error) {
+	flags, ok := t.Data.([]int64)
+	if !ok {
+		if t.Dtype != dtype {
+			return nil, errors.New("float masks must have the query data type")
+		}
+		return t.Detach(), nil
+	}
+	values := make([]float64, len(flags))
+	for i, f := range flags {
+		if f != 0 {
+			values[i] = math.Inf(-1)
+		}
+	}
+	return fromFloat64(values, t.Shape, dtype)
+}
+
+// xavierUniform returns a parameter drawn from the Xavier uniform
+// distribution for a 2-d (fan_out, fan_in) weight.
+func xavierUniform(shape []int) (*Parameter, error) {
+	bound := math.Sqrt(6 / float64(shape[0]+shape[1]))
+	t, err := uniform(shape, bound)
+	if err != nil {
+		return nil, err
+	}
+	return NewParameter(t), nil
+}
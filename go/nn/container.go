@@ -0,0 +1,252 @@
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"gotorch/tensors"
+)
+
+// Must returns m, panicking if err is non-nil. It lets constructors that
+// return an error be used inline when building containers:
+//
+//	model := nn.NewSequential(
+//		nn.Must(newBlock(64, 128)),
+//		nn.Must(newBlock(128, 10)),
+//	)
+func Must[M Module](m M, err error) M {
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Sequential chains modules, feeding the output of each one to the next.
+// Its children are named by position.
+type Sequential struct {
+	Base
+	modules []Module
+}
+
+// NewSequential returns a Sequential running modules in order.
+func NewSequential(modules ...Module) *Sequential {
+	s := &Sequential{}
+	for _, m := range modules {
+		s.Append(m)
+	}
+	return s
+}
+
+// Append adds m to the end of the chain.
+func (s *Sequential) Append(m Module) {
+	s.RegisterModule(strconv.Itoa(len(s.modules)), m)
+	s.modules = append(s.modules, m)
+}
+
+// Get returns the i-th module.
+func (s *Sequential) Get(i int) Module {
+	return s.modules[i]
+}
+
+// Len returns the number of modules.
+func (s *Sequential) Len() int {
+	return len(s.modules)
+}
+
+func (s *Sequential) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(s.modules) == 0 {
+		if len(inputs) != 1 {
+			return nil, errors.New("an empty Sequential takes exactly one input")
+		}
+		return inputs[0], nil
+	}
+	for i, m := range s.modules {
+		out, err := Call(m, inputs...)
+		if err != nil {
+			return nil, fmt.Errorf("module %d: %w", i, err)
+		}
+		inputs = []*tensors.Tensor{out}
+	}
+	return inputs[0], nil
+}
+
+// ModuleList holds modules in a list. It registers them as children named
+// by position but has no Forward of its own.
+type ModuleList struct {
+	Base
+	modules []Module
+}
+
+// NewModuleList returns a ModuleList holding modules.
+func NewModuleList(modules ...Module) *ModuleList {
+	l := &ModuleList{}
+	for _, m := range modules {
+		l.Append(m)
+	}
+	return l
+}
+
+// Append adds m to the end of the list.
+func (l *ModuleList) Append(m Module) {
+	l.RegisterModule(strconv.Itoa(len(l.modules)), m)
+	l.modules = append(l.modules, m)
+}
+
+// Get returns the i-th module.
+func (l *ModuleList) Get(i int) Module {
+	return l.modules[i]
+}
+
+// Len returns the number of modules.
+func (l *ModuleList) Len() int {
+	return len(l.modules)
+}
+
+func (l *ModuleList) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	return nil, errors.New("ModuleList has no Forward; call its modules directly")
+}
+
+// ModuleDict holds modules by name, in insertion order.
+type ModuleDict struct {
+	Base
+	keys    []string
+	modules map[string]Module
+}
+
+// NewModuleDict returns a ModuleDict holding modules, inserted in sorted
+// key order.
+func NewModuleDict(modules map[string]Module) *ModuleDict {
+	d := &ModuleDict{modules: make(map[string]Module)}
+	keys := make([]string, 0, len(modules))
+	for k := range modules {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		d.Set(k, modules[k])
+	}
+	return d
+}
+
+// Set adds or replaces the module stored under key.
+func (d *ModuleDict) Set(key string, m Module) {
+	if d.modules == nil {
+		d.modules = make(map[string]Module)
+	}
+	d.RegisterModule(key, m)
+	if _, ok := d.modules[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.modules[key] = m
+}
+
+// Get returns the module stored under key.
+func (d *ModuleDict) Get(key string) (Module, bool) {
+	m, ok := d.modules[key]
+	return m, ok
+}
+
+// Keys returns the keys in insertion order.
+func (d *ModuleDict) Keys() []string {
+	return append([]string{}, d.keys...)
+}
+
+// Len returns the number of modules.
+func (d *ModuleDict) Len() int {
+	return len(d.keys)
+}
+
+func (d *ModuleDict) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	return nil, errors.New("ModuleDict has no Forward; call its modules directly")
+}
+
+// ParameterList holds parameters in a list, named by position.
+type ParameterList struct {
+	Base
+	params []*Parameter
+}
+
+// NewParameterList returns a ParameterList holding params.
+func NewParameterList(params ...*Parameter) *ParameterList {
+	l := &ParameterList{}
+	for _, p := range params {
+		l.Append(p)
+	}
+	return l
+}
+
+// Append adds p to the end of the list.
+func (l *ParameterList) Append(p *Parameter) {
+	l.RegisterParameter(strconv.Itoa(len(l.params)), p)
+	l.params = append(l.params, p)
+}
+
+// Get returns the i-th parameter.
+func (l *ParameterList) Get(i int) *Parameter {
+	return l.params[i]
+}
+
+// Len returns the number of parameters.
+func (l *ParameterList) Len() int {
+	return len(l.params)
+}
+
+func (l *ParameterList) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	return nil, errors.New("ParameterList has no Forward")
+}
+
+// ParameterDict holds parameters by name, in insertion order.
+type ParameterDict struct {
+	Base
+	keys   []string
+	params map[string]*Parameter
+}
+
+// NewParameterDict returns a ParameterDict holding params, inserted in
+// sorted key order.
+func NewParameterDict(params map[string]*Parameter) *ParameterDict {
+	d := &ParameterDict{params: make(map[string]*Parameter)}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		d.Set(k, params[k])
+	}
+	return d
+}
+
+// Set adds or replaces the parameter stored under key.
+func (d *ParameterDict) Set(key string, p *Parameter) {
+	if d.params == nil {
+		d.params = make(map[string]*Parameter)
+	}
+	d.RegisterParameter(key, p)
+	if _, ok := d.params[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.params[key] = p
+}
+
+// Get returns the parameter stored under key.
+func (d *ParameterDict) Get(key string) (*Parameter, bool) {
+	p, ok := d.params[key]
+	return p, ok
+}
+
+// Keys returns the keys in insertion order.
+func (d *ParameterDict) Keys() []string {
+	return append([]string{}, d.keys...)
+}
+
+// Len returns the number of parameters.
+func (d *ParameterDict) Len() int {
+	return len(d.keys)
+}
+
+func (d *ParameterDict) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	return nil, errors.New("ParameterDict has no Forward")
+}
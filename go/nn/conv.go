@@ -0,0 +1,163 @@
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gotorch/nn/functional"
+	"gotorch/tensors"
+)
+
+type convFunc func(input, weight, bias *tensors.Tensor, opts functional.ConvOptions) (*tensors.Tensor, error)
+
+// ConvNd holds the state shared by the convolution layers.
+type ConvNd struct {
+	Base
+	InChannels  int
+	OutChannels int
+	KernelSize  []int
+	Options     functional.ConvOptions
+	Transposed  bool
+	Weight      *Parameter // (out_channels, in_channels/groups, kernel...), or (in_channels, out_channels/groups, kernel...) when transposed
+	Bias        *Parameter // (out_channels), nil when created without bias
+
+	name    string
+	forward convFunc
+}
+
+// Conv1d applies a 1-d convolution over an (n, c, l) or (c, l) input.
+type Conv1d struct{ ConvNd }
+
+// Conv2d applies a 2-d convolution over an (n, c, h, w) or (c, h, w) input.
+type Conv2d struct{ ConvNd }
+
+// Conv3d applies a 3-d convolution over an (n, c, d, h, w) or (c, d, h, w)
+// input.
+type Conv3d struct{ ConvNd }
+
+// ConvTranspose1d applies a transposed 1-d convolution, the gradient of
+// Conv1d with respect to its input.
+type ConvTranspose1d struct{ ConvNd }
+
+// ConvTranspose2d applies a transposed 2-d convolution, the gradient of
+// Conv2d with respect to its input.
+type ConvTranspose2d struct{ ConvNd }
+
+// ConvTranspose3d applies a transposed 3-d convolution, the gradient of
+// Conv3d with respect to its input.
+type ConvTranspose3d struct{ ConvNd }
+
+// NewConv1d returns a Conv1d layer. kernelSize holds one value or one per
+// spatial dimension, as do the stride, padding and dilation in opts.
+func NewConv1d(in, out int, kernelSize []int, bias bool, opts functional.ConvOptions) (*Conv1d, error) {
+	m := &Conv1d{}
+	return m, m.init(in, out, kernelSize, bias, opts, "Conv1d", 1, false, functional.Conv1d)
+}
+
+// NewConv2d returns a Conv2d layer. kernelSize holds one value or one per
+// spatial dimension, as do the stride, padding and dilation in opts.
+func NewConv2d(in, out int, kernelSize []int, bias bool, opts functional.ConvOptions) (*Conv2d, error) {
+	m := &Conv2d{}
+	return m, m.init(in, out, kernelSize, bias, opts, "Conv2d", 2, false, functional.Conv2d)
+}
+
+// NewConv3d returns a Conv3d layer. kernelSize holds one value or one per
+// spatial dimension, as do the stride, padding and dilation in opts.
+func NewConv3d(in, out int, kernelSize []int, bias bool, opts functional.ConvOptions) (*Conv3d, error) {
+	m := &Conv3d{}
+	return m, m.init(in, out, kernelSize, bias, opts, "Conv3d", 3, false, functional.Conv3d)
+}
+
+// NewConvTranspose1d returns a ConvTranspose1d layer. The options are those
+// of Conv1d plus OutputPadding, which must be smaller than the stride or
+// the dilation.
+func NewConvTranspose1d(in, out int, kernelSize []int, bias bool, opts functional.ConvOptions) (*ConvTranspose1d, error) {
+	m := &ConvTranspose1d{}
+	return m, m.init(in, out, kernelSize, bias, opts, "ConvTranspose1d", 1, true, functional.ConvTranspose1d)
+}
+
+// NewConvTranspose2d returns a ConvTranspose2d layer. The options are those
+// of Conv2d plus OutputPadding, which must be smaller than the stride or
+// the dilation.
+func NewConvTranspose2d(in, out int, kernelSize []int, bias bool, opts functional.ConvOptions) (*ConvTranspose2d, error) {
+	m := &ConvTranspose2d{}
+	return m, m.init(in, out, kernelSize, bias, opts, "ConvTranspose2d", 2, true, functional.ConvTranspose2d)
+}
+
+// NewConvTranspose3d returns a ConvTranspose3d layer. The options are those
+// of Conv3d plus OutputPadding, which must be smaller than the stride or
+// the dilation.
+func NewConvTranspose3d(in, out int, kernelSize []int, bias bool, opts functional.ConvOptions) (*ConvTranspose3d, error) {
+	m := &ConvTranspose3d{}
+	return m, m.init(in, out, kernelSize, bias, opts, "ConvTranspose3d", 3, true, functional.ConvTranspose3d)
+}
+
+// init validates the configuration and creates the parameters, initialized
+// like PyTorch with Kaiming uniform (a = sqrt(5)) weights and a bias within
+// ±1/sqrt(fan_in). As in PyTorch, fan_in is computed from the second
+// dimension of the weight, which for a transposed convolution counts output
+// channels.
+func (m *ConvNd) init(in, out int, kernelSize []int, bias bool, opts functional.ConvOptions, name string, dims int, transposed bool, forward convFunc) error {
+	kernel, err := expandKernel(kernelSize, dims, name)
+	if err != nil {
+		return err
+	}
+	groups := opts.Groups
+	if groups == 0 {
+		groups = 1
+	}
+	if in <= 0 || out <= 0 || groups <= 0 || in%groups != 0 || out%groups != 0 {
+		return fmt.Errorf("%s: %d input and %d output channels must be positive and divisible by %d groups", name, in, out, groups)
+	}
+
+	m.InChannels, m.OutChannels, m.KernelSize, m.Options, m.Transposed = in, out, kernel, opts, transposed
+	m.name, m.forward = name, forward
+	shape := append([]int{out, in / groups}, kernel...)
+	if transposed {
+		shape = append([]int{in, out / groups}, kernel...)
+	}
+	fanIn := shapeSize(shape[1:])
+	w, err := uniform(shape, kaimingUniformBound(fanIn, math.Sqrt(5)))
+	if err != nil {
+		return err
+	}
+	m.Weight = NewParameter(w)
+	m.RegisterParameter("weight", m.Weight)
+	if bias {
+		b, err := uniform([]int{out}, 1/math.Sqrt(float64(fanIn)))
+		if err != nil {
+			return err
+		}
+		m.Bias = NewParameter(b)
+	}
+	m.RegisterParameter("bias", m.Bias)
+	return nil
+}
+
+func (m *ConvNd) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(inputs) != 1 {
+		return nil, errors.New(m.name + " takes exactly one input")
+	}
+	return m.forward(inputs[0], m.Weight.Tensor, parameterTensor(m.Bias), m.Options)
+}
+
+// expandKernel broadcasts a kernel size to dims positive values.
+func expandKernel(kernelSize []int, dims int, name string) ([]int, error) {
+	kernel := kernelSize
+	if len(kernel) == 1 {
+		kernel = make([]int, dims)
+		for i := range kernel {
+			kernel[i] = kernelSize[0]
+		}
+	}
+	if len(kernel) != dims {
+		return nil, fmt.Errorf("%s expects 1 or %d kernel sizes, got %d", name, dims, len(kernelSize))
+	}
+	for _, k := range kernel {
+		if k <= 0 {
+			return nil, errors.New(name + " requires positive kernel sizes")
+		}
+	}
+	return append([]int{}, kernel...), nil
+}
@@ -0,0 +1,55 @@
+package nn
+
+import (
+	"gotorch/nn/functional"
+	"gotorch/tensors"
+)
+
+type dropoutFunc func(input *tensors.Tensor, p float64, training bool, generator *tensors.Generator) (*tensors.Tensor, error)
+
+// DropoutNd holds the configuration shared by the dropout layers. They are
+// active only in training mode and return their input unchanged in
+// evaluation mode.
+type DropoutNd struct {
+	Base
+	P         float64
+	Generator *tensors.Generator // source of the masks; nil uses the default generator
+
+	name    string
+	dropout dropoutFunc
+}
+
+// Dropout zeroes elements with probability P and scales the others by
+// 1/(1-P).
+type Dropout struct{ DropoutNd }
+
+// Dropout2d zeroes whole channels of an (n, c, h, w) or (c, h, w) input
+// with probability P and scales the others by 1/(1-P).
+type Dropout2d struct{ DropoutNd }
+
+// AlphaDropout drops elements with probability P while keeping the mean
+// and variance of its input, for use with SELU activations.
+type AlphaDropout struct{ DropoutNd }
+
+// NewDropout returns a Dropout layer. PyTorch defaults p to 0.5.
+func NewDropout(p float64) *Dropout {
+	return &Dropout{DropoutNd{P: p, name: "Dropout", dropout: functional.Dropout}}
+}
+
+// NewDropout2d returns a Dropout2d layer. PyTorch defaults p to 0.5.
+func NewDropout2d(p float64) *Dropout2d {
+	return &Dropout2d{DropoutNd{P: p, name: "Dropout2d", dropout: functional.Dropout2d}}
+}
+
+// NewAlphaDropout returns an AlphaDropout layer. PyTorch defaults p to 0.5.
+func NewAlphaDropout(p float64) *AlphaDropout {
+	return &AlphaDropout{DropoutNd{P: p, name: "AlphaDropout", dropout: functional.AlphaDropout}}
+}
+
+func (m *DropoutNd) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, m.name)
+	if err != nil {
+		return nil, err
+	}
+	return m.dropout(input, m.P, m.IsTraining(), m.Generator)
+}
@@ -0,0 +1,87 @@
+package nn
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+
+	"gotorch/tensors"
+)
+
+var (
+	dtypeMu      sync.Mutex
+	defaultDtype tensors.Dtype = tensors.Float32{}
+)
+
+// DefaultDtype returns the data type of the parameters and buffers created
+// by layer constructors. It starts out as float32.
+func DefaultDtype() tensors.Dtype {
+	dtypeMu.Lock()
+	defer dtypeMu.Unlock()
+	return defaultDtype
+}
+
+// SetDefaultDtype sets the data type used by layer constructors and returns
+// the previous one.
+func SetDefaultDtype(dtype tensors.Dtype) tensors.Dtype {
+	dtypeMu.Lock()
+	defer dtypeMu.Unlock()
+	prev := defaultDtype
+	defaultDtype = dtype
+	return prev
+}
+
+// uniform returns a tensor of the default data type filled from
+// U(-bound, bound) using the default generator.
+func uniform(shape []int, bound float64) (*tensors.Tensor, error) {
+	values := make([]float64, shapeSize(shape))
+	tensors.DefaultGenerator.Fill(values, func(r *rand.Rand) float64 {
+		return (2*r.Float64() - 1) * bound
+	})
+	return fromFloat64(values, shape, DefaultDtype())
+}
+
+// normal returns a tensor of the default data type filled from
+// N(0, std^2) using the default generator.
+func normal(shape []int, std float64) (*tensors.Tensor, error) {
+	values := make([]float64, shapeSize(shape))
+	tensors.DefaultGenerator.Fill(values, func(r *rand.Rand) float64 {
+		return r.NormFloat64() * std
+	})
+	return fromFloat64(values, shape, DefaultDtype())
+}
+
+// full returns a tensor of the default data type filled with value.
+func full(shape []int, value float64) (*tensors.Tensor, error) {
+	values := make([]float64, shapeSize(shape))
+	for i := range values {
+		values[i] = value
+	}
+	return fromFloat64(values, shape, DefaultDtype())
+}
+
+// fromFloat64 wraps values in a tensor of the given data type.
+func fromFloat64(values []float64, shape []int, dtype tensors.Dtype) (*tensors.Tensor, error) {
+	var data interface{}
+	switch dtype.DataType() {
+	case "float32":
+		converted := make([]float32, len(values))
+		for i, v := range values {
+			converted[i] = float32(v)
+		}
+		data = converted
+	case "float64":
+		data = values
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	return &tensors.Tensor{Shape: append([]int{}, shape...), Data: data, Dtype: dtype}, nil
+}
+
+func shapeSize(shape []int) int {
+	size := 1
+	for _, d := range shape {
+		size *= d
+	}
+	return size
+}
@@ -0,0 +1,165 @@
+package nn
+
+import (
+	"errors"
+	"fmt"
+
+	"gotorch/nn/functional"
+	"gotorch/tensors"
+)
+
+// Embedding is a lookup table from int64 indices to rows of its weight.
+type Embedding struct {
+	Base
+	NumEmbeddings int
+	EmbeddingDim  int
+	Options       functional.EmbeddingOptions
+	Weight        *Parameter // (num_embeddings, embedding_dim)
+}
+
+// NewEmbedding returns an Embedding layer with weights drawn from N(0, 1).
+// The padding row, if any, starts out as zeros.
+func NewEmbedding(numEmbeddings, embeddingDim int, opts functional.EmbeddingOptions) (*Embedding, error) {
+	w, err := normal([]int{numEmbeddings, embeddingDim}, 1)
+	if err != nil {
+		return nil, err
+	}
+	return newEmbedding(w, opts, true)
+}
+
+// NewEmbeddingFromPretrained returns an Embedding layer whose weight shares
+// the data of the (num_embeddings, embedding_dim) embeddings tensor. A
+// frozen weight does not require grad.
+func NewEmbeddingFromPretrained(embeddings *tensors.Tensor, freeze bool, opts functional.EmbeddingOptions) (*Embedding, error) {
+	m, err := newEmbedding(embeddings, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	m.Weight.RequiresGrad = !freeze
+	return m, nil
+}
+
+func newEmbedding(weight *tensors.Tensor, opts functional.EmbeddingOptions, zeroPadding bool) (*Embedding, error) {
+	if len(weight.Shape) != 2 {
+		return nil, fmt.Errorf("Embedding expects a 2-d weight, got shape %v", weight.Shape)
+	}
+	rows, dim := weight.Shape[0], weight.Shape[1]
+	if rows <= 0 || dim <= 0 {
+		return nil, errors.New("Embedding requires a positive number of embeddings and dimension")
+	}
+	if opts.Sparse {
+		return nil, errors.New("Embedding does not support sparse gradients")
+	}
+	opts, err := resolvePaddingIdx(opts, rows)
+	if err != nil {
+		return nil, err
+	}
+	m := &Embedding{NumEmbeddings: rows, EmbeddingDim: dim, Options: opts, Weight: NewParameter(weight)}
+	if zeroPadding {
+		if err := zeroRow(m.Weight.Tensor, opts.PaddingIdx); err != nil {
+			return nil, err
+		}
+	}
+	m.RegisterParameter("weight", m.Weight)
+	return m, nil
+}
+
+func (m *Embedding) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "Embedding")
+	if err != nil {
+		return nil, err
+	}
+	return functional.Embedding(input, m.Weight.Tensor, m.Options)
+}
+
+// EmbeddingBag sums, averages or takes the maximum of bags of embedding
+// rows without materializing the individual rows.
+type EmbeddingBag struct {
+	Base
+	NumEmbeddings int
+	EmbeddingDim  int
+	Options       functional.EmbeddingBagOptions
+	Weight        *Parameter // (num_embeddings, embedding_dim)
+}
+
+// NewEmbeddingBag returns an EmbeddingBag layer with weights drawn from
+// N(0, 1). The padding row, if any, starts out as zeros.
+func NewEmbeddingBag(numEmbeddings, embeddingDim int, opts functional.EmbeddingBagOptions) (*EmbeddingBag, error) {
+	w, err := normal([]int{numEmbeddings, embeddingDim}, 1)
+	if err != nil {
+		return nil, err
+	}
+	return newEmbeddingBag(w, opts, true)
+}
+
+// NewEmbeddingBagFromPretrained returns an EmbeddingBag layer whose weight
+// shares the data of the (num_embeddings, embedding_dim) embeddings tensor.
+// A frozen weight does not require grad.
+func NewEmbeddingBagFromPretrained(embeddings *tensors.Tensor, freeze bool, opts functional.EmbeddingBagOptions) (*EmbeddingBag, error) {
+	m, err := newEmbeddingBag(embeddings, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	m.Weight.RequiresGrad = !freeze
+	return m, nil
+}
+
+func newEmbeddingBag(weight *tensors.Tensor, opts functional.EmbeddingBagOptions, zeroPadding bool) (*EmbeddingBag, error) {
+	e, err := newEmbedding(weight, opts.EmbeddingOptions, zeroPadding)
+	if err != nil {
+		return nil, err
+	}
+	opts.EmbeddingOptions = e.Options
+	m := &EmbeddingBag{NumEmbeddings: e.NumEmbeddings, EmbeddingDim: e.EmbeddingDim, Options: opts, Weight: e.Weight}
+	m.RegisterParameter("weight", m.Weight)
+	return m, nil
+}
+
+// Forward takes a 2-d input of fixed-size bags, or a 1-d input followed by
+// its offsets, and optionally per-sample weights for the sum mode.
+func (m *EmbeddingBag) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(inputs) == 0 || len(inputs) > 3 {
+		return nil, errors.New("EmbeddingBag takes an input, optional offsets and optional per-sample weights")
+	}
+	var offsets, perSampleWeights *tensors.Tensor
+	if len(inputs) > 1 {
+		offsets = inputs[1]
+	}
+	if len(inputs) > 2 {
+		perSampleWeights = inputs[2]
+	}
+	return functional.EmbeddingBag(inputs[0], m.Weight.Tensor, offsets, perSampleWeights, m.Options)
+}
+
+// resolvePaddingIdx turns a negative padding index into a row number.
+func resolvePaddingIdx(opts functional.EmbeddingOptions, rows int) (functional.EmbeddingOptions, error) {
+	if opts.PaddingIdx == nil {
+		return opts, nil
+	}
+	idx := *opts.PaddingIdx
+	if idx < 0 {
+		idx += rows
+	}
+	if idx < 0 || idx >= rows {
+		return opts, fmt.Errorf("Embedding: padding index %d is out of range for %d embeddings", *opts.PaddingIdx, rows)
+	}
+	opts.PaddingIdx = &idx
+	return opts, nil
+}
+
+// zeroRow sets row idx of a 2-d tensor to zeros.
+func zeroRow(t *tensors.Tensor, idx *int) error {
+	if idx == nil {
+		return nil
+	}
+	dim := t.Shape[1]
+	switch data := t.Data.(type) {
+	case []float32:
+		clear(data[*idx*dim : (*idx+1)*dim])
+	case []float64:
+		clear(data[*idx*dim : (*idx+1)*dim])
+	default:
+		return errors.New("unsupported data type")
+	}
+	return nil
+}
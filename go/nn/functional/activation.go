@@ -0,0 +1,395 @@
+package functional
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gotorch/internal/parallel"
+	"gotorch/tensors"
+)
+
+// The SELU constants from Klambauer et al., "Self-Normalizing Neural
+// Networks".
+const (
+	seluAlpha = 1.6732632423543772848170429916717
+	seluScale = 1.0507009873554804934193349852946
+)
+
+// ReLU returns max(x, 0) elementwise.
+func ReLU(input *tensors.Tensor) (*tensors.Tensor, error) {
+	return pointwise(input, "ReLU",
+		func(x float64) float64 { return math.Max(x, 0) },
+		func(x, _ float64) float64 {
+			if x > 0 {
+				return 1
+			}
+			return 0
+		})
+}
+
+// LeakyReLU returns x for positive x and negativeSlope*x otherwise.
+// PyTorch defaults negativeSlope to 0.01.
+func LeakyReLU(input *tensors.Tensor, negativeSlope float64) (*tensors.Tensor, error) {
+	return pointwise(input, "LeakyReLU",
+		func(x float64) float64 {
+			if x > 0 {
+				return x
+			}
+			return negativeSlope * x
+		},
+		func(x, _ float64) float64 {
+			if x > 0 {
+				return 1
+			}
+			return negativeSlope
+		})
+}
+
+// ELU returns x for positive x and alpha*(exp(x)-1) otherwise. PyTorch
+// defaults alpha to 1.
+func ELU(input *tensors.Tensor, alpha float64) (*tensors.Tensor, error) {
+	return pointwise(input, "ELU",
+		func(x float64) float64 {
+			if x > 0 {
+				return x
+			}
+			return alpha * math.Expm1(x)
+		},
+		func(x, y float64) float64 {
+			if x > 0 {
+				return 1
+			}
+			return y + alpha
+		})
+}
+
+// SELU returns scale*ELU(x, alpha) with the constants that keep
+// activations at zero mean and unit variance.
+func SELU(input *tensors.Tensor) (*tensors.Tensor, error) {
+	return pointwise(input, "SELU",
+		func(x float64) float64 {
+			if x > 0 {
+				return seluScale * x
+			}
+			return seluScale * seluAlpha * math.Expm1(x)
+		},
+		func(x, y float64) float64 {
+			if x > 0 {
+				return seluScale
+			}
+			return y + seluScale*seluAlpha
+		})
+}
+
+// GELU returns x*Phi(x), where Phi is the standard normal distribution
+// function. approximate is "none" for the exact form or "tanh" for
+// 0.5*x*(1+tanh(sqrt(2/pi)*(x+0.044715*x^3))).
+func GELU(input *tensors.Tensor, approximate string) (*tensors.Tensor, error) {
+	switch approximate {
+	case "none":
+		return pointwise(input, "GELU",
+			func(x float64) float64 { return x * normalCDF(x) },
+			func(x, _ float64) float64 { return normalCDF(x) + x*math.Exp(-x*x/2)/math.Sqrt(2*math.Pi) })
+	case "tanh":
+		const k, c = 0.7978845608028654, 0.044715 // sqrt(2/pi)
+		return pointwise(input, "GELU",
+			func(x float64) float64 { return 0.5 * x * (1 + math.Tanh(k*(x+c*x*x*x))) },
+			func(x, _ float64) float64 {
+				t := math.Tanh(k * (x + c*x*x*x))
+				return 0.5*(1+t) + 0.5*x*(1-t*t)*k*(1+3*c*x*x)
+			})
+	}
+	return nil, fmt.Errorf("GELU: approximate must be \"none\" or \"tanh\", got %q", approximate)
+}
+
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// SiLU returns x*sigmoid(x), also known as swish.
+func SiLU(input *tensors.Tensor) (*tensors.Tensor, error) {
+	return pointwise(input, "SiLU",
+		func(x float64) float64 { return x * sigmoid(x) },
+		func(x, _ float64) float64 {
+			s := sigmoid(x)
+			return s * (1 + x*(1-s))
+		})
+}
+
+// Mish returns x*tanh(softplus(x)).
+func Mish(input *tensors.Tensor) (*tensors.Tensor, error) {
+	return pointwise(input, "Mish",
+		func(x float64) float64 { return x * math.Tanh(softplus(x)) },
+		func(x, _ float64) float64 {
+			t := math.Tanh(softplus(x))
+			return t + x*(1-t*t)*sigmoid(x)
+		})
+}
+
+// Softplus returns log(1+exp(beta*x))/beta, reverting to x where
+// beta*x > threshold. PyTorch defaults beta to 1 and threshold to 20.
+func Softplus(input *tensors.Tensor, beta, threshold float64) (*tensors.Tensor, error) {
+	if beta == 0 {
+		return nil, errors.New("Softplus: beta must be non-zero")
+	}
+	return pointwise(input, "Softplus",
+		func(x float64) float64 {
+			if beta*x > threshold {
+				return x
+			}
+			return softplus(beta*x) / beta
+		},
+		func(x, _ float64) float64 {
+			if beta*x > threshold {
+				return 1
+			}
+			return sigmoid(beta * x)
+		})
+}
+
+// softplus returns log(1+exp(x)) without overflow.
+func softplus(x float64) float64 {
+	if x > 0 {
+		return x + math.Log1p(math.Exp(-x))
+	}
+	return math.Log1p(math.Exp(x))
+}
+
+// Hardswish returns x*min(max(x+3, 0), 6)/6.
+func Hardswish(input *tensors.Tensor) (*tensors.Tensor, error) {
+	return pointwise(input, "Hardswish",
+		func(x float64) float64 {
+			switch {
+			case x <= -3:
+				return 0
+			case x >= 3:
+				return x
+			}
+			return x * (x + 3) / 6
+		},
+		func(x, _ float64) float64 {
+			switch {
+			case x < -3:
+				return 0
+			case x > 3:
+				return 1
+			}
+			return x/3 + 0.5
+		})
+}
+
+// Tanh returns the hyperbolic tangent of every element.
+func Tanh(input *tensors.Tensor) (*tensors.Tensor, error) {
+	return pointwise(input, "Tanh", math.Tanh, func(_, y float64) float64 { return 1 - y*y })
+}
+
+// Sigmoid returns 1/(1+exp(-x)) elementwise.
+func Sigmoid(input *tensors.Tensor) (*tensors.Tensor, error) {
+	return pointwise(input, "Sigmoid", sigmoid, func(_, y float64) float64 { return y * (1 - y) })
+}
+
+func sigmoid(x float64) float64 {
+	if x >= 0 {
+		return 1 / (1 + math.Exp(-x))
+	}
+	e := math.Exp(x)
+	return e / (1 + e)
+}
+
+// PReLU returns x for positive x and a*x otherwise, where a is learned.
+// weight holds either a single slope or one per channel, with channels
+// along dimension 1 of input, or dimension 0 for a 1-d input.
+func PReLU(input, weight *tensors.Tensor) (*tensors.Tensor, error) {
+	if len(weight.Shape) > 1 {
+		return nil, fmt.Errorf("PReLU expects a 0-d or 1-d weight, got shape %v", weight.Shape)
+	}
+	if input.Dtype != weight.Dtype {
+		return nil, errors.New("tensors must have the same data type")
+	}
+	channels, inner := 1, shapeSize(input.Shape)
+	if n := shapeSize(weight.Shape); n != 1 {
+		dim := min(1, len(input.Shape)-1)
+		if dim < 0 || input.Shape[dim] != n {
+			return nil, fmt.Errorf("PReLU: %d slopes do not match input of shape %v", n, input.Shape)
+		}
+		channels, inner = n, shapeSize(input.Shape[dim+1:])
+	}
+
+	var data interface{}
+	switch x := input.Data.(type) {
+	case []float32:
+		data = preluForward(x, weight.Data.([]float32), channels, inner)
+	case []float64:
+		data = preluForward(x, weight.Data.([]float64), channels, inner)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	out := &tensors.Tensor{Shape: append([]int{}, input.Shape...), Data: data, Dtype: input.Dtype}
+
+	px, pw := input.Detach(), weight.Detach()
+	err := tensors.RecordJVP(out, "PReLUBackward", []*tensors.Tensor{input, weight}, []*tensors.Tensor{px, pw}, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		var gx, gw interface{}
+		switch gy := grad.Data.(type) {
+		case []float32:
+			gx, gw = preluBackward(gy, px.Data.([]float32), pw.Data.([]float32), channels, inner)
+		case []float64:
+			gx, gw = preluBackward(gy, px.Data.([]float64), pw.Data.([]float64), channels, inner)
+		default:
+			return nil, errors.New("unsupported data type")
+		}
+		return []*tensors.Tensor{
+			{Shape: append([]int{}, px.Shape...), Data: gx, Dtype: grad.Dtype},
+			{Shape: append([]int{}, pw.Shape...), Data: gw, Dtype: grad.Dtype},
+		}, nil
+	}, func(tangents []*tensors.Tensor) (*tensors.Tensor, error) {
+		t := &tensors.Tensor{Shape: append([]int{}, px.Shape...), Dtype: px.Dtype}
+		switch x := px.Data.(type) {
+		case []float32:
+			t.Data = preluJVP(tangentData[float32](tangents[0]), tangentData[float32](tangents[1]), x, pw.Data.([]float32), channels, inner)
+		case []float64:
+			t.Data = preluJVP(tangentData[float64](tangents[0]), tangentData[float64](tangents[1]), x, pw.Data.([]float64), channels, inner)
+		default:
+			return nil, errors.New("unsupported data type")
+		}
+		return t, nil
+	})
+	return out, err
+}
+
+// preluForward applies slope w[c] to the negative elements of x, laid out
+// as (outer, channels, inner).
+func preluForward[T float32 | float64](x, w []T, channels, inner int) []T {
+	y := make([]T, len(x))
+	parallel.For(len(x), 1, func(start, end int) {
+		for i := start; i < end; i++ {
+			if y[i] = x[i]; x[i] <= 0 {
+				y[i] *= w[i/inner%channels]
+			}
+		}
+	})
+	return y
+}
+
+// preluJVP returns the tangent of PReLU for the input tangent tx and the
+// slope tangent tw, either of which may be nil.
+func preluJVP[T float32 | float64](tx, tw, x, w []T, channels, inner int) []T {
+	t := make([]T, len(x))
+	parallel.For(len(x), 1, func(start, end int) {
+		for i := start; i < end; i++ {
+			if x[i] > 0 {
+				if tx != nil {
+					t[i] = tx[i]
+				}
+				continue
+			}
+			c := i / inner % channels
+			if tx != nil {
+				t[i] = tx[i] * w[c]
+			}
+			if tw != nil {
+				t[i] += x[i] * tw[c]
+			}
+		}
+	})
+	return t
+}
+
+// tangentData returns the elements of a tangent, or nil when there is none.
+func tangentData[T float32 | float64](t *tensors.Tensor) []T {
+	if t == nil {
+		return nil
+	}
+	return t.Data.([]T)
+}
+
+// preluBackward returns the input gradient and the slope gradients, each
+// summed over its channel by a single worker.
+func preluBackward[T float32 | float64](gy, x, w []T, channels, inner int) ([]T, []T) {
+	gx := make([]T, len(x))
+	parallel.For(len(x), 1, func(start, end int) {
+		for i := start; i < end; i++ {
+			if gx[i] = gy[i]; x[i] <= 0 {
+				gx[i] *= w[i/inner%channels]
+			}
+		}
+	})
+	gw := make([]T, len(w))
+	outer := len(x) / (channels * inner)
+	parallel.For(channels, outer*inner, func(start, end int) {
+		for c := start; c < end; c++ {
+			var sum float64
+			for o := 0; o < outer; o++ {
+				base := (o*channels + c) * inner
+				for i := base; i < base+inner; i++ {
+					if x[i] <= 0 {
+						sum += float64(gy[i]) * float64(x[i])
+					}
+				}
+			}
+			gw[c] = T(sum)
+		}
+	})
+	return gx, gw
+}
+
+// pointwiseCost is the work estimate of one element of a pointwise op,
+// which is dominated by calls such as math.Exp.
+const pointwiseCost = 16
+
+// pointwise applies f to every element of input in float64 and records a
+// backward that scales the gradient, and a forward-mode rule that scales
+// the tangent, by df(x, f(x)).
+func pointwise(input *tensors.Tensor, name string, f func(x float64) float64, df func(x, y float64) float64) (*tensors.Tensor, error) {
+	var data interface{}
+	switch x := input.Data.(type) {
+	case []float32:
+		data = pointwiseKernel(x, f)
+	case []float64:
+		data = pointwiseKernel(x, f)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	out := &tensors.Tensor{Shape: append([]int{}, input.Shape...), Data: data, Dtype: input.Dtype}
+	px, py := input.Detach(), out.Detach()
+	// The tangent is scaled by the same derivative as the gradient.
+	scale := func(t *tensors.Tensor) (*tensors.Tensor, error) {
+		gx := &tensors.Tensor{Shape: append([]int{}, t.Shape...), Dtype: t.Dtype}
+		switch v := t.Data.(type) {
+		case []float32:
+			gx.Data = pointwiseGrad(v, px.Data.([]float32), py.Data.([]float32), df)
+		case []float64:
+			gx.Data = pointwiseGrad(v, px.Data.([]float64), py.Data.([]float64), df)
+		default:
+			return nil, errors.New("unsupported data type")
+		}
+		return gx, nil
+	}
+	err := tensors.RecordJVP(out, name+"Backward", []*tensors.Tensor{input}, []*tensors.Tensor{px, py}, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		gx, err := scale(grad)
+		return []*tensors.Tensor{gx}, err
+	}, func(tangents []*tensors.Tensor) (*tensors.Tensor, error) {
+		return scale(tangents[0])
+	})
+	return out, err
+}
+
+func pointwiseKernel[T float32 | float64](x []T, f func(x float64) float64) []T {
+	y := make([]T, len(x))
+	parallel.For(len(x), pointwiseCost, func(start, end int) {
+		for i := start; i < end; i++ {
+			y[i] = T(f(float64(x[i])))
+		}
+	})
+	return y
+}
+
+func pointwiseGrad[T float32 | float64](gy, x, y []T, df func(x, y float64) float64) []T {
+	gx := make([]T, len(gy))
+	parallel.For(len(gy), pointwiseCost, func(start, end int) {
+		for i := start; i < end; i++ {
+			gx[i] = T(float64(gy[i]) * df(float64(x[i]), float64(y[i])))
+		}
+	})
+	return gx
+}
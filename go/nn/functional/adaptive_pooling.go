@@ -0,0 +1,245 @@
+package functional
+
+import (
+	"errors"
+	"fmt"
+
+	"gotorch/internal/parallel"
+	"gotorch/tensors"
+)
+
+// AdaptiveAvgPool1d averages an (n, c, l) or (c, l) input over windows
+// chosen so the output has the given size. outputSize holds one value, and
+// 0 keeps the input size.
+func AdaptiveAvgPool1d(input *tensors.Tensor, outputSize []int) (*tensors.Tensor, error) {
+	return adaptiveAvgPool(input, outputSize, 1)
+}
+
+// AdaptiveAvgPool2d averages an (n, c, h, w) or (c, h, w) input over
+// windows chosen so the output has the given size. outputSize holds one
+// value or one per dimension, and 0 keeps the input size.
+func AdaptiveAvgPool2d(input *tensors.Tensor, outputSize []int) (*tensors.Tensor, error) {
+	return adaptiveAvgPool(input, outputSize, 2)
+}
+
+// AdaptiveAvgPool3d averages an (n, c, d, h, w) or (c, d, h, w) input over
+// windows chosen so the output has the given size. outputSize holds one
+// value or one per dimension, and 0 keeps the input size.
+func AdaptiveAvgPool3d(input *tensors.Tensor, outputSize []int) (*tensors.Tensor, error) {
+	return adaptiveAvgPool(input, outputSize, 3)
+}
+
+// AdaptiveMaxPool1d takes the maximum of an (n, c, l) or (c, l) input over
+// windows chosen so the output has the given size.
+func AdaptiveMaxPool1d(input *tensors.Tensor, outputSize []int) (*tensors.Tensor, error) {
+	out, _, err := adaptiveMaxPool(input, outputSize, 1)
+	return out, err
+}
+
+// AdaptiveMaxPool2d takes the maximum of an (n, c, h, w) or (c, h, w)
+// input over windows chosen so the output has the given size.
+func AdaptiveMaxPool2d(input *tensors.Tensor, outputSize []int) (*tensors.Tensor, error) {
+	out, _, err := adaptiveMaxPool(input, outputSize, 2)
+	return out, err
+}
+
+// AdaptiveMaxPool3d takes the maximum of an (n, c, d, h, w) or
+// (c, d, h, w) input over windows chosen so the output has the given size.
+func AdaptiveMaxPool3d(input *tensors.Tensor, outputSize []int) (*tensors.Tensor, error) {
+	out, _, err := adaptiveMaxPool(input, outputSize, 3)
+	return out, err
+}
+
+// AdaptiveMaxPool1dWithIndices is AdaptiveMaxPool1d that also returns the
+// int64 position of every maximum within its input plane.
+func AdaptiveMaxPool1dWithIndices(input *tensors.Tensor, outputSize []int) (*tensors.Tensor, *tensors.Tensor, error) {
+	return adaptiveMaxPool(input, outputSize, 1)
+}
+
+// AdaptiveMaxPool2dWithIndices is AdaptiveMaxPool2d that also returns the
+// int64 flat position of every maximum within its input plane.
+func AdaptiveMaxPool2dWithIndices(input *tensors.Tensor, outputSize []int) (*tensors.Tensor, *tensors.Tensor, error) {
+	return adaptiveMaxPool(input, outputSize, 2)
+}
+
+// AdaptiveMaxPool3dWithIndices is AdaptiveMaxPool3d that also returns the
+// int64 flat position of every maximum within its input plane.
+func AdaptiveMaxPool3dWithIndices(input *tensors.Tensor, outputSize []int) (*tensors.Tensor, *tensors.Tensor, error) {
+	return adaptiveMaxPool(input, outputSize, 3)
+}
+
+// adaptiveWindows returns the output size and, for every output position,
+// the flat input positions of its window. Along each dimension output o
+// covers [floor(o*in/out), ceil((o+1)*in/out)).
+func adaptiveWindows(in, outputSize []int, name string) ([]int, [][]int, error) {
+	dims := len(in)
+	out, err := expandInts(outputSize, dims, 0, "output size", name)
+	if err != nil {
+		return nil, nil, err
+	}
+	out = append([]int{}, out...)
+	ranges := make([][][2]int, dims)
+	for d := range out {
+		if out[d] == 0 {
+			out[d] = in[d]
+		}
+		if out[d] < 0 {
+			return nil, nil, fmt.Errorf("%s requires a non-negative output size, got %v", name, outputSize)
+		}
+		ranges[d] = make([][2]int, out[d])
+		for o := range ranges[d] {
+			ranges[d][o] = [2]int{o * in[d] / out[d], ((o+1)*in[d] + out[d] - 1) / out[d]}
+		}
+	}
+
+	strides := make([]int, dims)
+	stride := 1
+	for d := dims - 1; d >= 0; d-- {
+		strides[d] = stride
+		stride *= in[d]
+	}
+	windows := [][]int{{0}}
+	for d := range out {
+		var next [][]int
+		for _, w := range windows {
+			for _, r := range ranges[d] {
+				var idx []int
+				for _, base := range w {
+					for i := r[0]; i < r[1]; i++ {
+						idx = append(idx, base+i*strides[d])
+					}
+				}
+				next = append(next, idx)
+			}
+		}
+		windows = next
+	}
+	return out, windows, nil
+}
+
+func adaptiveAvgPool(input *tensors.Tensor, outputSize []int, dims int) (*tensors.Tensor, error) {
+	name := fmt.Sprintf("AdaptiveAvgPool%dd", dims)
+	planes, err := poolPlanes(input, dims, name)
+	if err != nil {
+		return nil, err
+	}
+	out, windows, err := adaptiveWindows(input.Shape[len(input.Shape)-dims:], outputSize, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+	switch x := input.Data.(type) {
+	case []float32:
+		data = adaptiveAvgKernel(x, planes, windows)
+	case []float64:
+		data = adaptiveAvgKernel(x, planes, windows)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	y := &tensors.Tensor{Shape: pooledShape(input.Shape, out), Data: data, Dtype: input.Dtype}
+	inShape := append([]int{}, input.Shape...)
+	err = tensors.Record(y, fmt.Sprintf("AdaptiveAvgPool%ddBackward", dims), []*tensors.Tensor{input}, nil, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		gx := &tensors.Tensor{Shape: inShape, Dtype: grad.Dtype}
+		switch gy := grad.Data.(type) {
+		case []float32:
+			gx.Data = adaptiveAvgBackward(gy, planes, shapeSize(inShape)/planes, windows)
+		case []float64:
+			gx.Data = adaptiveAvgBackward(gy, planes, shapeSize(inShape)/planes, windows)
+		default:
+			return nil, errors.New("unsupported data type")
+		}
+		return []*tensors.Tensor{gx}, nil
+	})
+	return y, err
+}
+
+func adaptiveAvgKernel[T float32 | float64](x []T, planes int, windows [][]int) []T {
+	plane, l := len(x)/planes, len(windows)
+	y := make([]T, planes*l)
+	parallel.For(planes, plane, func(start, end int) {
+		for p := start; p < end; p++ {
+			src := x[p*plane : (p+1)*plane]
+			for pos, w := range windows {
+				var sum T
+				for _, idx := range w {
+					sum += src[idx]
+				}
+				y[p*l+pos] = sum / T(len(w))
+			}
+		}
+	})
+	return y
+}
+
+func adaptiveAvgBackward[T float32 | float64](gy []T, planes, plane int, windows [][]int) []T {
+	l := len(windows)
+	gx := make([]T, planes*plane)
+	parallel.For(planes, plane, func(start, end int) {
+		for p := start; p < end; p++ {
+			dst := gx[p*plane : (p+1)*plane]
+			for pos, w := range windows {
+				g := gy[p*l+pos] / T(len(w))
+				for _, idx := range w {
+					dst[idx] += g
+				}
+			}
+		}
+	})
+	return gx
+}
+
+func adaptiveMaxPool(input *tensors.Tensor, outputSize []int, dims int) (*tensors.Tensor, *tensors.Tensor, error) {
+	name := fmt.Sprintf("AdaptiveMaxPool%dd", dims)
+	planes, err := poolPlanes(input, dims, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, windows, err := adaptiveWindows(input.Shape[len(input.Shape)-dims:], outputSize, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		data    interface{}
+		indices []int64
+	)
+	switch x := input.Data.(type) {
+	case []float32:
+		data, indices = adaptiveMaxKernel(x, planes, windows)
+	case []float64:
+		data, indices = adaptiveMaxKernel(x, planes, windows)
+	default:
+		return nil, nil, errors.New("unsupported data type")
+	}
+	shape := pooledShape(input.Shape, out)
+	y := &tensors.Tensor{Shape: shape, Data: data, Dtype: input.Dtype}
+	idx := &tensors.Tensor{Shape: append([]int{}, shape...), Data: indices, Dtype: tensors.Int64{}}
+	inShape := append([]int{}, input.Shape...)
+	err = tensors.Record(y, fmt.Sprintf("AdaptiveMaxPool%ddBackward", dims), []*tensors.Tensor{input}, nil, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		gx, err := scatterPlanes(grad, indices, inShape, planes, true)
+		return []*tensors.Tensor{gx}, err
+	})
+	return y, idx, err
+}
+
+func adaptiveMaxKernel[T float32 | float64](x []T, planes int, windows [][]int) ([]T, []int64) {
+	plane, l := len(x)/planes, len(windows)
+	y := make([]T, planes*l)
+	indices := make([]int64, planes*l)
+	parallel.For(planes, plane, func(start, end int) {
+		for p := start; p < end; p++ {
+			src := x[p*plane : (p+1)*plane]
+			for pos, w := range windows {
+				best := w[0]
+				for _, idx := range w[1:] {
+					if v := src[idx]; v > src[best] || (v != v && src[best] == src[best]) {
+						best = idx
+					}
+				}
+				y[p*l+pos], indices[p*l+pos] = src[best], int64(best)
+			}
+		}
+	})
+	return y, indices
+}
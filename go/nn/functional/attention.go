@@ -0,0 +1,370 @@
+package functional
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"gotorch/internal/parallel"
+	"gotorch/tensors"
+)
+
+// attentionChunk is the number of keys scored at a time. Softmax is
+// accumulated online over chunks, so the (query, key) score matrix is never
+// materialized.
+const attentionChunk = 64
+
+// AttentionOptions configures ScaledDotProductAttention.
+type AttentionOptions struct {
+	// DropoutP is the probability of dropping an attention weight. The
+	// masks are drawn from Generator, or the default generator when nil.
+	DropoutP  float64
+	Generator *tensors.Generator
+	// IsCausal masks key j for query i whenever j > i.
+	IsCausal bool
+	// Scale multiplies the scores; 0 means 1/sqrt(embedding dim).
+	Scale float64
+}
+
+// ScaledDotProductAttention computes softmax(q k^T * scale + mask) v for
+// a query of shape (..., l, e), a key of shape (..., s, e) and a value of
+// shape (..., s, ev), with the same leading dimensions such as
+// (batch, heads). attnMask, which may be nil, is added to the scores and
+// must broadcast to (..., l, s); use -Inf to mask a position. It is treated
+// as a constant. Queries whose keys are all masked give zeros.
+//
+// The softmax is computed online over chunks of keys and the backward
+// recomputes the weights from the saved log-sum-exp of every query. The
+// mask is read through its broadcast strides and the dropout decisions are
+// redrawn from a saved seed, so apart from the mask as given the memory
+// used grows with l + s rather than l * s.
+func ScaledDotProductAttention(query, key, value, attnMask *tensors.Tensor, opts AttentionOptions) (*tensors.Tensor, error) {
+	q, k, v := query.Shape, key.Shape, value.Shape
+	n := len(q)
+	if n < 2 || len(k) != n || len(v) != n {
+		return nil, fmt.Errorf("ScaledDotProductAttention expects query, key and value of equal rank of at least 2, got %v, %v and %v", q, k, v)
+	}
+	if !equalInts(q[:n-2], k[:n-2]) || !equalInts(q[:n-2], v[:n-2]) || q[n-1] != k[n-1] || k[n-2] != v[n-2] {
+		return nil, fmt.Errorf("ScaledDotProductAttention: query %v, key %v and value %v do not match", q, k, v)
+	}
+	if query.Dtype != key.Dtype || query.Dtype != value.Dtype {
+		return nil, errors.New("tensors must have the same data type")
+	}
+	if !(opts.DropoutP >= 0 && opts.DropoutP < 1) {
+		return nil, fmt.Errorf("ScaledDotProductAttention dropout has to be in [0, 1), got %v", opts.DropoutP)
+	}
+	g := attentionGeometry{
+		batch: leading(q, 2), l: q[n-2], s: k[n-2], e: q[n-1], ev: v[n-1],
+		scale: opts.Scale, causal: opts.IsCausal,
+	}
+	if g.scale == 0 {
+		g.scale = 1 / math.Sqrt(float64(g.e))
+	}
+
+	if attnMask != nil {
+		if err := g.setMask(attnMask, q[:n-2]); err != nil {
+			return nil, err
+		}
+	}
+	if opts.DropoutP > 0 {
+		generator := opts.Generator
+		if generator == nil {
+			generator = tensors.DefaultGenerator
+		}
+		g.dropP = opts.DropoutP
+		g.dropSeed = uint64(generator.Float64() * (1 << 53))
+	}
+
+	var data interface{}
+	lse := make([]float64, g.batch*g.l)
+	switch qd := query.Data.(type) {
+	case []float32:
+		data = attentionForward(g, qd, key.Data.([]float32), value.Data.([]float32), lse)
+	case []float64:
+		data = attentionForward(g, qd, key.Data.([]float64), value.Data.([]float64), lse)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	out := &tensors.Tensor{Shape: append(append([]int{}, q[:n-1]...), g.ev), Data: data, Dtype: query.Dtype}
+
+	pq, pk, pv, po := query.Detach(), key.Detach(), value.Detach(), out.Detach()
+	err := tensors.Record(out, "ScaledDotProductAttentionBackward", []*tensors.Tensor{query, key, value}, []*tensors.Tensor{pq, pk, pv, po}, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		var gq, gk, gv interface{}
+		switch gy := grad.Data.(type) {
+		case []float32:
+			gq, gk, gv = attentionBackward(g, gy, pq.Data.([]float32), pk.Data.([]float32), pv.Data.([]float32), po.Data.([]float32), lse)
+		case []float64:
+			gq, gk, gv = attentionBackward(g, gy, pq.Data.([]float64), pk.Data.([]float64), pv.Data.([]float64), po.Data.([]float64), lse)
+		default:
+			return nil, errors.New("unsupported data type")
+		}
+		return []*tensors.Tensor{
+			{Shape: append([]int{}, pq.Shape...), Data: gq, Dtype: grad.Dtype},
+			{Shape: append([]int{}, pk.Shape...), Data: gk, Dtype: grad.Dtype},
+			{Shape: append([]int{}, pv.Shape...), Data: gv, Dtype: grad.Dtype},
+		}, nil
+	})
+	return out, err
+}
+
+// attentionGeometry describes batch independent attention problems of l
+// queries over s keys.
+type attentionGeometry struct {
+	batch, l, s, e, ev int
+	scale              float64
+	causal             bool
+
+	// mask is the additive mask as given, or nil. Entry (i, j) of problem b
+	// is at maskBase[b] + i*maskL + j*maskS; broadcast dimensions have a
+	// stride of 0, so the mask is never expanded to (batch, l, s).
+	mask         []float64
+	maskBase     []int
+	maskL, maskS int
+
+	// dropP is the dropout probability, 0 for none. The keep decisions of
+	// every chunk of keys of every query come from a stream of its own
+	// seeded with dropSeed, so the backward redraws them instead of keeping
+	// a (batch, l, s) mask.
+	dropP    float64
+	dropSeed uint64
+}
+
+// setMask copies mask as given and sets up reading it through its strides
+// broadcast to (lead..., l, s).
+func (g *attentionGeometry) setMask(mask *tensors.Tensor, lead []int) error {
+	shape := append(append([]int{}, lead...), g.l, g.s)
+	m := mask.Shape
+	if len(m) > len(shape) {
+		return fmt.Errorf("ScaledDotProductAttention: mask of shape %v does not broadcast to %v", m, shape)
+	}
+	strides := make([]int, len(shape))
+	stride := 1
+	for d := len(shape) - 1; d >= len(shape)-len(m); d-- {
+		md := d - (len(shape) - len(m))
+		switch m[md] {
+		case shape[d]:
+			strides[d] = stride
+		case 1:
+		default:
+			return fmt.Errorf("ScaledDotProductAttention: mask of shape %v does not broadcast to %v", m, shape)
+		}
+		stride *= m[md]
+	}
+
+	values, err := toFloat64(mask)
+	if err != nil {
+		return err
+	}
+	g.mask = values
+	g.maskL, g.maskS = strides[len(lead)], strides[len(lead)+1]
+	g.maskBase = make([]int, g.batch)
+	for b := range g.maskBase {
+		rest := b
+		for d := len(lead) - 1; d >= 0; d-- {
+			g.maskBase[b] += rest % lead[d] * strides[d]
+			rest /= lead[d]
+		}
+	}
+	return nil
+}
+
+// score returns the masked, scaled score of query i and key j of problem b,
+// or -Inf when the key is masked.
+func (g attentionGeometry) score(b, i, j int, dot float64) float64 {
+	if g.causal && j > i {
+		return math.Inf(-1)
+	}
+	s := dot * g.scale
+	if g.mask != nil {
+		s += g.mask[g.maskBase[b]+i*g.maskL+j*g.maskS]
+	}
+	return s
+}
+
+// keys returns the number of keys query i can attend to before the causal
+// cut-off.
+func (g attentionGeometry) keys(i int) int {
+	if g.causal {
+		return min(i+1, g.s)
+	}
+	return g.s
+}
+
+// dropout draws the dropout scales of the keys from c0 on of query row into
+// keep, one per entry, using r over src. Without dropout every scale is 1.
+func (g attentionGeometry) dropout(src *rand.PCG, r *rand.Rand, row, c0 int, keep []float64) {
+	if g.dropP == 0 {
+		for j := range keep {
+			keep[j] = 1
+		}
+		return
+	}
+	chunks := (g.s + attentionChunk - 1) / attentionChunk
+	src.Seed(g.dropSeed, uint64(row*chunks+c0/attentionChunk))
+	scale := 1 / (1 - g.dropP)
+	for j := range keep {
+		keep[j] = scale
+		if r.Float64() < g.dropP {
+			keep[j] = 0
+		}
+	}
+}
+
+func dot[T float32 | float64](a, b []T) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// attentionForward computes the output of every query with an online
+// softmax over chunks of keys, storing the log-sum-exp of its scores.
+func attentionForward[T float32 | float64](g attentionGeometry, q, k, v []T, lse []float64) []T {
+	out := make([]T, g.batch*g.l*g.ev)
+	parallel.For(g.batch*g.l, g.s*(g.e+g.ev), func(start, end int) {
+		scores := make([]float64, attentionChunk)
+		keep := make([]float64, attentionChunk)
+		src := rand.NewPCG(0, 0)
+		r := rand.New(src)
+		acc := make([]float64, g.ev)
+		for row := start; row < end; row++ {
+			b, i := row/g.l, row%g.l
+			qi := q[row*g.e : (row+1)*g.e]
+			clear(acc)
+			m, sum := math.Inf(-1), 0.0
+			for c0 := 0; c0 < g.keys(i); c0 += attentionChunk {
+				c1 := min(c0+attentionChunk, g.keys(i))
+				chunkMax := math.Inf(-1)
+				for j := c0; j < c1; j++ {
+					kj := k[(b*g.s+j)*g.e : (b*g.s+j+1)*g.e]
+					scores[j-c0] = g.score(b, i, j, dot(qi, kj))
+					chunkMax = math.Max(chunkMax, scores[j-c0])
+				}
+				if math.IsInf(chunkMax, -1) {
+					continue
+				}
+				if chunkMax > m {
+					rescale := math.Exp(m - chunkMax)
+					sum *= rescale
+					for d := range acc {
+						acc[d] *= rescale
+					}
+					m = chunkMax
+				}
+				g.dropout(src, r, row, c0, keep[:c1-c0])
+				for j := c0; j < c1; j++ {
+					p := math.Exp(scores[j-c0] - m)
+					sum += p
+					p *= keep[j-c0]
+					if p == 0 {
+						continue
+					}
+					vj := v[(b*g.s+j)*g.ev : (b*g.s+j+1)*g.ev]
+					for d, x := range vj {
+						acc[d] += p * float64(x)
+					}
+				}
+			}
+			dst := out[row*g.ev : (row+1)*g.ev]
+			if sum == 0 {
+				lse[row] = math.Inf(1)
+				continue
+			}
+			lse[row] = m + math.Log(sum)
+			for d := range dst {
+				dst[d] = T(acc[d] / sum)
+			}
+		}
+	})
+	return out
+}
+
+// attentionBackward recomputes the attention weights p from the saved
+// log-sum-exp and returns the gradients of q, k and v. With
+// dp = dout . v_j and D = dout . out, the score gradient is p*(dp - D).
+// The query gradient is accumulated per query and the key and value
+// gradients per key, so neither needs synchronization.
+func attentionBackward[T float32 | float64](g attentionGeometry, gy, q, k, v, out []T, lse []float64) ([]T, []T, []T) {
+	delta := make([]float64, g.batch*g.l)
+	for row := range delta {
+		delta[row] = dot(gy[row*g.ev:(row+1)*g.ev], out[row*g.ev:(row+1)*g.ev])
+	}
+	// weight returns p and the score gradient of query i and key j, whose
+	// dropout scale is keep.
+	weight := func(b, i, j int, keep float64) (float64, float64) {
+		row := b*g.l + i
+		if math.IsInf(lse[row], 1) {
+			return 0, 0
+		}
+		s := g.score(b, i, j, dot(q[row*g.e:(row+1)*g.e], k[(b*g.s+j)*g.e:(b*g.s+j+1)*g.e]))
+		if math.IsInf(s, -1) {
+			return 0, 0
+		}
+		p := math.Exp(s - lse[row])
+		dp := dot(gy[row*g.ev:(row+1)*g.ev], v[(b*g.s+j)*g.ev:(b*g.s+j+1)*g.ev]) * keep
+		return p, p * (dp - delta[row]) * g.scale
+	}
+
+	gq := make([]T, len(q))
+	parallel.For(g.batch*g.l, g.s*(g.e+g.ev), func(start, end int) {
+		keep := make([]float64, attentionChunk)
+		src := rand.NewPCG(0, 0)
+		r := rand.New(src)
+		for row := start; row < end; row++ {
+			b, i := row/g.l, row%g.l
+			dst := gq[row*g.e : (row+1)*g.e]
+			for c0 := 0; c0 < g.keys(i); c0 += attentionChunk {
+				c1 := min(c0+attentionChunk, g.keys(i))
+				g.dropout(src, r, row, c0, keep[:c1-c0])
+				for j := c0; j < c1; j++ {
+					_, ds := weight(b, i, j, keep[j-c0])
+					if ds == 0 {
+						continue
+					}
+					for d, x := range k[(b*g.s+j)*g.e : (b*g.s+j+1)*g.e] {
+						dst[d] += T(ds * float64(x))
+					}
+				}
+			}
+		}
+	})
+
+	// The key and value gradients are accumulated per chunk of keys, so
+	// the dropout scales of a query are drawn once per chunk.
+	gk, gv := make([]T, len(k)), make([]T, len(v))
+	chunks := (g.s + attentionChunk - 1) / attentionChunk
+	parallel.For(g.batch*chunks, attentionChunk*g.l*(g.e+g.ev), func(start, end int) {
+		keep := make([]float64, attentionChunk)
+		src := rand.NewPCG(0, 0)
+		r := rand.New(src)
+		for unit := start; unit < end; unit++ {
+			b, c0 := unit/chunks, unit%chunks*attentionChunk
+			for i := 0; i < g.l; i++ {
+				c1 := min(c0+attentionChunk, g.keys(i))
+				if c1 <= c0 {
+					continue
+				}
+				row := b*g.l + i
+				g.dropout(src, r, row, c0, keep[:c1-c0])
+				for j := c0; j < c1; j++ {
+					col := b*g.s + j
+					dk, dv := gk[col*g.e:(col+1)*g.e], gv[col*g.ev:(col+1)*g.ev]
+					p, ds := weight(b, i, j, keep[j-c0])
+					if p *= keep[j-c0]; p != 0 {
+						for d, x := range gy[row*g.ev : (row+1)*g.ev] {
+							dv[d] += T(p * float64(x))
+						}
+					}
+					if ds != 0 {
+						for d, x := range q[row*g.e : (row+1)*g.e] {
+							dk[d] += T(ds * float64(x))
+						}
+					}
+				}
+			}
+		}
+	})
+	return gq, gk, gv
+}
@@ -0,0 +1,221 @@
+package functional
+
+import (
+	"fmt"
+
+	"gotorch/tensors"
+)
+
+// ConvOptions configures a convolution. Stride, Padding, Dilation and
+// OutputPadding hold either one value shared by every spatial dimension or
+// one value per dimension; nil selects the defaults of 1, 0, 1 and 0.
+type ConvOptions struct {
+	Stride        []int
+	Padding       []int
+	Same          bool // pad so the output keeps the spatial size of the input; requires a stride of 1
+	Dilation      []int
+	Groups        int   // defaults to 1
+	OutputPadding []int // extra size added to one side of the output of a transposed convolution
+}
+
+// Conv1d convolves an (n, c, l) or unbatched (c, l) input with a weight of
+// shape (out, c/groups, k). bias may be nil.
+func Conv1d(input, weight, bias *tensors.Tensor, opts ConvOptions) (*tensors.Tensor, error) {
+	return conv(input, weight, bias, opts, 1)
+}
+
+// Conv2d convolves an (n, c, h, w) or unbatched (c, h, w) input with a
+// weight of shape (out, c/groups, kh, kw). bias may be nil.
+func Conv2d(input, weight, bias *tensors.Tensor, opts ConvOptions) (*tensors.Tensor, error) {
+	return conv(input, weight, bias, opts, 2)
+}
+
+// Conv3d convolves an (n, c, d, h, w) or unbatched (c, d, h, w) input with
+// a weight of shape (out, c/groups, kd, kh, kw). bias may be nil.
+func Conv3d(input, weight, bias *tensors.Tensor, opts ConvOptions) (*tensors.Tensor, error) {
+	return conv(input, weight, bias, opts, 3)
+}
+
+// conv lowers a convolution over dims spatial dimensions to im2col and a
+// batched matrix product per group. Depthwise convolutions, where every
+// group holds a single input channel, use a direct kernel instead.
+func conv(input, weight, bias *tensors.Tensor, opts ConvOptions, dims int) (*tensors.Tensor, error) {
+	name := fmt.Sprintf("Conv%dd", dims)
+	x, batched, err := batchInput(input, dims, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(weight.Shape) != dims+2 {
+		return nil, fmt.Errorf("%s expects a %d-d weight, got shape %v", name, dims+2, weight.Shape)
+	}
+	groups := opts.groups()
+	c := x.Shape[1]
+	oc, cg := weight.Shape[0], weight.Shape[1]
+	if groups <= 0 || c != cg*groups || oc%groups != 0 {
+		return nil, fmt.Errorf("%s: input with %d channels does not match weight of shape %v and %d groups", name, c, weight.Shape, groups)
+	}
+	if err := checkBias(bias, oc, name); err != nil {
+		return nil, err
+	}
+
+	g, err := newConvGeometry(x.Shape[2:], weight.Shape[2:], opts, name)
+	if err != nil {
+		return nil, err
+	}
+	g.channels = c
+	if groups == c && cg == 1 {
+		y, err := depthwise(x, weight, g)
+		if err != nil {
+			return nil, err
+		}
+		if y, err = addChannelBias(y, bias, dims); err != nil {
+			return nil, err
+		}
+		return unbatchOutput(y, batched)
+	}
+	y, err := convGEMM(x, weight, g, groups)
+	if err != nil {
+		return nil, err
+	}
+	if y, err = addChannelBias(y, bias, dims); err != nil {
+		return nil, err
+	}
+	return unbatchOutput(y, batched)
+}
+
+// convGEMM convolves the batched input x with weight through im2col
+// columns and a batched matrix product per group.
+func convGEMM(x, weight *tensors.Tensor, g *convGeometry, groups int) (*tensors.Tensor, error) {
+	dims := len(x.Shape) - 2
+	n, oc, cg := x.Shape[0], weight.Shape[0], weight.Shape[1]
+	cols, err := im2col(x, g)
+	if err != nil {
+		return nil, err
+	}
+	k, l := g.kernelSize(), g.outSize()
+	if cols, err = tensors.Reshape(cols, []int{n, groups, cg * k, l}); err != nil {
+		return nil, err
+	}
+	w, err := tensors.Reshape(weight, []int{groups, oc / groups, cg * k})
+	if err != nil {
+		return nil, err
+	}
+	y, err := tensors.MatMul(w, cols)
+	if err != nil {
+		return nil, err
+	}
+	return tensors.Reshape(y, append([]int{n, oc}, g.out[3-dims:]...))
+}
+
+func (o ConvOptions) groups() int {
+	if o.Groups == 0 {
+		return 1
+	}
+	return o.Groups
+}
+
+// newConvGeometry resolves the options of a convolution over the spatial
+// input size in with the given kernel size.
+func newConvGeometry(in, kernel []int, opts ConvOptions, name string) (*convGeometry, error) {
+	dims := len(in)
+	stride, err := expandInts(opts.Stride, dims, 1, "stride", name)
+	if err != nil {
+		return nil, err
+	}
+	dilation, err := expandInts(opts.Dilation, dims, 1, "dilation", name)
+	if err != nil {
+		return nil, err
+	}
+	padding, err := expandInts(opts.Padding, dims, 0, "padding", name)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &convGeometry{channels: 1}
+	for i := range g.in {
+		g.in[i], g.kernel[i], g.out[i], g.stride[i], g.dilation[i] = 1, 1, 1, 1, 1
+	}
+	off := 3 - dims
+	for i := 0; i < dims; i++ {
+		if stride[i] <= 0 || dilation[i] <= 0 || padding[i] < 0 {
+			return nil, fmt.Errorf("%s requires positive stride and dilation and non-negative padding", name)
+		}
+		span := dilation[i] * (kernel[i] - 1)
+		before, after := padding[i], padding[i]
+		if opts.Same {
+			if stride[i] != 1 {
+				return nil, fmt.Errorf("%s: same padding requires a stride of 1", name)
+			}
+			before, after = span/2, span-span/2
+		}
+		out := (in[i]+before+after-span-1)/stride[i] + 1
+		if out <= 0 {
+			return nil, fmt.Errorf("%s: kernel of size %v is larger than the padded input of size %v", name, kernel, in)
+		}
+		g.in[off+i], g.kernel[off+i], g.out[off+i] = in[i], kernel[i], out
+		g.stride[off+i], g.dilation[off+i], g.pad[off+i] = stride[i], dilation[i], before
+	}
+	return g, nil
+}
+
+// expandInts broadcasts a per-dimension option to dims values.
+func expandInts(values []int, dims, def int, option, name string) ([]int, error) {
+	switch len(values) {
+	case 0:
+		values = []int{def}
+		fallthrough
+	case 1:
+		out := make([]int, dims)
+		for i := range out {
+			out[i] = values[0]
+		}
+		return out, nil
+	case dims:
+		return values, nil
+	}
+	return nil, fmt.Errorf("%s expects 1 or %d values for %s, got %d", name, dims, option, len(values))
+}
+
+// batchInput adds a batch dimension to an unbatched input of a layer over
+// dims spatial dimensions and reports whether the input was batched.
+func batchInput(input *tensors.Tensor, dims int, name string) (*tensors.Tensor, bool, error) {
+	switch len(input.Shape) {
+	case dims + 2:
+		return input, true, nil
+	case dims + 1:
+		x, err := tensors.Reshape(input, append([]int{1}, input.Shape...))
+		return x, false, err
+	}
+	return nil, false, fmt.Errorf("%s expects a %d-d or %d-d input, got shape %v", name, dims+1, dims+2, input.Shape)
+}
+
+// unbatchOutput removes the batch dimension added by batchInput.
+func unbatchOutput(y *tensors.Tensor, batched bool) (*tensors.Tensor, error) {
+	if batched {
+		return y, nil
+	}
+	return tensors.Reshape(y, append([]int{}, y.Shape[1:]...))
+}
+
+func checkBias(bias *tensors.Tensor, channels int, name string) error {
+	if bias != nil && (len(bias.Shape) != 1 || bias.Shape[0] != channels) {
+		return fmt.Errorf("%s bias of shape %v does not match %d output channels", name, bias.Shape, channels)
+	}
+	return nil
+}
+
+// addChannelBias adds a per-channel bias to an (n, c, spatial...) tensor.
+func addChannelBias(y, bias *tensors.Tensor, dims int) (*tensors.Tensor, error) {
+	if bias == nil {
+		return y, nil
+	}
+	shape := []int{bias.Shape[0]}
+	for i := 0; i < dims; i++ {
+		shape = append(shape, 1)
+	}
+	b, err := tensors.Reshape(bias, shape)
+	if err != nil {
+		return nil, err
+	}
+	return tensors.Add(y, b)
+}
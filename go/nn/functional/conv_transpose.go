@@ -0,0 +1,133 @@
+package functional
+
+import (
+	"fmt"
+
+	"gotorch/tensors"
+)
+
+// ConvTranspose1d applies a transposed 1-d convolution to an (n, c, l) or
+// unbatched (c, l) input with a weight of shape (c, out/groups, k). bias
+// may be nil.
+func ConvTranspose1d(input, weight, bias *tensors.Tensor, opts ConvOptions) (*tensors.Tensor, error) {
+	return convTranspose(input, weight, bias, opts, 1)
+}
+
+// ConvTranspose2d applies a transposed 2-d convolution to an (n, c, h, w)
+// or unbatched (c, h, w) input with a weight of shape
+// (c, out/groups, kh, kw). bias may be nil.
+func ConvTranspose2d(input, weight, bias *tensors.Tensor, opts ConvOptions) (*tensors.Tensor, error) {
+	return convTranspose(input, weight, bias, opts, 2)
+}
+
+// ConvTranspose3d applies a transposed 3-d convolution to an
+// (n, c, d, h, w) or unbatched (c, d, h, w) input with a weight of shape
+// (c, out/groups, kd, kh, kw). bias may be nil.
+func ConvTranspose3d(input, weight, bias *tensors.Tensor, opts ConvOptions) (*tensors.Tensor, error) {
+	return convTranspose(input, weight, bias, opts, 3)
+}
+
+// convTranspose computes the gradient of a convolution with respect to its
+// input: every input position scatters weight^T x into the output windows,
+// which is a batched matrix product per group followed by col2im.
+func convTranspose(input, weight, bias *tensors.Tensor, opts ConvOptions, dims int) (*tensors.Tensor, error) {
+	name := fmt.Sprintf("ConvTranspose%dd", dims)
+	if opts.Same {
+		return nil, fmt.Errorf("%s does not support same padding", name)
+	}
+	x, batched, err := batchInput(input, dims, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(weight.Shape) != dims+2 {
+		return nil, fmt.Errorf("%s expects a %d-d weight, got shape %v", name, dims+2, weight.Shape)
+	}
+	groups := opts.groups()
+	n, c := x.Shape[0], x.Shape[1]
+	ocg := weight.Shape[1]
+	oc := ocg * groups
+	if groups <= 0 || weight.Shape[0] != c || c%groups != 0 {
+		return nil, fmt.Errorf("%s: input with %d channels does not match weight of shape %v and %d groups", name, c, weight.Shape, groups)
+	}
+	if err := checkBias(bias, oc, name); err != nil {
+		return nil, err
+	}
+
+	kernel := weight.Shape[2:]
+	in := x.Shape[2:]
+	outSize, err := transposedSize(in, kernel, opts, name)
+	if err != nil {
+		return nil, err
+	}
+	g, err := newConvGeometry(outSize, kernel, ConvOptions{Stride: opts.Stride, Padding: opts.Padding, Dilation: opts.Dilation}, name)
+	if err != nil {
+		return nil, err
+	}
+	g.channels = oc
+	// Output padding can leave room for one more window than the input
+	// has positions; those windows simply receive nothing.
+	copy(g.out[3-dims:], in)
+
+	cg, k, l := c/groups, g.kernelSize(), g.outSize()
+	xs, err := tensors.Reshape(x, []int{n, groups, cg, l})
+	if err != nil {
+		return nil, err
+	}
+	w, err := tensors.Reshape(weight, []int{groups, cg, ocg * k})
+	if err != nil {
+		return nil, err
+	}
+	if w, err = tensors.Adjoint(w); err != nil {
+		return nil, err
+	}
+	cols, err := tensors.MatMul(w, xs)
+	if err != nil {
+		return nil, err
+	}
+	if cols, err = tensors.Reshape(cols, []int{n, oc * k, l}); err != nil {
+		return nil, err
+	}
+	y, err := col2im(cols, g, append([]int{n, oc}, outSize...))
+	if err != nil {
+		return nil, err
+	}
+	if y, err = addChannelBias(y, bias, dims); err != nil {
+		return nil, err
+	}
+	return unbatchOutput(y, batched)
+}
+
+// transposedSize returns the spatial output size of a transposed
+// convolution: (in-1)*stride - 2*padding + dilation*(kernel-1) +
+// output_padding + 1.
+func transposedSize(in, kernel []int, opts ConvOptions, name string) ([]int, error) {
+	dims := len(in)
+	stride, err := expandInts(opts.Stride, dims, 1, "stride", name)
+	if err != nil {
+		return nil, err
+	}
+	padding, err := expandInts(opts.Padding, dims, 0, "padding", name)
+	if err != nil {
+		return nil, err
+	}
+	dilation, err := expandInts(opts.Dilation, dims, 1, "dilation", name)
+	if err != nil {
+		return nil, err
+	}
+	outputPadding, err := expandInts(opts.OutputPadding, dims, 0, "output padding", name)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]int, dims)
+	for i := range out {
+		if outputPadding[i] < 0 || outputPadding[i] >= max(stride[i], dilation[i]) {
+			return nil, fmt.Errorf("%s: output padding must be smaller than either stride or dilation", name)
+		}
+		out[i] = (in[i]-1)*stride[i] - 2*padding[i] + dilation[i]*(kernel[i]-1) + outputPadding[i] + 1
+		if out[i] <= 0 {
+			return nil, fmt.Errorf("%s: padding leaves an empty output for input of size %v", name, in)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,115 @@
+package functional
+
+import (
+	"math"
+	"testing"
+
+	"gotorch/tensors"
+)
+
+// The expected values follow PyTorch's definition of conv_transpose1d and
+// conv_transpose2d: input position i of channel c adds x*weight[c, o, k] to
+// output position i*stride - padding + k*dilation of channel
+// group*out/groups + o. Inputs are multiples of 1/2 so every value is
+// exact.
+func TestConvTranspose(t *testing.T) {
+	tests := []struct {
+		name                 string
+		conv                 func(input, weight, bias *tensors.Tensor, opts ConvOptions) (*tensors.Tensor, error)
+		inShape, weightShape []int
+		outShape             []int
+		opts                 ConvOptions
+		want, gx, gw, gb     []float64
+	}{
+		{
+			name:        "1d",
+			conv:        ConvTranspose1d,
+			inShape:     []int{2, 4, 3},
+			weightShape: []int{4, 2, 3},
+			outShape:    []int{2, 4, 8},
+			opts:        ConvOptions{Stride: []int{2}, Padding: []int{1}, Dilation: []int{2}, OutputPadding: []int{1}, Groups: 2},
+			want: []float64{-0.25, 0.75, -0.25, -4.5, -0.25, 1.25, -0.25, 1.25, 0, 0, 0, -1.75, 0, 2, 0, -2, 0.25, -2.25, 0.25, 2.5, 0.25, -2, 0.25, 1, -0.25, 1.25, -0.25, 2, -0.25, -3, -0.25, 0,
+				-0.25, 2, -0.25, 1, -0.25, -2.5, -0.25, 0.5, 0, 1.75, 0, -1.25, 0, 0.5, 0, 0.5, 0.25, 0.5, 0.25, 0.75, 0.25, 0, 0.25, 1.5, -0.25, -2.5, -0.25, 1.5, -0.25, -0.5, -0.25, -0.5},
+			gx: []float64{1, 2, 1.75, -1.25, -4.5, -4, 3.25, -3.5, -4, -2.75, -5.25, 1.75, -0.5, 4.75, 0, 2, -1, 1.75, 1, 2, 3.75, 3, 1, -5.5},
+			gw: []float64{1.25, 0.75, 1.25, -1.25, 0.5, 3.25, -0.75, -0.25, -0.75, 2.5, -2.25, -2.75, -0.25, 0.25, 0, 2, -3, 1.25, -0.75, -1, -1, -1.75, 3.5, 3.5},
+			gb: []float64{1, -3, 2, -2},
+		},
+		{
+			name:        "2d",
+			conv:        ConvTranspose2d,
+			inShape:     []int{1, 2, 2, 3},
+			weightShape: []int{2, 2, 2, 3},
+			outShape:    []int{1, 4, 5, 5},
+			opts:        ConvOptions{Stride: []int{2, 1}, Padding: []int{0, 1}, Dilation: []int{1, 2}, OutputPadding: []int{1, 0}, Groups: 2},
+			want: []float64{-0.25, -1.75, -0.25, -1.75, -0.25, -0.25, -1.75, -0.25, 1.75, -0.25, -1, 1.25, -0.25, -1, 0.5, -0.5, -0.25, 0.25, -0.75, -0.75, -0.25, -0.25, -0.25, -0.25, -0.25,
+				0, 2, 0, -1.5, 0, 0, 2, 0, -1.5, 0, 0.25, 0.25, -0.75, 1.5, 0, 0.75, -1.25, -0.25, 0, 0.5, 0, 0, 0, 0, 0,
+				-0.75, 0.75, 0.75, 0, -1.25, 0.25, 0.25, 1.75, -0.5, -0.25, 1.25, 0.5, -0.25, -0.5, 1.75, 0.25, 1, -1.25, 0, 0.75, 0.25, 0.25, 0.25, 0.25, 0.25,
+				0.75, -0.75, -1.25, 0.25, 0.25, -1.75, 0.5, -0.25, -0.25, 1.25, -1.25, -0.75, 0.75, 0, -0.75, 1.25, -0.25, -0.25, 0.5, -1.75, -0.25, -0.25, -0.25, -0.25, -0.25},
+			gx: []float64{1, 11.5, -2.5, 6.75, -0.75, 1.75, -3.5, 5.25, 1.5, 5.75, -0.5, -2},
+			gw: []float64{2.25, 2.75, 0.25, -2.75, 1.5, 1.25, 2, 3.25, 0.75, -3, -2.5, -0.5, 2.25, 3, -3.5, 2.75, 3, 2.75, 2.5, 3, 3, 3, 3, 2.5},
+			gb: []float64{-2, -1, 0, 1},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			x := testTensor(t, tc.inShape, 7, 5, 2, true)
+			w := testTensor(t, tc.weightShape, 3, 7, 3, true)
+			oc := tc.outShape[1]
+			biasValues := make([]float64, oc)
+			for o := range biasValues {
+				biasValues[o] = float64(o%3-1) / 4
+			}
+			b, err := tensors.NewTensor(biasValues, []int{oc}, "float64", true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			y, err := tc.conv(x, w, b, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalInts(y.Shape, tc.outShape) {
+				t.Fatalf("output shape %v, want %v", y.Shape, tc.outShape)
+			}
+			expectClose(t, "output", y, tc.want)
+
+			if err := y.Backward(testTensor(t, tc.outShape, 5, 9, 4, false)); err != nil {
+				t.Fatal(err)
+			}
+			expectClose(t, "input grad", x.Grad, tc.gx)
+			expectClose(t, "weight grad", w.Grad, tc.gw)
+			expectClose(t, "bias grad", b.Grad, tc.gb)
+		})
+	}
+}
+
+// testTensor returns a float64 tensor of shape whose i-th value is
+// ((i*a)%m - off)/2.
+func testTensor(t *testing.T, shape []int, a, m, off int, requiresGrad bool) *tensors.Tensor {
+	t.Helper()
+	values := make([]float64, shapeSize(shape))
+	for i := range values {
+		values[i] = float64((i*a)%m-off) / 2
+	}
+	x, err := tensors.NewTensor(values, shape, "float64", requiresGrad, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return x
+}
+
+func expectClose(t *testing.T, name string, got *tensors.Tensor, want []float64) {
+	t.Helper()
+	if got == nil {
+		t.Fatalf("%s is nil", name)
+	}
+	values := got.Data.([]float64)
+	if len(values) != len(want) {
+		t.Fatalf("%s has %d values, want %d", name, len(values), len(want))
+	}
+	for i := range want {
+		if math.Abs(values[i]-want[i]) > 1e-9 {
+			t.Fatalf("%s[%d] = %v, want %v", name, i, values[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package functional
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gotorch/internal/parallel"
+	"gotorch/tensors"
+)
+
+// CTCLoss returns the connectionist temporal classification loss, the
+// negative log-likelihood of every target over all alignments of it to
+// the input, reduced by reduction. logProbs has shape (t, n, c), or
+// (t, c) unbatched, and holds log-probabilities over c classes including
+// blank. targets holds the int64 labels, either padded as (n, s) or all
+// sequences concatenated as (sum(targetLengths)). inputLengths and
+// targetLengths give the lengths of every sequence. The mean divides every
+// loss by its target length before averaging over the batch. With
+// zeroInfinity, losses of targets that cannot be aligned, which are
+// infinite, and their gradients are zeroed; otherwise their gradients are
+// zero and their loss infinite.
+//
+// The forward and backward variables are computed in log space, and the
+// gradient is that of the loss with respect to logProbs itself.
+func CTCLoss(logProbs, targets *tensors.Tensor, inputLengths, targetLengths []int, blank int, reduction string, zeroInfinity bool) (*tensors.Tensor, error) {
+	const name = "CTCLoss"
+	if err := checkReduction(reduction, name); err != nil {
+		return nil, err
+	}
+	batched := len(logProbs.Shape) == 3
+	if !batched && len(logProbs.Shape) != 2 {
+		return nil, fmt.Errorf("%s expects log-probabilities of shape (t, n, c) or (t, c), got %v", name, logProbs.Shape)
+	}
+	steps, n, classes := logProbs.Shape[0], 1, logProbs.Shape[len(logProbs.Shape)-1]
+	if batched {
+		n = logProbs.Shape[1]
+	}
+	if len(inputLengths) != n || len(targetLengths) != n {
+		return nil, fmt.Errorf("%s expects %d input and target lengths, got %d and %d", name, n, len(inputLengths), len(targetLengths))
+	}
+	if blank < 0 || blank >= classes {
+		return nil, fmt.Errorf("%s: blank %d is out of bounds for %d classes", name, blank, classes)
+	}
+	labels, ok := targets.Data.([]int64)
+	if !ok {
+		return nil, fmt.Errorf("%s expects int64 targets", name)
+	}
+	sequences, err := ctcTargets(labels, targets.Shape, targetLengths, batched)
+	if err != nil {
+		return nil, err
+	}
+	for b, seq := range sequences {
+		if inputLengths[b] < 0 || inputLengths[b] > steps {
+			return nil, fmt.Errorf("%s: input length %d is out of range for %d steps", name, inputLengths[b], steps)
+		}
+		for _, y := range seq {
+			if y < 0 || y >= int64(classes) || y == int64(blank) {
+				return nil, fmt.Errorf("%s: target %d is out of bounds or blank", name, y)
+			}
+		}
+	}
+	lp, err := toFloat64(logProbs)
+	if err != nil {
+		return nil, err
+	}
+
+	losses, grad := make([]float64, n), make([]float64, len(lp))
+	parallel.For(n, steps*classes*4, func(start, end int) {
+		for b := start; b < end; b++ {
+			c := ctcSequence{lp: lp, n: n, classes: classes, b: b, steps: inputLengths[b], blank: int64(blank), labels: sequences[b]}
+			losses[b] = c.loss(grad)
+			if math.IsInf(losses[b], 1) && zeroInfinity {
+				losses[b] = 0
+			}
+			if reduction == ReductionMean {
+				scale := 1 / float64(max(targetLengths[b], 1))
+				losses[b] *= scale
+				for t := 0; t < c.steps; t++ {
+					for k := 0; k < classes; k++ {
+						grad[c.at(t, k)] *= scale
+					}
+				}
+			}
+		}
+	})
+	shape := []int{n}
+	if !batched {
+		shape = []int{}
+	}
+	return reduceLoss(name, losses, shape, logProbs.Dtype, reduction, float64(n), []lossGrad{{
+		input: logProbs, d: grad, at: func(e int) int { return e / classes % n },
+	}})
+}
+
+// ctcTargets splits padded or concatenated targets into one label
+// sequence per batch element.
+func ctcTargets(labels []int64, shape, lengths []int, batched bool) ([][]int64, error) {
+	padded := len(shape) == 2
+	if padded && (!batched || shape[0] != len(lengths)) || len(shape) > 2 || len(shape) == 0 {
+		return nil, fmt.Errorf("CTCLoss: targets of shape %v do not match %d sequences", shape, len(lengths))
+	}
+	sequences := make([][]int64, len(lengths))
+	offset := 0
+	for b, length := range lengths {
+		if length < 0 {
+			return nil, errors.New("CTCLoss: target lengths must be non-negative")
+		}
+		start := offset
+		if padded {
+			if length > shape[1] {
+				return nil, fmt.Errorf("CTCLoss: target length %d exceeds the padded length %d", length, shape[1])
+			}
+			start = b * shape[1]
+		}
+		if start+length > len(labels) {
+			return nil, errors.New("CTCLoss: target lengths exceed the targets")
+		}
+		sequences[b] = labels[start : start+length]
+		offset += length
+	}
+	return sequences, nil
+}
+
+// ctcSequence is the alignment problem of batch element b.
+type ctcSequence struct {
+	lp         []float64 // (t, n, c) log-probabilities
+	n, classes int
+	b, steps   int
+	blank      int64
+	labels     []int64
+}
+
+func (c ctcSequence) at(t, k int) int {
+	return (t*c.n+c.b)*c.classes + k
+}
+
+// label returns the class of state s of the target extended with blanks
+// around and between its labels.
+func (c ctcSequence) label(s int) int64 {
+	if s%2 == 0 {
+		return c.blank
+	}
+	return c.labels[s/2]
+}
+
+// skips reports whether state s can be reached from s-2, which holds
+// unless s is a blank or repeats the previous label.
+func (c ctcSequence) skips(s int) bool {
+	return s >= 2 && s%2 == 1 && c.labels[s/2] != c.labels[s/2-1]
+}
+
+// loss returns the negative log-likelihood of the sequence and writes its
+// gradient with respect to the log-probabilities into grad.
+func (c ctcSequence) loss(grad []float64) float64 {
+	states := 2*len(c.labels) + 1
+	if c.steps == 0 {
+		if len(c.labels) == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	inf := math.Inf(-1)
+	alpha, beta := make([]float64, c.steps*states), make([]float64, c.steps*states)
+	for i := range alpha {
+		alpha[i], beta[i] = inf, inf
+	}
+	emit := func(t, s int) float64 { return c.lp[c.at(t, int(c.label(s)))] }
+
+	alpha[0] = emit(0, 0)
+	if states > 1 {
+		alpha[1] = emit(0, 1)
+	}
+	for t := 1; t < c.steps; t++ {
+		prev, cur := alpha[(t-1)*states:t*states], alpha[t*states:(t+1)*states]
+		for s := range cur {
+			a := prev[s]
+			if s >= 1 {
+				a = logAddExp(a, prev[s-1])
+			}
+			if c.skips(s) {
+				a = logAddExp(a, prev[s-2])
+			}
+			cur[s] = a + emit(t, s)
+		}
+	}
+	last := (c.steps - 1) * states
+	beta[last+states-1] = emit(c.steps-1, states-1)
+	if states > 1 {
+		beta[last+states-2] = emit(c.steps-1, states-2)
+	}
+	for t := c.steps - 2; t >= 0; t-- {
+		next, cur := beta[(t+1)*states:(t+2)*states], beta[t*states:(t+1)*states]
+		for s := range cur {
+			v := next[s]
+			if s+1 < states {
+				v = logAddExp(v, next[s+1])
+			}
+			if s+2 < states && c.skips(s+2) {
+				v = logAddExp(v, next[s+2])
+			}
+			cur[s] = v + emit(t, s)
+		}
+	}
+
+	logLikelihood := alpha[last+states-1]
+	if states > 1 {
+		logLikelihood = logAddExp(logLikelihood, alpha[last+states-2])
+	}
+	if math.IsInf(logLikelihood, -1) {
+		return math.Inf(1)
+	}
+	// Every path through state s at step t contains the emission of
+	// label(s) at t once, so the derivative of the likelihood with respect
+	// to that log-probability is alpha*beta divided by the emission.
+	occupancy := make([]float64, c.classes)
+	for t := 0; t < c.steps; t++ {
+		for k := range occupancy {
+			occupancy[k] = inf
+		}
+		for s := 0; s < states; s++ {
+			k := c.label(s)
+			occupancy[k] = logAddExp(occupancy[k], alpha[t*states+s]+beta[t*states+s])
+		}
+		for k, o := range occupancy {
+			if !math.IsInf(o, -1) {
+				grad[c.at(t, k)] = -math.Exp(o - c.lp[c.at(t, k)] - logLikelihood)
+			}
+		}
+	}
+	return -logLikelihood
+}
+
+// logAddExp returns log(exp(a) + exp(b)).
+func logAddExp(a, b float64) float64 {
+	if math.IsInf(a, -1) {
+		return b
+	}
+	if math.IsInf(b, -1) {
+		return a
+	}
+	if a < b {
+		a, b = b, a
+	}
+	return a + math.Log1p(math.Exp(b-a))
+}
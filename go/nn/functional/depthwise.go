@@ -0,0 +1,112 @@
+package functional
+
+import (
+	"errors"
+
+	"gotorch/internal/parallel"
+	"gotorch/tensors"
+)
+
+// depthwise convolves every input channel of the (n, c, spatial...) tensor
+// x with its own filters. weight has shape (c*multiplier, 1, kernel...) and
+// output channel o reads input channel o/multiplier. It works directly on
+// the input planes rather than building im2col columns.
+func depthwise(x, weight *tensors.Tensor, g *convGeometry) (*tensors.Tensor, error) {
+	if x.Dtype != weight.Dtype {
+		return nil, errors.New("tensors must have the same data type")
+	}
+	n, oc := x.Shape[0], weight.Shape[0]
+	dims := len(x.Shape) - 2
+
+	var data interface{}
+	switch v := x.Data.(type) {
+	case []float32:
+		data = depthwiseKernel(v, weight.Data.([]float32), n, oc, g)
+	case []float64:
+		data = depthwiseKernel(v, weight.Data.([]float64), n, oc, g)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	out := &tensors.Tensor{
+		Shape: append([]int{n, oc}, g.out[3-dims:]...),
+		Data:  data,
+		Dtype: x.Dtype,
+	}
+	px, pw := x.Detach(), weight.Detach()
+	err := tensors.Record(out, "DepthwiseConvBackward", []*tensors.Tensor{x, weight}, []*tensors.Tensor{px, pw}, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		gx := &tensors.Tensor{Shape: append([]int{}, px.Shape...), Dtype: px.Dtype}
+		gw := &tensors.Tensor{Shape: append([]int{}, pw.Shape...), Dtype: pw.Dtype}
+		switch gy := grad.Data.(type) {
+		case []float32:
+			gx.Data, gw.Data = depthwiseBackward(gy, px.Data.([]float32), pw.Data.([]float32), n, oc, g)
+		case []float64:
+			gx.Data, gw.Data = depthwiseBackward(gy, px.Data.([]float64), pw.Data.([]float64), n, oc, g)
+		default:
+			return nil, errors.New("unsupported data type")
+		}
+		return []*tensors.Tensor{gx, gw}, nil
+	})
+	return out, err
+}
+
+func depthwiseKernel[T float32 | float64](x, w []T, n, oc int, g *convGeometry) []T {
+	plane, k, l := g.inSize(), g.kernelSize(), g.outSize()
+	mult := oc / g.channels
+	y := make([]T, n*oc*l)
+	parallel.For(n*oc, k*l, func(start, end int) {
+		for p := start; p < end; p++ {
+			b, o := p/oc, p%oc
+			src := x[(b*g.channels+o/mult)*plane:]
+			filter := w[o*k : (o+1)*k]
+			dst := y[p*l : (p+1)*l]
+			g.window(func(row, pos, idx int) {
+				if idx >= 0 {
+					dst[pos] += src[idx] * filter[row]
+				}
+			})
+		}
+	})
+	return y
+}
+
+func depthwiseBackward[T float32 | float64](gy, x, w []T, n, oc int, g *convGeometry) ([]T, []T) {
+	plane, k, l := g.inSize(), g.kernelSize(), g.outSize()
+	c := g.channels
+	mult := oc / c
+
+	// Each input plane only receives gradient from its own output channels,
+	// so the planes can be filled independently.
+	gx := make([]T, n*c*plane)
+	parallel.For(n*c, mult*k*l, func(start, end int) {
+		for p := start; p < end; p++ {
+			b, ch := p/c, p%c
+			dst := gx[p*plane : (p+1)*plane]
+			for o := ch * mult; o < (ch+1)*mult; o++ {
+				src := gy[(b*oc+o)*l : (b*oc+o+1)*l]
+				filter := w[o*k : (o+1)*k]
+				g.window(func(row, pos, idx int) {
+					if idx >= 0 {
+						dst[idx] += src[pos] * filter[row]
+					}
+				})
+			}
+		}
+	})
+
+	gw := make([]T, oc*k)
+	parallel.For(oc, n*k*l, func(start, end int) {
+		for o := start; o < end; o++ {
+			dst := gw[o*k : (o+1)*k]
+			for b := 0; b < n; b++ {
+				src := gy[(b*oc+o)*l : (b*oc+o+1)*l]
+				in := x[(b*c+o/mult)*plane:]
+				g.window(func(row, pos, idx int) {
+					if idx >= 0 {
+						dst[row] += src[pos] * in[idx]
+					}
+				})
+			}
+		}
+	})
+	return gx, gw
+}
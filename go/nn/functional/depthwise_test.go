@@ -0,0 +1,51 @@
+package functional
+
+import (
+	"testing"
+
+	"gotorch/tensors"
+)
+
+// The depthwise kernel must agree with the im2col path it replaces, in the
+// output and in both gradients.
+func TestDepthwiseMatchesIm2col(t *testing.T) {
+	tests := []struct {
+		name                 string
+		inShape, weightShape []int
+		opts                 ConvOptions
+	}{
+		{"1d", []int{2, 3, 9}, []int{6, 1, 3}, ConvOptions{Stride: []int{2}, Padding: []int{2}, Dilation: []int{2}}},
+		{"2d", []int{2, 3, 5, 6}, []int{6, 1, 3, 2}, ConvOptions{Stride: []int{2, 1}, Padding: []int{1, 1}, Dilation: []int{1, 2}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			run := func(conv func(x, w *tensors.Tensor, g *convGeometry) (*tensors.Tensor, error)) (y, gx, gw *tensors.Tensor) {
+				x := testTensor(t, tc.inShape, 7, 11, 5, true)
+				w := testTensor(t, tc.weightShape, 5, 7, 3, true)
+				g, err := newConvGeometry(x.Shape[2:], w.Shape[2:], tc.opts, "conv")
+				if err != nil {
+					t.Fatal(err)
+				}
+				g.channels = x.Shape[1]
+				if y, err = conv(x, w, g); err != nil {
+					t.Fatal(err)
+				}
+				if err := y.Backward(testTensor(t, y.Shape, 3, 13, 6, false)); err != nil {
+					t.Fatal(err)
+				}
+				return y, x.Grad, w.Grad
+			}
+			want, wantGx, wantGw := run(func(x, w *tensors.Tensor, g *convGeometry) (*tensors.Tensor, error) {
+				return convGEMM(x, w, g, x.Shape[1])
+			})
+			got, gx, gw := run(depthwise)
+
+			if !equalInts(got.Shape, want.Shape) {
+				t.Fatalf("output shape %v, want %v", got.Shape, want.Shape)
+			}
+			expectClose(t, "output", got, want.Data.([]float64))
+			expectClose(t, "input grad", gx, wantGx.Data.([]float64))
+			expectClose(t, "weight grad", gw, wantGw.Data.([]float64))
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package functional
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"gotorch/internal/parallel"
+	"gotorch/tensors"
+)
+
+// Dropout zeroes every element of input with probability p and scales the
+// others by 1/(1-p) when training is set; otherwise it returns input. The
+// mask is drawn from generator, or the default generator when nil, one
+// element at a time in order, so a seed always gives the same mask.
+func Dropout(input *tensors.Tensor, p float64, training bool, generator *tensors.Generator) (*tensors.Tensor, error) {
+	if err := checkDropout(p, "Dropout"); err != nil {
+		return nil, err
+	}
+	if !training || p == 0 {
+		return input, nil
+	}
+	scale := dropoutMask(shapeSize(input.Shape), p, generator)
+	return maskedAffine(input, scale, nil, 1, "Dropout")
+}
+
+// Dropout2d zeroes every channel of an (n, c, h, w) or (c, h, w) input
+// with probability p and scales the others by 1/(1-p) when training is
+// set; otherwise it returns input.
+func Dropout2d(input *tensors.Tensor, p float64, training bool, generator *tensors.Generator) (*tensors.Tensor, error) {
+	if err := checkDropout(p, "Dropout2d"); err != nil {
+		return nil, err
+	}
+	if len(input.Shape) != 3 && len(input.Shape) != 4 {
+		return nil, fmt.Errorf("Dropout2d expects an input with 3 or 4 dimensions, got shape %v", input.Shape)
+	}
+	if !training || p == 0 {
+		return input, nil
+	}
+	plane := shapeSize(input.Shape[len(input.Shape)-2:])
+	scale := dropoutMask(shapeSize(input.Shape)/plane, p, generator)
+	return maskedAffine(input, scale, nil, plane, "Dropout2d")
+}
+
+// AlphaDropout drops elements of input with probability p when training
+// is set, keeping the mean and variance of a zero-mean, unit-variance input
+// as SELU activations expect. Dropped elements are set to the negative
+// saturation value of SELU, then an affine transformation restores the
+// statistics.
+func AlphaDropout(input *tensors.Tensor, p float64, training bool, generator *tensors.Generator) (*tensors.Tensor, error) {
+	if err := checkDropout(p, "AlphaDropout"); err != nil {
+		return nil, err
+	}
+	if !training || p == 0 {
+		return input, nil
+	}
+	n := shapeSize(input.Shape)
+	if p == 1 {
+		return maskedAffine(input, make([]float64, n), make([]float64, n), 1, "AlphaDropout")
+	}
+	alpha := -seluScale * seluAlpha
+	a := 1 / math.Sqrt((1-p)*(1+p*alpha*alpha))
+	b := -a * alpha * p
+	scale := dropoutMask(n, p, generator)
+	shift := make([]float64, n)
+	for i, s := range scale {
+		if s == 0 {
+			shift[i] = a*alpha + b
+		} else {
+			scale[i], shift[i] = a, b
+		}
+	}
+	return maskedAffine(input, scale, shift, 1, "AlphaDropout")
+}
+
+func checkDropout(p float64, name string) error {
+	if !(p >= 0 && p <= 1) {
+		return fmt.Errorf("%s probability has to be between 0 and 1, got %v", name, p)
+	}
+	return nil
+}
+
+// dropoutMask draws n keep decisions in order, returning 1/(1-p) for kept
+// entries and 0 for dropped ones. Drawing the whole mask up front keeps it
+// independent of how the kernel is parallelized.
+func dropoutMask(n int, p float64, generator *tensors.Generator) []float64 {
+	if generator == nil {
+		generator = tensors.DefaultGenerator
+	}
+	mask := make([]float64, n)
+	if p == 1 {
+		return mask
+	}
+	keep := 1 / (1 - p)
+	generator.Fill(mask, func(r *rand.Rand) float64 {
+		if r.Float64() < p {
+			return 0
+		}
+		return keep
+	})
+	return mask
+}
+
+// maskedAffine returns x*scale + shift, where element i uses entry
+// i/block of scale and shift. A nil shift is zero.
+func maskedAffine(input *tensors.Tensor, scale, shift []float64, block int, name string) (*tensors.Tensor, error) {
+	var data interface{}
+	switch x := input.Data.(type) {
+	case []float32:
+		data = maskedAffineKernel(x, scale, shift, block)
+	case []float64:
+		data = maskedAffineKernel(x, scale, shift, block)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	out := &tensors.Tensor{Shape: append([]int{}, input.Shape...), Data: data, Dtype: input.Dtype}
+	err := tensors.Record(out, name+"Backward", []*tensors.Tensor{input}, nil, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		gx := &tensors.Tensor{Shape: append([]int{}, grad.Shape...), Dtype: grad.Dtype}
+		switch gy := grad.Data.(type) {
+		case []float32:
+			gx.Data = maskedAffineKernel(gy, scale, nil, block)
+		case []float64:
+			gx.Data = maskedAffineKernel(gy, scale, nil, block)
+		default:
+			return nil, errors.New("unsupported data type")
+		}
+		return []*tensors.Tensor{gx}, nil
+	})
+	return out, err
+}
+
+func maskedAffineKernel[T float32 | float64](x []T, scale, shift []float64, block int) []T {
+	y := make([]T, len(x))
+	parallel.For(len(scale), block, func(start, end int) {
+		for j := start; j < end; j++ {
+			s, b := T(scale[j]), T(0)
+			if shift != nil {
+				b = T(shift[j])
+			}
+			for i := j * block; i < (j+1)*block; i++ {
+				y[i] = x[i]*s + b
+			}
+		}
+	})
+	return y
+}
@@ -0,0 +1,461 @@
+package functional
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gotorch/internal/parallel"
+	"gotorch/tensors"
+)
+
+// EmbeddingOptions configures Embedding and EmbeddingBag.
+type EmbeddingOptions struct {
+	// PaddingIdx, when set, names a row of the weight that receives no
+	// gradient; EmbeddingBag also leaves it out of its bags. Negative
+	// values count from the end.
+	PaddingIdx *int
+	// MaxNorm, when positive, renormalizes in place every looked up row
+	// whose NormType-norm exceeds it. NormType defaults to 2.
+	MaxNorm  float64
+	NormType float64
+	// ScaleGradByFreq divides the gradient of every row by the number of
+	// times it occurs in the input.
+	ScaleGradByFreq bool
+	// Sparse requests a sparse weight gradient. The tensors package has no
+	// sparse layout, so setting it is an error.
+	Sparse bool
+}
+
+// EmbeddingBag modes.
+const (
+	BagSum  = "sum"
+	BagMean = "mean"
+	BagMax  = "max"
+)
+
+// EmbeddingBagOptions configures EmbeddingBag.
+type EmbeddingBagOptions struct {
+	EmbeddingOptions
+	// Mode is BagSum, BagMean or BagMax; empty means BagMean.
+	Mode string
+	// IncludeLastOffset treats the last offset as the end of the last bag
+	// rather than the start of another one.
+	IncludeLastOffset bool
+}
+
+// Embedding looks up the rows of the (num_embeddings, dim) weight named by
+// the int64 indices in input and returns a tensor of shape
+// input.Shape + (dim).
+func Embedding(input, weight *tensors.Tensor, opts EmbeddingOptions) (*tensors.Tensor, error) {
+	indices, padding, err := embeddingIndices(input, weight, opts, "Embedding")
+	if err != nil {
+		return nil, err
+	}
+	if err := renormRows(weight, indices, opts); err != nil {
+		return nil, err
+	}
+	dim := weight.Shape[1]
+
+	var data interface{}
+	switch w := weight.Data.(type) {
+	case []float32:
+		data = gatherRows(w, indices, dim)
+	case []float64:
+		data = gatherRows(w, indices, dim)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	out := &tensors.Tensor{Shape: append(append([]int{}, input.Shape...), dim), Data: data, Dtype: weight.Dtype}
+	scale := rowScale(indices, padding, weight.Shape[0], opts.ScaleGradByFreq)
+	wShape := append([]int{}, weight.Shape...)
+	err = tensors.Record(out, "EmbeddingBackward", []*tensors.Tensor{weight}, nil, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		gw := &tensors.Tensor{Shape: wShape, Dtype: grad.Dtype}
+		switch gy := grad.Data.(type) {
+		case []float32:
+			gw.Data = scatterRows(gy, indices, scale, wShape[0], dim)
+		case []float64:
+			gw.Data = scatterRows(gy, indices, scale, wShape[0], dim)
+		default:
+			return nil, errors.New("unsupported data type")
+		}
+		return []*tensors.Tensor{gw}, nil
+	})
+	return out, err
+}
+
+// EmbeddingBag reduces bags of embedding rows without materializing the
+// looked up rows. A 2-d input of shape (b, n) holds b bags of n indices and
+// takes no offsets. A 1-d input holds the concatenated bags, and offsets
+// holds the int64 start of every bag. perSampleWeights, which may be nil,
+// has the shape of input and scales every row before a BagSum reduction.
+// Empty bags give zeros. The result has shape (bags, dim).
+func EmbeddingBag(input, weight, offsets, perSampleWeights *tensors.Tensor, opts EmbeddingBagOptions) (*tensors.Tensor, error) {
+	mode := opts.Mode
+	if mode == "" {
+		mode = BagMean
+	}
+	if mode != BagSum && mode != BagMean && mode != BagMax {
+		return nil, fmt.Errorf("EmbeddingBag: unknown mode %q", opts.Mode)
+	}
+	indices, padding, err := embeddingIndices(input, weight, opts.EmbeddingOptions, "EmbeddingBag")
+	if err != nil {
+		return nil, err
+	}
+	bounds, err := bagBounds(input, offsets, len(indices), opts.IncludeLastOffset)
+	if err != nil {
+		return nil, err
+	}
+	if perSampleWeights != nil {
+		if mode != BagSum {
+			return nil, errors.New("EmbeddingBag supports per-sample weights only in sum mode")
+		}
+		if !equalInts(perSampleWeights.Shape, input.Shape) || perSampleWeights.Dtype != weight.Dtype {
+			return nil, fmt.Errorf("EmbeddingBag expects per-sample weights of shape %v and the weight data type, got %v", input.Shape, perSampleWeights.Shape)
+		}
+	}
+	if err := renormRows(weight, indices, opts.EmbeddingOptions); err != nil {
+		return nil, err
+	}
+	dim := weight.Shape[1]
+	bag := bagReducer{indices: indices, bounds: bounds, padding: padding, mode: mode, dim: dim}
+
+	var (
+		data   interface{}
+		argmax []int
+	)
+	switch w := weight.Data.(type) {
+	case []float32:
+		data, argmax = bagForward(bag, w, floatsOf[float32](perSampleWeights))
+	case []float64:
+		data, argmax = bagForward(bag, w, floatsOf[float64](perSampleWeights))
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	out := &tensors.Tensor{Shape: []int{len(bounds) - 1, dim}, Data: data, Dtype: weight.Dtype}
+
+	inputs := []*tensors.Tensor{weight}
+	var (
+		saved  []*tensors.Tensor
+		pw, pp *tensors.Tensor
+	)
+	if perSampleWeights != nil {
+		pw, pp = weight.Detach(), perSampleWeights.Detach()
+		inputs, saved = append(inputs, perSampleWeights), append(saved, pw, pp)
+	}
+	scale := rowScale(indices, padding, weight.Shape[0], opts.ScaleGradByFreq)
+	wShape := append([]int{}, weight.Shape...)
+	sShape := append([]int{}, input.Shape...)
+	err = tensors.Record(out, "EmbeddingBagBackward", inputs, saved, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		var gw, gs interface{}
+		switch gy := grad.Data.(type) {
+		case []float32:
+			gw, gs = bagBackward(bag, gy, argmax, scale, wShape[0], floatsOf[float32](pw), floatsOf[float32](pp))
+		case []float64:
+			gw, gs = bagBackward(bag, gy, argmax, scale, wShape[0], floatsOf[float64](pw), floatsOf[float64](pp))
+		default:
+			return nil, errors.New("unsupported data type")
+		}
+		grads := []*tensors.Tensor{{Shape: wShape, Data: gw, Dtype: grad.Dtype}}
+		if pp != nil {
+			grads = append(grads, &tensors.Tensor{Shape: sShape, Data: gs, Dtype: grad.Dtype})
+		}
+		return grads, nil
+	})
+	return out, err
+}
+
+// embeddingIndices validates the int64 indices of input against weight and
+// returns them with the resolved padding row, or -1 without one.
+func embeddingIndices(input, weight *tensors.Tensor, opts EmbeddingOptions, name string) ([]int64, int, error) {
+	if opts.Sparse {
+		return nil, 0, fmt.Errorf("%s does not support sparse gradients", name)
+	}
+	if len(weight.Shape) != 2 {
+		return nil, 0, fmt.Errorf("%s expects a 2-d weight, got shape %v", name, weight.Shape)
+	}
+	indices, ok := input.Data.([]int64)
+	if !ok {
+		return nil, 0, fmt.Errorf("%s expects int64 indices, got %s", name, input.Dtype.DataType())
+	}
+	rows := weight.Shape[0]
+	for _, idx := range indices {
+		if idx < 0 || idx >= int64(rows) {
+			return nil, 0, fmt.Errorf("%s: index %d is out of range for %d embeddings", name, idx, rows)
+		}
+	}
+	padding := -1
+	if opts.PaddingIdx != nil {
+		padding = *opts.PaddingIdx
+		if padding < 0 {
+			padding += rows
+		}
+		if padding < 0 || padding >= rows {
+			return nil, 0, fmt.Errorf("%s: padding index %d is out of range for %d embeddings", name, *opts.PaddingIdx, rows)
+		}
+	}
+	return indices, padding, nil
+}
+
+// renormRows rescales in place the rows named by indices whose norm
+// exceeds opts.MaxNorm, like torch.embedding_renorm_.
+func renormRows(weight *tensors.Tensor, indices []int64, opts EmbeddingOptions) error {
+	if opts.MaxNorm <= 0 {
+		return nil
+	}
+	p := opts.NormType
+	if p == 0 {
+		p = 2
+	}
+	rows, dim := weight.Shape[0], weight.Shape[1]
+	values, err := toFloat64(weight)
+	if err != nil {
+		return err
+	}
+	scale := make([]float64, rows)
+	for i := range scale {
+		scale[i] = 1
+	}
+	changed := false
+	for _, idx := range indices {
+		row := values[int(idx)*dim : int(idx+1)*dim]
+		var norm float64
+		for _, v := range row {
+			norm += math.Pow(math.Abs(v), p)
+		}
+		if norm = math.Pow(norm, 1/p); norm > opts.MaxNorm {
+			scale[idx], changed = opts.MaxNorm/(norm+1e-7), true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	factor, err := fromFloat64(scale, []int{rows, 1}, weight.Dtype)
+	if err != nil {
+		return err
+	}
+	tensors.NoGrad(func() { err = weight.MulInPlace(factor) })
+	return err
+}
+
+// rowScale returns the factor applied to the gradient of every weight row:
+// zero for the padding row and, with scaleByFreq, one over the number of
+// occurrences otherwise.
+func rowScale(indices []int64, padding, rows int, scaleByFreq bool) []float64 {
+	scale := make([]float64, rows)
+	if scaleByFreq {
+		for _, idx := range indices {
+			scale[idx]++
+		}
+		for i, c := range scale {
+			if c > 0 {
+				scale[i] = 1 / c
+			}
+		}
+	} else {
+		for i := range scale {
+			scale[i] = 1
+		}
+	}
+	if padding >= 0 {
+		scale[padding] = 0
+	}
+	return scale
+}
+
+func gatherRows[T float32 | float64](w []T, indices []int64, dim int) []T {
+	out := make([]T, len(indices)*dim)
+	parallel.For(len(indices), dim, func(start, end int) {
+		for i := start; i < end; i++ {
+			copy(out[i*dim:(i+1)*dim], w[int(indices[i])*dim:int(indices[i]+1)*dim])
+		}
+	})
+	return out
+}
+
+// scatterRows accumulates the gradient of every looked up row into its
+// weight row. Work is split over the embedding dimension so that every
+// element is summed in input order, whatever the number of goroutines.
+func scatterRows[T float32 | float64](gy []T, indices []int64, scale []float64, rows, dim int) []T {
+	gw := make([]T, rows*dim)
+	parallel.For(dim, len(indices), func(start, end int) {
+		for i, idx := range indices {
+			s := T(scale[idx])
+			if s == 0 {
+				continue
+			}
+			dst, src := gw[int(idx)*dim:], gy[i*dim:]
+			for d := start; d < end; d++ {
+				dst[d] += src[d] * s
+			}
+		}
+	})
+	return gw
+}
+
+// bagBounds returns the start of every bag followed by the end of the
+// last one.
+func bagBounds(input, offsets *tensors.Tensor, n int, includeLast bool) ([]int, error) {
+	switch len(input.Shape) {
+	case 2:
+		if offsets != nil {
+			return nil, errors.New("EmbeddingBag takes no offsets with a 2-d input")
+		}
+		bags, size := input.Shape[0], input.Shape[1]
+		bounds := make([]int, bags+1)
+		for i := range bounds {
+			bounds[i] = i * size
+		}
+		return bounds, nil
+	case 1:
+		if offsets == nil || len(offsets.Shape) != 1 {
+			return nil, errors.New("EmbeddingBag expects 1-d offsets with a 1-d input")
+		}
+		starts, ok := offsets.Data.([]int64)
+		if !ok {
+			return nil, fmt.Errorf("EmbeddingBag expects int64 offsets, got %s", offsets.Dtype.DataType())
+		}
+		if len(starts) == 0 || starts[0] != 0 {
+			return nil, errors.New("EmbeddingBag expects offsets to start at 0")
+		}
+		bounds := make([]int, 0, len(starts)+1)
+		for i, s := range starts {
+			if s > int64(n) || (i > 0 && s < starts[i-1]) {
+				return nil, fmt.Errorf("EmbeddingBag expects non-decreasing offsets within the %d indices", n)
+			}
+			bounds = append(bounds, int(s))
+		}
+		if !includeLast {
+			bounds = append(bounds, n)
+		} else if len(bounds) == 1 {
+			return nil, errors.New("EmbeddingBag needs at least 2 offsets with IncludeLastOffset")
+		}
+		return bounds, nil
+	}
+	return nil, fmt.Errorf("EmbeddingBag expects a 1-d or 2-d input, got shape %v", input.Shape)
+}
+
+type bagReducer struct {
+	indices []int64
+	bounds  []int
+	padding int
+	mode    string
+	dim     int
+}
+
+func (b bagReducer) bags() int { return len(b.bounds) - 1 }
+
+// count returns the number of non-padding entries of bag i.
+func (b bagReducer) count(i int) int {
+	n := 0
+	for _, idx := range b.indices[b.bounds[i]:b.bounds[i+1]] {
+		if int(idx) != b.padding {
+			n++
+		}
+	}
+	return n
+}
+
+// bagForward reduces every bag, returning for max mode the position in
+// indices of the row that won every output element, or -1 for empty bags.
+func bagForward[T float32 | float64](b bagReducer, w, perSample []T) ([]T, []int) {
+	dim := b.dim
+	out := make([]T, b.bags()*dim)
+	var argmax []int
+	if b.mode == BagMax {
+		argmax = make([]int, len(out))
+		for i := range argmax {
+			argmax[i] = -1
+		}
+	}
+	parallel.For(b.bags(), (b.bounds[len(b.bounds)-1]/max(b.bags(), 1)+1)*dim, func(start, end int) {
+		for i := start; i < end; i++ {
+			dst := out[i*dim : (i+1)*dim]
+			for k := b.bounds[i]; k < b.bounds[i+1]; k++ {
+				idx := int(b.indices[k])
+				if idx == b.padding {
+					continue
+				}
+				row := w[idx*dim : (idx+1)*dim]
+				if b.mode == BagMax {
+					arg := argmax[i*dim : (i+1)*dim]
+					for d, v := range row {
+						if arg[d] < 0 || v > dst[d] {
+							dst[d], arg[d] = v, k
+						}
+					}
+					continue
+				}
+				s := T(1)
+				if perSample != nil {
+					s = perSample[k]
+				}
+				for d, v := range row {
+					dst[d] += v * s
+				}
+			}
+			if n := b.count(i); b.mode == BagMean && n > 0 {
+				for d := range dst {
+					dst[d] /= T(n)
+				}
+			}
+		}
+	})
+	return out, argmax
+}
+
+// bagBackward returns the gradients of the weight and of the per-sample
+// weights. The weight gradient is split over the embedding dimension so
+// the sums are taken in a fixed order.
+func bagBackward[T float32 | float64](b bagReducer, gy []T, argmax []int, scale []float64, rows int, w, perSample []T) ([]T, []T) {
+	dim := b.dim
+	gw := make([]T, rows*dim)
+	parallel.For(dim, len(b.indices), func(start, end int) {
+		for i := 0; i < b.bags(); i++ {
+			n := T(1)
+			if b.mode == BagMean {
+				if c := b.count(i); c > 0 {
+					n = T(c)
+				}
+			}
+			src := gy[i*dim:]
+			for k := b.bounds[i]; k < b.bounds[i+1]; k++ {
+				idx := int(b.indices[k])
+				s := T(scale[idx])
+				if s == 0 {
+					continue
+				}
+				if perSample != nil {
+					s *= perSample[k]
+				}
+				dst := gw[idx*dim:]
+				for d := start; d < end; d++ {
+					if argmax != nil && argmax[i*dim+d] != k {
+						continue
+					}
+					dst[d] += src[d] * s / n
+				}
+			}
+		}
+	})
+
+	var gs []T
+	if perSample != nil {
+		gs = make([]T, len(b.indices))
+		for i := 0; i < b.bags(); i++ {
+			src := gy[i*dim : (i+1)*dim]
+			for k := b.bounds[i]; k < b.bounds[i+1]; k++ {
+				idx := int(b.indices[k])
+				if idx == b.padding {
+					continue
+				}
+				var sum T
+				for d, v := range w[idx*dim : (idx+1)*dim] {
+					sum += v * src[d]
+				}
+				gs[k] = sum
+			}
+		}
+	}
+	return gw, gs
+}
@@ -0,0 +1,145 @@
+package functional
+
+import (
+	"errors"
+
+	"gotorch/internal/parallel"
+	"gotorch/tensors"
+)
+
+// convGeometry describes a sliding window over up to three spatial
+// dimensions. Convolutions with fewer dimensions leave the leading entries
+// at size 1 with a unit kernel, stride and dilation.
+type convGeometry struct {
+	channels int
+	in       [3]int
+	kernel   [3]int
+	out      [3]int
+	stride   [3]int
+	dilation [3]int
+	pad      [3]int // padding before each dimension
+}
+
+func (g *convGeometry) inSize() int     { return g.in[0] * g.in[1] * g.in[2] }
+func (g *convGeometry) kernelSize() int { return g.kernel[0] * g.kernel[1] * g.kernel[2] }
+func (g *convGeometry) outSize() int    { return g.out[0] * g.out[1] * g.out[2] }
+
+// im2col unfolds the (n, channels, in...) tensor x into columns of shape
+// (n, channels*kernel, out), one column per output position.
+func im2col(x *tensors.Tensor, g *convGeometry) (*tensors.Tensor, error) {
+	n := x.Shape[0]
+	var data interface{}
+	switch v := x.Data.(type) {
+	case []float32:
+		data = im2colKernel(v, n, g)
+	case []float64:
+		data = im2colKernel(v, n, g)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	out := &tensors.Tensor{
+		Shape: []int{n, g.channels * g.kernelSize(), g.outSize()},
+		Data:  data,
+		Dtype: x.Dtype,
+	}
+	inShape := append([]int{}, x.Shape...)
+	err := tensors.RecordJVP(out, "Im2colBackward", []*tensors.Tensor{x}, nil, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		gx, err := col2im(grad, g, inShape)
+		return []*tensors.Tensor{gx}, err
+	}, func(tangents []*tensors.Tensor) (*tensors.Tensor, error) {
+		// im2col is linear, so its tangent is the tangent unfolded.
+		return im2col(tangents[0], g)
+	})
+	return out, err
+}
+
+// col2im folds columns of shape (n, channels*kernel, out) back into a
+// tensor of shape inShape, summing the values that overlap.
+func col2im(cols *tensors.Tensor, g *convGeometry, inShape []int) (*tensors.Tensor, error) {
+	n := cols.Shape[0]
+	var data interface{}
+	switch v := cols.Data.(type) {
+	case []float32:
+		data = col2imKernel(v, n, g)
+	case []float64:
+		data = col2imKernel(v, n, g)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	out := &tensors.Tensor{
+		Shape: append([]int{}, inShape...),
+		Data:  data,
+		Dtype: cols.Dtype,
+	}
+	err := tensors.RecordJVP(out, "Col2imBackward", []*tensors.Tensor{cols}, nil, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		gc, err := im2col(grad, g)
+		return []*tensors.Tensor{gc}, err
+	}, func(tangents []*tensors.Tensor) (*tensors.Tensor, error) {
+		return col2im(tangents[0], g, inShape)
+	})
+	return out, err
+}
+
+// window calls fn for every (kernel offset, output position) pair with the
+// flat column row, the flat output position and the flat input index, or
+// -1 when the window reads padding.
+func (g *convGeometry) window(fn func(row, pos, idx int)) {
+	row := 0
+	for kd := 0; kd < g.kernel[0]; kd++ {
+		for kh := 0; kh < g.kernel[1]; kh++ {
+			for kw := 0; kw < g.kernel[2]; kw++ {
+				pos := 0
+				for od := 0; od < g.out[0]; od++ {
+					id := od*g.stride[0] - g.pad[0] + kd*g.dilation[0]
+					for oh := 0; oh < g.out[1]; oh++ {
+						ih := oh*g.stride[1] - g.pad[1] + kh*g.dilation[1]
+						for ow := 0; ow < g.out[2]; ow++ {
+							iw := ow*g.stride[2] - g.pad[2] + kw*g.dilation[2]
+							idx := -1
+							if id >= 0 && id < g.in[0] && ih >= 0 && ih < g.in[1] && iw >= 0 && iw < g.in[2] {
+								idx = (id*g.in[1]+ih)*g.in[2] + iw
+							}
+							fn(row, pos, idx)
+							pos++
+						}
+					}
+				}
+				row++
+			}
+		}
+	}
+}
+
+func im2colKernel[T float32 | float64](x []T, n int, g *convGeometry) []T {
+	plane, k, l := g.inSize(), g.kernelSize(), g.outSize()
+	cols := make([]T, n*g.channels*k*l)
+	parallel.For(n*g.channels, k*l, func(start, end int) {
+		for p := start; p < end; p++ {
+			src := x[p*plane : (p+1)*plane]
+			dst := cols[p*k*l : (p+1)*k*l]
+			g.window(func(row, pos, idx int) {
+				if idx >= 0 {
+					dst[row*l+pos] = src[idx]
+				}
+			})
+		}
+	})
+	return cols
+}
+
+func col2imKernel[T float32 | float64](cols []T, n int, g *convGeometry) []T {
+	plane, k, l := g.inSize(), g.kernelSize(), g.outSize()
+	x := make([]T, n*g.channels*plane)
+	parallel.For(n*g.channels, k*l, func(start, end int) {
+		for p := start; p < end; p++ {
+			src := cols[p*k*l : (p+1)*k*l]
+			dst := x[p*plane : (p+1)*plane]
+			g.window(func(row, pos, idx int) {
+				if idx >= 0 {
+					dst[idx] += src[row*l+pos]
+				}
+			})
+		}
+	})
+	return x
+}
@@ -0,0 +1,40 @@
+// Package functional provides the stateless forms of the nn layers. Each
+// function takes its weights as arguments and records its backward on the
+// tensors autograd graph.
+package functional
+
+import (
+	"errors"
+
+	"gotorch/tensors"
+)
+
+// scalar returns a 0-d tensor holding v with the data type of like.
+func scalar(like *tensors.Tensor, v float64) (*tensors.Tensor, error) {
+	var data interface{}
+	switch like.Data.(type) {
+	case []float32:
+		data = []float32{float32(v)}
+	case []float64:
+		data = []float64{v}
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	return &tensors.Tensor{Shape: []int{}, Data: data, Dtype: like.Dtype}, nil
+}
+
+// leading returns the product of all but the last n dimensions of shape.
+func leading(shape []int, n int) int {
+	size := 1
+	for _, d := range shape[:len(shape)-n] {
+		size *= d
+	}
+	return size
+}
+
+// withLast returns shape with its last dimension replaced by last.
+func withLast(shape []int, last int) []int {
+	out := append([]int{}, shape...)
+	out[len(out)-1] = last
+	return out
+}
@@ -0,0 +1,106 @@
+package functional
+
+import (
+	"fmt"
+
+	"gotorch/tensors"
+)
+
+// Linear returns input @ weight^T + bias over the last dimension of input,
+// which may have any number of leading batch dimensions. weight has shape
+// (out, in) and bias, which may be nil, shape (out).
+func Linear(input, weight, bias *tensors.Tensor) (*tensors.Tensor, error) {
+	if len(input.Shape) == 0 || len(weight.Shape) != 2 {
+		return nil, fmt.Errorf("Linear expects an input with at least 1 dimension and a 2-d weight, got %v and %v", input.Shape, weight.Shape)
+	}
+	out, in := weight.Shape[0], weight.Shape[1]
+	if input.Shape[len(input.Shape)-1] != in {
+		return nil, fmt.Errorf("Linear input of shape %v does not match weight of shape %v", input.Shape, weight.Shape)
+	}
+	if bias != nil && (len(bias.Shape) != 1 || bias.Shape[0] != out) {
+		return nil, fmt.Errorf("Linear bias of shape %v does not match %d output features", bias.Shape, out)
+	}
+
+	x, err := tensors.Reshape(input, []int{leading(input.Shape, 1), in})
+	if err != nil {
+		return nil, err
+	}
+	wt, err := tensors.Adjoint(weight)
+	if err != nil {
+		return nil, err
+	}
+	beta := 1.0
+	if bias == nil {
+		beta = 0
+		if bias, err = scalar(input, 0); err != nil {
+			return nil, err
+		}
+	}
+	y, err := tensors.Addmm(bias, x, wt, beta, 1)
+	if err != nil {
+		return nil, err
+	}
+	return tensors.Reshape(y, withLast(input.Shape, out))
+}
+
+// Bilinear returns x1^T A x2 + b for every output feature, where input1
+// has shape (..., in1), input2 shape (..., in2) with the same leading
+// dimensions, weight shape (out, in1, in2) and bias, which may be nil,
+// shape (out).
+func Bilinear(input1, input2, weight, bias *tensors.Tensor) (*tensors.Tensor, error) {
+	if len(input1.Shape) == 0 || len(input1.Shape) != len(input2.Shape) || len(weight.Shape) != 3 {
+		return nil, fmt.Errorf("Bilinear expects inputs of equal rank and a 3-d weight, got %v, %v and %v", input1.Shape, input2.Shape, weight.Shape)
+	}
+	out, in1, in2 := weight.Shape[0], weight.Shape[1], weight.Shape[2]
+	if input1.Shape[len(input1.Shape)-1] != in1 || input2.Shape[len(input2.Shape)-1] != in2 {
+		return nil, fmt.Errorf("Bilinear inputs of shape %v and %v do not match weight of shape %v", input1.Shape, input2.Shape, weight.Shape)
+	}
+	for i := 0; i < len(input1.Shape)-1; i++ {
+		if input1.Shape[i] != input2.Shape[i] {
+			return nil, fmt.Errorf("Bilinear inputs of shape %v and %v have different batch dimensions", input1.Shape, input2.Shape)
+		}
+	}
+	if bias != nil && (len(bias.Shape) != 1 || bias.Shape[0] != out) {
+		return nil, fmt.Errorf("Bilinear bias of shape %v does not match %d output features", bias.Shape, out)
+	}
+
+	n := leading(input1.Shape, 1)
+	x1, err := tensors.Reshape(input1, []int{n, in1})
+	if err != nil {
+		return nil, err
+	}
+	x2, err := tensors.Reshape(input2, []int{n, in2, 1})
+	if err != nil {
+		return nil, err
+	}
+
+	// (n, in1) @ (in1, out*in2) gives x1^T A for every output feature, which
+	// a batched product with x2 then contracts over in2.
+	w, err := tensors.Permute(weight, []int{1, 0, 2})
+	if err != nil {
+		return nil, err
+	}
+	if w, err = tensors.Reshape(w, []int{in1, out * in2}); err != nil {
+		return nil, err
+	}
+	left, err := tensors.MatMul(x1, w)
+	if err != nil {
+		return nil, err
+	}
+	if left, err = tensors.Reshape(left, []int{n, out, in2}); err != nil {
+		return nil, err
+	}
+	y, err := tensors.MatMul(left, x2)
+	if err != nil {
+		return nil, err
+	}
+	if y, err = tensors.Reshape(y, []int{n, out}); err != nil {
+		return nil, err
+	}
+	if bias != nil {
+		if y, err = tensors.Add(y, bias); err != nil {
+			return nil, err
+		}
+	}
+	return tensors.Reshape(y, withLast(input1.Shape, out))
+}
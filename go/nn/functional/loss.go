@@ -0,0 +1,696 @@
+package functional
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gotorch/internal/parallel"
+	"gotorch/tensors"
+)
+
+// Reductions of the per-element losses.
+const (
+	ReductionNone = "none" // keep one loss per element
+	ReductionMean = "mean" // average the losses
+	ReductionSum  = "sum"  // add the losses up
+	// ReductionBatchMean sums the losses and divides by the batch size. It
+	// is accepted by KLDiv only.
+	ReductionBatchMean = "batchmean"
+)
+
+// MSELoss returns the squared error (x - y)^2 between input and target,
+// which must have the same shape, reduced by reduction.
+func MSELoss(input, target *tensors.Tensor, reduction string) (*tensors.Tensor, error) {
+	return pointwiseLoss("MSELoss", input, target, nil, reduction, func(_ int, x, y float64) (float64, float64, float64) {
+		d := x - y
+		return d * d, 2 * d, -2 * d
+	})
+}
+
+// L1Loss returns the absolute error |x - y| between input and target,
+// reduced by reduction.
+func L1Loss(input, target *tensors.Tensor, reduction string) (*tensors.Tensor, error) {
+	return pointwiseLoss("L1Loss", input, target, nil, reduction, func(_ int, x, y float64) (float64, float64, float64) {
+		d := x - y
+		return math.Abs(d), sign(d), -sign(d)
+	})
+}
+
+// SmoothL1Loss returns 0.5*d^2/beta where the error d = x - y is below
+// beta in magnitude and |d| - 0.5*beta elsewhere, reduced by reduction.
+// A beta of 0 gives L1Loss. PyTorch defaults beta to 1.
+func SmoothL1Loss(input, target *tensors.Tensor, reduction string, beta float64) (*tensors.Tensor, error) {
+	if beta < 0 {
+		return nil, fmt.Errorf("SmoothL1Loss: beta must be non-negative, got %v", beta)
+	}
+	return pointwiseLoss("SmoothL1Loss", input, target, nil, reduction, func(_ int, x, y float64) (float64, float64, float64) {
+		d := x - y
+		if math.Abs(d) < beta {
+			return 0.5 * d * d / beta, d / beta, -d / beta
+		}
+		return math.Abs(d) - 0.5*beta, sign(d), -sign(d)
+	})
+}
+
+// HuberLoss returns 0.5*d^2 where the error d = x - y is below delta in
+// magnitude and delta*(|d| - 0.5*delta) elsewhere, reduced by reduction.
+// PyTorch defaults delta to 1.
+func HuberLoss(input, target *tensors.Tensor, reduction string, delta float64) (*tensors.Tensor, error) {
+	if delta <= 0 {
+		return nil, fmt.Errorf("HuberLoss: delta must be positive, got %v", delta)
+	}
+	return pointwiseLoss("HuberLoss", input, target, nil, reduction, func(_ int, x, y float64) (float64, float64, float64) {
+		d := x - y
+		if math.Abs(d) < delta {
+			return 0.5 * d * d, d, -d
+		}
+		return delta * (math.Abs(d) - 0.5*delta), delta * sign(d), -delta * sign(d)
+	})
+}
+
+// BinaryCrossEntropy returns -w*(y*log(x) + (1-y)*log(1-x)) for
+// probabilities x in [0, 1], reduced by reduction. The logarithms are
+// clamped at -100 as in PyTorch. weight, which may be nil, broadcasts to
+// the input and is treated as a constant.
+func BinaryCrossEntropy(input, target, weight *tensors.Tensor, reduction string) (*tensors.Tensor, error) {
+	x, err := toFloat64(input)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range x {
+		if !(v >= 0 && v <= 1) {
+			return nil, errors.New("BinaryCrossEntropy: all elements of input should be between 0 and 1")
+		}
+	}
+	return pointwiseLoss("BinaryCrossEntropy", input, target, weight, reduction, func(_ int, x, y float64) (float64, float64, float64) {
+		logX, log1mX := math.Max(math.Log(x), -100), math.Max(math.Log1p(-x), -100)
+		return -(y*logX + (1-y)*log1mX), (x - y) / math.Max(x*(1-x), 1e-12), log1mX - logX
+	})
+}
+
+// BinaryCrossEntropyWithLogits returns BinaryCrossEntropy of sigmoid(x)
+// computed stably from the logits x, reduced by reduction. posWeight, which
+// may be nil, scales the loss of positive targets and broadcasts to the
+// input, typically as one weight per class along the last dimension.
+// weight and posWeight are treated as constants.
+func BinaryCrossEntropyWithLogits(input, target, weight, posWeight *tensors.Tensor, reduction string) (*tensors.Tensor, error) {
+	var pw []float64
+	if posWeight != nil {
+		var err error
+		if pw, err = broadcastConstant(posWeight, input.Shape, "BinaryCrossEntropyWithLogits"); err != nil {
+			return nil, err
+		}
+	}
+	return pointwiseLoss("BinaryCrossEntropyWithLogits", input, target, weight, reduction, func(i int, x, y float64) (float64, float64, float64) {
+		p := 1.0
+		if pw != nil {
+			p = pw[i]
+		}
+		// With c = 1 + (p-1)*y, the loss is (1-y)*x + c*log(1+exp(-x)).
+		c, sp := 1+(p-1)*y, softplus(-x)
+		return (1-y)*x + c*sp, (1 - y) - c*sigmoid(-x), -x + (p-1)*sp
+	})
+}
+
+// KLDiv returns the Kullback-Leibler divergence target*(log(target) - x)
+// of the log-probabilities x from target, reduced by reduction, which may
+// also be ReductionBatchMean to divide the sum by the size of the first
+// dimension. With logTarget the target holds log-probabilities too.
+func KLDiv(input, target *tensors.Tensor, reduction string, logTarget bool) (*tensors.Tensor, error) {
+	return pointwiseLoss("KLDiv", input, target, nil, reduction, func(_ int, x, t float64) (float64, float64, float64) {
+		if logTarget {
+			e := math.Exp(t)
+			return e * (t - x), -e, e * (t - x + 1)
+		}
+		if t <= 0 {
+			return 0, 0, 0
+		}
+		return t * (math.Log(t) - x), -t, math.Log(t) + 1 - x
+	})
+}
+
+// NLLLoss returns the negative log-likelihood -w[y]*x[y] of the class
+// indices in target under the log-probabilities in input, reduced by
+// reduction. input has shape (n, c, d1, ...), (n, c) or (c) and target,
+// of data type int64, the same shape without the class dimension. weight,
+// which may be nil, holds one constant weight per class; the mean divides
+// by the total weight of the targets. Targets equal to ignoreIndex add
+// nothing to the loss or the weight. PyTorch defaults ignoreIndex to -100.
+func NLLLoss(input, target, weight *tensors.Tensor, ignoreIndex int, reduction string) (*tensors.Tensor, error) {
+	return nllLoss("NLLLoss", input, target, weight, ignoreIndex, reduction, 0)
+}
+
+// CrossEntropy returns the cross entropy between the logits in input and
+// target, reduced by reduction. target holds either int64 class indices,
+// as in NLLLoss, or class probabilities of the shape of input, for which
+// ignoreIndex is unused and the mean is over all positions. With
+// labelSmoothing = e the target mixes a fraction e of the uniform
+// distribution into the classes. The class weights, which may be nil, are
+// treated as constants.
+func CrossEntropy(input, target, weight *tensors.Tensor, ignoreIndex int, reduction string, labelSmoothing float64) (*tensors.Tensor, error) {
+	if !(labelSmoothing >= 0 && labelSmoothing <= 1) {
+		return nil, fmt.Errorf("CrossEntropy: label_smoothing must be between 0 and 1, got %v", labelSmoothing)
+	}
+	classDim := 0
+	if len(input.Shape) >= 2 {
+		classDim = 1
+	}
+	logp, err := LogSoftmax(input, classDim)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := target.Data.([]int64); ok {
+		return nllLoss("CrossEntropy", logp, target, weight, ignoreIndex, reduction, labelSmoothing)
+	}
+	return softTargetLoss(logp, target, weight, reduction, labelSmoothing)
+}
+
+// MarginRankingLoss returns max(0, -y*(x1-x2) + margin) for inputs x1
+// and x2 and labels y of 1 or -1, all of the same shape, reduced by
+// reduction.
+func MarginRankingLoss(input1, input2, target *tensors.Tensor, margin float64, reduction string) (*tensors.Tensor, error) {
+	const name = "MarginRankingLoss"
+	if err := checkReduction(reduction, name); err != nil {
+		return nil, err
+	}
+	if !equalInts(input1.Shape, input2.Shape) || !equalInts(input1.Shape, target.Shape) {
+		return nil, fmt.Errorf("%s: inputs of shapes %v and %v do not match target of shape %v", name, input1.Shape, input2.Shape, target.Shape)
+	}
+	values, err := floatInputs(name, input1, input2, target)
+	if err != nil {
+		return nil, err
+	}
+	x1, x2, y := values[0], values[1], values[2]
+	losses, d1, d2, dy := make([]float64, len(y)), make([]float64, len(y)), make([]float64, len(y)), make([]float64, len(y))
+	for i := range y {
+		if l := -y[i]*(x1[i]-x2[i]) + margin; l > 0 {
+			losses[i], d1[i], d2[i], dy[i] = l, -y[i], y[i], -(x1[i] - x2[i])
+		}
+	}
+	same := func(e int) int { return e }
+	return reduceLoss(name, losses, target.Shape, target.Dtype, reduction, float64(len(y)), []lossGrad{
+		{input: input1, d: d1, at: same},
+		{input: input2, d: d2, at: same},
+		{input: target, d: dy, at: same},
+	})
+}
+
+// TripletMarginLoss returns max(d(a, p) - d(a, n) + margin, 0) for anchors
+// a, positives p and negatives n of shape (n, d) or (d), where d is the
+// p-norm distance ||x - y + eps|| along the last dimension, reduced by
+// reduction. With swap the distance to the negative is the smaller of
+// d(a, n) and d(p, n). PyTorch defaults margin to 1, p to 2 and eps to 1e-6.
+func TripletMarginLoss(anchor, positive, negative *tensors.Tensor, margin, p, eps float64, swap bool, reduction string) (*tensors.Tensor, error) {
+	const name = "TripletMarginLoss"
+	if err := checkReduction(reduction, name); err != nil {
+		return nil, err
+	}
+	if n := len(anchor.Shape); n != 1 && n != 2 {
+		return nil, fmt.Errorf("%s expects inputs with 1 or 2 dimensions, got shape %v", name, anchor.Shape)
+	}
+	if !equalInts(anchor.Shape, positive.Shape) || !equalInts(anchor.Shape, negative.Shape) {
+		return nil, fmt.Errorf("%s: anchor %v, positive %v and negative %v do not match", name, anchor.Shape, positive.Shape, negative.Shape)
+	}
+	if p <= 0 {
+		return nil, fmt.Errorf("%s: p must be positive, got %v", name, p)
+	}
+	values, err := floatInputs(name, anchor, positive, negative)
+	if err != nil {
+		return nil, err
+	}
+	a, pos, neg := values[0], values[1], values[2]
+	dim := anchor.Shape[len(anchor.Shape)-1]
+	rows := leading(anchor.Shape, 1)
+
+	losses := make([]float64, rows)
+	da, dp, dn := make([]float64, len(a)), make([]float64, len(a)), make([]float64, len(a))
+	parallel.For(rows, 3*dim*pointwiseCost, func(start, end int) {
+		for r := start; r < end; r++ {
+			row := func(v []float64) []float64 { return v[r*dim : (r+1)*dim] }
+			ap, gap := pairwiseDistance(row(a), row(pos), p, eps)
+			an, gan := pairwiseDistance(row(a), row(neg), p, eps)
+			swapped := false
+			if swap {
+				if pn, gpn := pairwiseDistance(row(pos), row(neg), p, eps); pn < an {
+					an, gan, swapped = pn, gpn, true
+				}
+			}
+			l := ap - an + margin
+			if l <= 0 {
+				continue
+			}
+			losses[r] = l
+			ga, gp, gn := row(da), row(dp), row(dn)
+			for i := range ga {
+				ga[i] += gap[i]
+				gp[i] -= gap[i]
+				if swapped {
+					gp[i] -= gan[i]
+				} else {
+					ga[i] -= gan[i]
+				}
+				gn[i] += gan[i]
+			}
+		}
+	})
+	row := func(e int) int { return e / dim }
+	return reduceLoss(name, losses, anchor.Shape[:len(anchor.Shape)-1], anchor.Dtype, reduction, float64(rows), []lossGrad{
+		{input: anchor, d: da, at: row},
+		{input: positive, d: dp, at: row},
+		{input: negative, d: dn, at: row},
+	})
+}
+
+// pairwiseDistance returns ||x - y + eps||_p and its gradient with respect
+// to x.
+func pairwiseDistance(x, y []float64, p, eps float64) (float64, []float64) {
+	var sum float64
+	for i := range x {
+		sum += math.Pow(math.Abs(x[i]-y[i]+eps), p)
+	}
+	norm := math.Pow(sum, 1/p)
+	grad := make([]float64, len(x))
+	if norm == 0 {
+		return 0, grad
+	}
+	for i := range x {
+		d := x[i] - y[i] + eps
+		grad[i] = sign(d) * math.Pow(math.Abs(d)/norm, p-1)
+	}
+	return norm, grad
+}
+
+// CosineEmbeddingLoss returns 1 - cos(x1, x2) for labels y of 1 and
+// max(0, cos(x1, x2) - margin) for labels of -1, with x1 and x2 of shape
+// (n, d) or (d) and y of shape (n) or (), reduced by reduction.
+func CosineEmbeddingLoss(input1, input2, target *tensors.Tensor, margin float64, reduction string) (*tensors.Tensor, error) {
+	const name = "CosineEmbeddingLoss"
+	const eps = 1e-12
+	if err := checkReduction(reduction, name); err != nil {
+		return nil, err
+	}
+	if n := len(input1.Shape); (n != 1 && n != 2) || !equalInts(input1.Shape, input2.Shape) || !equalInts(target.Shape, input1.Shape[:n-1]) {
+		return nil, fmt.Errorf("%s: inputs of shapes %v and %v do not match target of shape %v", name, input1.Shape, input2.Shape, target.Shape)
+	}
+	values, err := floatInputs(name, input1, input2, target)
+	if err != nil {
+		return nil, err
+	}
+	x1, x2, y := values[0], values[1], values[2]
+	dim := input1.Shape[len(input1.Shape)-1]
+
+	losses, d1, d2 := make([]float64, len(y)), make([]float64, len(x1)), make([]float64, len(x2))
+	for r, label := range y {
+		a, b := x1[r*dim:(r+1)*dim], x2[r*dim:(r+1)*dim]
+		s1, s2 := dot(a, a)+eps, dot(b, b)+eps
+		norm := math.Sqrt(s1 * s2)
+		cos := dot(a, b) / norm
+		var scale float64
+		switch {
+		case label == 1:
+			losses[r], scale = 1-cos, -1
+		case label == -1:
+			if cos > margin {
+				losses[r], scale = cos-margin, 1
+			}
+		default:
+			return nil, fmt.Errorf("%s expects targets of 1 or -1, got %v", name, label)
+		}
+		if scale == 0 {
+			continue
+		}
+		for i := range a {
+			d1[r*dim+i] = scale * (b[i]/norm - cos*a[i]/s1)
+			d2[r*dim+i] = scale * (a[i]/norm - cos*b[i]/s2)
+		}
+	}
+	row := func(e int) int { return e / dim }
+	return reduceLoss(name, losses, target.Shape, input1.Dtype, reduction, float64(len(y)), []lossGrad{
+		{input: input1, d: d1, at: row},
+		{input: input2, d: d2, at: row},
+	})
+}
+
+// MultiLabelSoftMarginLoss returns the binary cross entropy of the logits
+// in input against the 0 or 1 labels in target, averaged over the classes
+// of every sample, for inputs of shape (n, c) or (c), reduced by
+// reduction. weight, which may be nil, holds one constant weight per
+// class.
+func MultiLabelSoftMarginLoss(input, target, weight *tensors.Tensor, reduction string) (*tensors.Tensor, error) {
+	const name = "MultiLabelSoftMarginLoss"
+	if err := checkReduction(reduction, name); err != nil {
+		return nil, err
+	}
+	if n := len(input.Shape); (n != 1 && n != 2) || !equalInts(input.Shape, target.Shape) {
+		return nil, fmt.Errorf("%s: target of shape %v does not match input of shape %v", name, target.Shape, input.Shape)
+	}
+	values, err := floatInputs(name, input, target)
+	if err != nil {
+		return nil, err
+	}
+	x, y := values[0], values[1]
+	classes := input.Shape[len(input.Shape)-1]
+	w, err := classWeights(weight, classes, name)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := leading(input.Shape, 1)
+	losses, dx, dy := make([]float64, rows), make([]float64, len(x)), make([]float64, len(y))
+	for i := range x {
+		r, c := i/classes, i%classes
+		scale := w[c] / float64(classes)
+		// -(y*log(sigmoid(x)) + (1-y)*log(sigmoid(-x))) = (1-y)*x + softplus(-x)
+		losses[r] += scale * ((1-y[i])*x[i] + softplus(-x[i]))
+		dx[i] = scale * (sigmoid(x[i]) - y[i])
+		dy[i] = -scale * x[i]
+	}
+	row := func(e int) int { return e / classes }
+	return reduceLoss(name, losses, input.Shape[:len(input.Shape)-1], input.Dtype, reduction, float64(rows), []lossGrad{
+		{input: input, d: dx, at: row},
+		{input: target, d: dy, at: row},
+	})
+}
+
+// floatInputs returns the values of tensors of one floating point data
+// type in float64.
+func floatInputs(name string, ts ...*tensors.Tensor) ([][]float64, error) {
+	values := make([][]float64, len(ts))
+	for i, t := range ts {
+		if t.Dtype != ts[0].Dtype {
+			return nil, errors.New("tensors must have the same data type")
+		}
+		v, err := toFloat64(t)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func sign(x float64) float64 {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
+func checkReduction(reduction, name string) error {
+	switch reduction {
+	case ReductionNone, ReductionMean, ReductionSum:
+		return nil
+	case ReductionBatchMean:
+		if name == "KLDiv" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: unknown reduction %q", name, reduction)
+}
+
+// broadcastConstant returns t broadcast to shape as float64 values.
+func broadcastConstant(t *tensors.Tensor, shape []int, name string) ([]float64, error) {
+	b, err := tensors.BroadcastTo(t.Detach(), shape)
+	if err != nil {
+		return nil, fmt.Errorf("%s: weight of shape %v does not broadcast to %v", name, t.Shape, shape)
+	}
+	return toFloat64(b)
+}
+
+// lossTerm returns the loss of element i with input x and target y, and
+// its derivatives with respect to x and y.
+type lossTerm func(i int, x, y float64) (l, dx, dy float64)
+
+// pointwiseLoss evaluates term on every element of input and target,
+// scales it by the broadcast weight when not nil and reduces the result.
+func pointwiseLoss(name string, input, target, weight *tensors.Tensor, reduction string, term lossTerm) (*tensors.Tensor, error) {
+	if err := checkReduction(reduction, name); err != nil {
+		return nil, err
+	}
+	if !equalInts(input.Shape, target.Shape) {
+		return nil, fmt.Errorf("%s: target of shape %v does not match input of shape %v", name, target.Shape, input.Shape)
+	}
+	if input.Dtype != target.Dtype {
+		return nil, errors.New("tensors must have the same data type")
+	}
+	x, err := toFloat64(input)
+	if err != nil {
+		return nil, err
+	}
+	y, err := toFloat64(target)
+	if err != nil {
+		return nil, err
+	}
+	var w []float64
+	if weight != nil {
+		if w, err = broadcastConstant(weight, input.Shape, name); err != nil {
+			return nil, err
+		}
+	}
+
+	losses, dx, dy := make([]float64, len(x)), make([]float64, len(x)), make([]float64, len(x))
+	parallel.For(len(x), pointwiseCost, func(start, end int) {
+		for i := start; i < end; i++ {
+			losses[i], dx[i], dy[i] = term(i, x[i], y[i])
+			if w != nil {
+				losses[i], dx[i], dy[i] = w[i]*losses[i], w[i]*dx[i], w[i]*dy[i]
+			}
+		}
+	})
+	divisor := float64(len(x))
+	if reduction == ReductionBatchMean && len(input.Shape) > 0 {
+		divisor = float64(input.Shape[0])
+	}
+	same := func(e int) int { return e }
+	return reduceLoss(name, losses, input.Shape, input.Dtype, reduction, divisor, []lossGrad{
+		{input: input, d: dx, at: same},
+		{input: target, d: dy, at: same},
+	})
+}
+
+// lossGrad holds the derivatives of the losses with respect to the
+// elements of one input; at maps an element to the loss it belongs to.
+type lossGrad struct {
+	input *tensors.Tensor
+	d     []float64
+	at    func(e int) int
+}
+
+// reduceLoss returns losses, of the given shape, unreduced or summed and
+// divided by divisor for the mean reductions, and records the backward
+// through grads.
+func reduceLoss(name string, losses []float64, shape []int, dtype tensors.Dtype, reduction string, divisor float64, grads []lossGrad) (*tensors.Tensor, error) {
+	var out *tensors.Tensor
+	var err error
+	if reduction == ReductionNone {
+		out, err = fromFloat64(losses, shape, dtype)
+	} else {
+		var sum float64
+		for _, l := range losses {
+			sum += l
+		}
+		if reduction == ReductionSum {
+			divisor = 1
+		}
+		out, err = fromFloat64([]float64{sum / divisor}, []int{}, dtype)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make([]*tensors.Tensor, len(grads))
+	for i, g := range grads {
+		inputs[i] = g.input
+	}
+	err = tensors.Record(out, name+"Backward", inputs, nil, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		gy, err := toFloat64(grad)
+		if err != nil {
+			return nil, err
+		}
+		scale := func(int) float64 { return gy[0] / divisor }
+		if reduction == ReductionNone {
+			scale = func(p int) float64 { return gy[p] }
+		}
+		result := make([]*tensors.Tensor, len(grads))
+		for i, g := range grads {
+			if !g.input.RequiresGrad {
+				continue
+			}
+			gx := make([]float64, len(g.d))
+			parallel.For(len(gx), 1, func(start, end int) {
+				for e := start; e < end; e++ {
+					if g.d[e] != 0 {
+						gx[e] = g.d[e] * scale(g.at(e))
+					}
+				}
+			})
+			if result[i], err = fromFloat64(gx, g.input.Shape, grad.Dtype); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	})
+	return out, err
+}
+
+// classLayout views class scores as (outer, classes, inner) with the
+// classes along dimension 1, or dimension 0 for a 1-d input.
+type classLayout struct {
+	outer, classes, inner int
+	positions             []int // shape of the input without the class dimension
+}
+
+func newClassLayout(shape []int, name string) (classLayout, error) {
+	switch len(shape) {
+	case 0:
+		return classLayout{}, fmt.Errorf("%s expects an input with at least 1 dimension", name)
+	case 1:
+		return classLayout{outer: 1, classes: shape[0], inner: 1, positions: []int{}}, nil
+	}
+	return classLayout{
+		outer:     shape[0],
+		classes:   shape[1],
+		inner:     shapeSize(shape[2:]),
+		positions: append([]int{shape[0]}, shape[2:]...),
+	}, nil
+}
+
+// position maps element e of the class scores to its position.
+func (l classLayout) position(e int) int {
+	return e/(l.classes*l.inner)*l.inner + e%l.inner
+}
+
+// index returns the element of class c at position p.
+func (l classLayout) index(p, c int) int {
+	return (p/l.inner*l.classes+c)*l.inner + p%l.inner
+}
+
+// classWeights returns the per-class weights, all 1 when weight is nil.
+func classWeights(weight *tensors.Tensor, classes int, name string) ([]float64, error) {
+	if weight == nil {
+		w := make([]float64, classes)
+		for i := range w {
+			w[i] = 1
+		}
+		return w, nil
+	}
+	if !equalInts(weight.Shape, []int{classes}) {
+		return nil, fmt.Errorf("%s expects a weight of shape [%d], got %v", name, classes, weight.Shape)
+	}
+	return toFloat64(weight)
+}
+
+// nllLoss returns the negative log-likelihood of the int64 class indices
+// in target under the log-probabilities logp, mixing in a fraction
+// smoothing of the uniform distribution over the classes.
+func nllLoss(name string, logp, target, weight *tensors.Tensor, ignoreIndex int, reduction string, smoothing float64) (*tensors.Tensor, error) {
+	if err := checkReduction(reduction, name); err != nil {
+		return nil, err
+	}
+	l, err := newClassLayout(logp.Shape, name)
+	if err != nil {
+		return nil, err
+	}
+	labels, ok := target.Data.([]int64)
+	if !ok {
+		return nil, fmt.Errorf("%s expects int64 class indices", name)
+	}
+	if !equalInts(target.Shape, l.positions) {
+		return nil, fmt.Errorf("%s: target of shape %v does not match input of shape %v", name, target.Shape, logp.Shape)
+	}
+	w, err := classWeights(weight, l.classes, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, y := range labels {
+		if y != int64(ignoreIndex) && (y < 0 || y >= int64(l.classes)) {
+			return nil, fmt.Errorf("%s: target %d is out of bounds for %d classes", name, y, l.classes)
+		}
+	}
+	x, err := toFloat64(logp)
+	if err != nil {
+		return nil, err
+	}
+
+	losses, dx := make([]float64, len(labels)), make([]float64, len(x))
+	parallel.For(len(labels), l.classes, func(start, end int) {
+		for p := start; p < end; p++ {
+			y := int(labels[p])
+			if y == ignoreIndex {
+				continue
+			}
+			i := l.index(p, y)
+			losses[p] = -(1 - smoothing) * w[y] * x[i]
+			dx[i] = -(1 - smoothing) * w[y]
+			if smoothing == 0 {
+				continue
+			}
+			for c := 0; c < l.classes; c++ {
+				i := l.index(p, c)
+				losses[p] -= smoothing / float64(l.classes) * w[c] * x[i]
+				dx[i] -= smoothing / float64(l.classes) * w[c]
+			}
+		}
+	})
+	var total float64
+	for _, y := range labels {
+		if y != int64(ignoreIndex) {
+			total += w[y]
+		}
+	}
+	return reduceLoss(name, losses, l.positions, logp.Dtype, reduction, total, []lossGrad{{input: logp, d: dx, at: l.position}})
+}
+
+// softTargetLoss returns the cross entropy between the class probabilities
+// in target, smoothed towards the uniform distribution, and the
+// log-probabilities logp.
+func softTargetLoss(logp, target, weight *tensors.Tensor, reduction string, smoothing float64) (*tensors.Tensor, error) {
+	const name = "CrossEntropy"
+	if err := checkReduction(reduction, name); err != nil {
+		return nil, err
+	}
+	l, err := newClassLayout(logp.Shape, name)
+	if err != nil {
+		return nil, err
+	}
+	if !equalInts(target.Shape, logp.Shape) {
+		return nil, fmt.Errorf("%s: target of shape %v does not match input of shape %v", name, target.Shape, logp.Shape)
+	}
+	if target.Dtype != logp.Dtype {
+		return nil, errors.New("tensors must have the same data type")
+	}
+	w, err := classWeights(weight, l.classes, name)
+	if err != nil {
+		return nil, err
+	}
+	x, err := toFloat64(logp)
+	if err != nil {
+		return nil, err
+	}
+	t, err := toFloat64(target)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := shapeSize(l.positions)
+	losses, dx, dt := make([]float64, positions), make([]float64, len(x)), make([]float64, len(t))
+	parallel.For(positions, l.classes, func(start, end int) {
+		for p := start; p < end; p++ {
+			for c := 0; c < l.classes; c++ {
+				i := l.index(p, c)
+				smoothed := t[i]*(1-smoothing) + smoothing/float64(l.classes)
+				losses[p] -= w[c] * smoothed * x[i]
+				dx[i] = -w[c] * smoothed
+				dt[i] = -w[c] * (1 - smoothing) * x[i]
+			}
+		}
+	})
+	return reduceLoss(name, losses, l.positions, logp.Dtype, reduction, float64(positions), []lossGrad{
+		{input: logp, d: dx, at: l.position},
+		{input: target, d: dt, at: l.position},
+	})
+}
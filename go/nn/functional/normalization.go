@@ -0,0 +1,463 @@
+package functional
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gotorch/internal/parallel"
+	"gotorch/tensors"
+)
+
+// BatchNorm normalizes every channel of an (n, c, ...) input with the mean
+// and variance over the batch and spatial dimensions, then applies the
+// optional per-channel weight and bias. In training mode, or when no
+// running statistics are given, the batch statistics are used and the
+// running statistics, if any, are updated in place as
+// running = (1-momentum)*running + momentum*batch, using the unbiased
+// batch variance. Otherwise the running statistics are used.
+func BatchNorm(input, runningMean, runningVar, weight, bias *tensors.Tensor, training bool, momentum, eps float64) (*tensors.Tensor, error) {
+	if len(input.Shape) < 2 {
+		return nil, fmt.Errorf("BatchNorm expects an input with at least 2 dimensions, got shape %v", input.Shape)
+	}
+	n, c := input.Shape[0], input.Shape[1]
+	s := leading(input.Shape[2:], 0)
+	if err := checkNormParams("BatchNorm", []int{c}, weight, bias, runningMean, runningVar); err != nil {
+		return nil, err
+	}
+	layout := normLayout{outer: n, groups: c, inner: s, affineCount: c, affineInner: s}
+
+	if !training && runningMean != nil && runningVar != nil {
+		stats, err := statsOf(runningMean, runningVar)
+		if err != nil {
+			return nil, err
+		}
+		y, _, err := normalize("BatchNorm", input, weight, bias, layout, stats, false, eps)
+		return y, err
+	}
+
+	if n*s <= 1 {
+		return nil, fmt.Errorf("BatchNorm expects more than 1 value per channel when training, got input of shape %v", input.Shape)
+	}
+	y, stats, err := normalize("BatchNorm", input, weight, bias, layout, nil, false, eps)
+	if err != nil {
+		return nil, err
+	}
+	if runningMean != nil && runningVar != nil {
+		correction := float64(n*s) / float64(n*s-1)
+		unbiased := make([]float64, c)
+		for i, v := range stats.variance {
+			unbiased[i] = v * correction
+		}
+		if err := updateRunning(runningMean, stats.mean, momentum); err != nil {
+			return nil, err
+		}
+		if err := updateRunning(runningVar, unbiased, momentum); err != nil {
+			return nil, err
+		}
+	}
+	return y, nil
+}
+
+// InstanceNorm normalizes every channel of every sample of an
+// (n, c, spatial...) input over its spatial dimensions, then applies the
+// optional per-channel weight and bias. With useInputStats set, or when no
+// running statistics are given, the statistics of each instance are used
+// and the running statistics, if any, are updated with their average over
+// the batch. Otherwise the running statistics are used.
+func InstanceNorm(input, runningMean, runningVar, weight, bias *tensors.Tensor, useInputStats bool, momentum, eps float64) (*tensors.Tensor, error) {
+	if len(input.Shape) < 3 {
+		return nil, fmt.Errorf("InstanceNorm expects an input with at least 3 dimensions, got shape %v", input.Shape)
+	}
+	n, c := input.Shape[0], input.Shape[1]
+	s := leading(input.Shape[2:], 0)
+	if err := checkNormParams("InstanceNorm", []int{c}, weight, bias, runningMean, runningVar); err != nil {
+		return nil, err
+	}
+	layout := normLayout{outer: 1, groups: n * c, inner: s, affineCount: c, affineInner: s}
+
+	if !useInputStats && runningMean != nil && runningVar != nil {
+		perChannel, err := statsOf(runningMean, runningVar)
+		if err != nil {
+			return nil, err
+		}
+		stats := &normStats{mean: make([]float64, n*c), variance: make([]float64, n*c)}
+		for i := range stats.mean {
+			stats.mean[i], stats.variance[i] = perChannel.mean[i%c], perChannel.variance[i%c]
+		}
+		y, _, err := normalize("InstanceNorm", input, weight, bias, layout, stats, false, eps)
+		return y, err
+	}
+
+	if s <= 1 {
+		return nil, fmt.Errorf("InstanceNorm expects more than 1 spatial element per channel, got input of shape %v", input.Shape)
+	}
+	y, stats, err := normalize("InstanceNorm", input, weight, bias, layout, nil, false, eps)
+	if err != nil {
+		return nil, err
+	}
+	if runningMean != nil && runningVar != nil {
+		correction := float64(s) / float64(s-1)
+		mean, variance := make([]float64, c), make([]float64, c)
+		for i := range stats.mean {
+			mean[i%c] += stats.mean[i] / float64(n)
+			variance[i%c] += stats.variance[i] * correction / float64(n)
+		}
+		if err := updateRunning(runningMean, mean, momentum); err != nil {
+			return nil, err
+		}
+		if err := updateRunning(runningVar, variance, momentum); err != nil {
+			return nil, err
+		}
+	}
+	return y, nil
+}
+
+// LayerNorm normalizes input over its trailing dimensions, which must
+// equal normalizedShape, then applies the optional elementwise weight and
+// bias of that shape.
+func LayerNorm(input *tensors.Tensor, normalizedShape []int, weight, bias *tensors.Tensor, eps float64) (*tensors.Tensor, error) {
+	d, err := normalizedSize("LayerNorm", input, normalizedShape)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNormParams("LayerNorm", normalizedShape, weight, bias); err != nil {
+		return nil, err
+	}
+	layout := normLayout{outer: 1, groups: leading(input.Shape, 0) / d, inner: d, affineCount: d, affineInner: 1}
+	y, _, err := normalize("LayerNorm", input, weight, bias, layout, nil, false, eps)
+	return y, err
+}
+
+// GroupNorm splits the channels of an (n, c, ...) input into numGroups
+// groups, normalizes every group of every sample, then applies the
+// optional per-channel weight and bias.
+func GroupNorm(input *tensors.Tensor, numGroups int, weight, bias *tensors.Tensor, eps float64) (*tensors.Tensor, error) {
+	if len(input.Shape) < 2 {
+		return nil, fmt.Errorf("GroupNorm expects an input with at least 2 dimensions, got shape %v", input.Shape)
+	}
+	n, c := input.Shape[0], input.Shape[1]
+	if numGroups <= 0 || c%numGroups != 0 {
+		return nil, fmt.Errorf("GroupNorm: %d channels are not divisible into %d groups", c, numGroups)
+	}
+	if err := checkNormParams("GroupNorm", []int{c}, weight, bias); err != nil {
+		return nil, err
+	}
+	s := leading(input.Shape[2:], 0)
+	layout := normLayout{outer: 1, groups: n * numGroups, inner: c / numGroups * s, affineCount: c, affineInner: s}
+	y, _, err := normalize("GroupNorm", input, weight, bias, layout, nil, false, eps)
+	return y, err
+}
+
+// RMSNorm divides input by the root mean square over its trailing
+// dimensions, which must equal normalizedShape, then applies the optional
+// elementwise weight of that shape.
+func RMSNorm(input *tensors.Tensor, normalizedShape []int, weight *tensors.Tensor, eps float64) (*tensors.Tensor, error) {
+	d, err := normalizedSize("RMSNorm", input, normalizedShape)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNormParams("RMSNorm", normalizedShape, weight); err != nil {
+		return nil, err
+	}
+	layout := normLayout{outer: 1, groups: leading(input.Shape, 0) / d, inner: d, affineCount: d, affineInner: 1}
+	y, _, err := normalize("RMSNorm", input, weight, nil, layout, nil, true, eps)
+	return y, err
+}
+
+func normalizedSize(name string, input *tensors.Tensor, normalizedShape []int) (int, error) {
+	k := len(normalizedShape)
+	if k == 0 || len(input.Shape) < k || !equalInts(input.Shape[len(input.Shape)-k:], normalizedShape) {
+		return 0, fmt.Errorf("%s: input of shape %v does not end in the normalized shape %v", name, input.Shape, normalizedShape)
+	}
+	return shapeSize(normalizedShape), nil
+}
+
+// checkNormParams checks that every non-nil parameter has the given shape.
+func checkNormParams(name string, shape []int, params ...*tensors.Tensor) error {
+	for _, p := range params {
+		if p != nil && !equalInts(p.Shape, shape) {
+			return fmt.Errorf("%s expects parameters and statistics of shape %v, got %v", name, shape, p.Shape)
+		}
+	}
+	return nil
+}
+
+// normLayout describes how a normalization groups the elements of its
+// input. The element at flat index (o*groups+g)*inner+s belongs to
+// statistics group g, and the affine parameters of element i are indexed by
+// (i/affineInner)%affineCount.
+type normLayout struct {
+	outer, groups, inner     int
+	affineCount, affineInner int
+}
+
+func (l normLayout) groupSize() int { return l.outer * l.inner }
+
+// normStats holds the biased mean and variance of every group.
+type normStats struct {
+	mean, variance []float64
+}
+
+func statsOf(mean, variance *tensors.Tensor) (*normStats, error) {
+	m, err := toFloat64(mean)
+	if err != nil {
+		return nil, err
+	}
+	v, err := toFloat64(variance)
+	if err != nil {
+		return nil, err
+	}
+	return &normStats{mean: m, variance: v}, nil
+}
+
+func toFloat64(t *tensors.Tensor) ([]float64, error) {
+	switch v := t.Data.(type) {
+	case []float32:
+		out := make([]float64, len(v))
+		for i, x := range v {
+			out[i] = float64(x)
+		}
+		return out, nil
+	case []float64:
+		return append([]float64(nil), v...), nil
+	}
+	return nil, errors.New("unsupported data type")
+}
+
+// updateRunning blends batch statistics into a running statistics buffer
+// in place.
+func updateRunning(running *tensors.Tensor, batch []float64, momentum float64) error {
+	prev, err := toFloat64(running)
+	if err != nil {
+		return err
+	}
+	for i := range prev {
+		prev[i] = (1-momentum)*prev[i] + momentum*batch[i]
+	}
+	next, err := fromFloat64(prev, running.Shape, running.Dtype)
+	if err != nil {
+		return err
+	}
+	tensors.NoGrad(func() { err = running.CopyFrom(next) })
+	return err
+}
+
+func fromFloat64(values []float64, shape []int, dtype tensors.Dtype) (*tensors.Tensor, error) {
+	var data interface{}
+	switch dtype.DataType() {
+	case "float32":
+		converted := make([]float32, len(values))
+		for i, v := range values {
+			converted[i] = float32(v)
+		}
+		data = converted
+	case "float64":
+		data = values
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	return &tensors.Tensor{Shape: append([]int{}, shape...), Data: data, Dtype: dtype}, nil
+}
+
+// normalize computes weight*(x-mean)/sqrt(var+eps) + bias over the groups
+// of layout, as one op with a fused backward. When stats is nil the
+// statistics are computed from x with Welford's algorithm and
+// differentiated through; otherwise they are treated as constants. RMS
+// normalization skips the centering and divides by sqrt(mean(x^2)+eps).
+// The statistics that were used are returned.
+func normalize(name string, x, weight, bias *tensors.Tensor, layout normLayout, stats *normStats, rms bool, eps float64) (*tensors.Tensor, *normStats, error) {
+	for _, p := range []*tensors.Tensor{weight, bias} {
+		if p != nil && p.Dtype != x.Dtype {
+			return nil, nil, errors.New("tensors must have the same data type")
+		}
+	}
+	batchStats := stats == nil
+
+	var data interface{}
+	switch v := x.Data.(type) {
+	case []float32:
+		if batchStats {
+			stats = groupStats(v, layout, rms)
+		}
+		data = normForward(v, floatsOf[float32](weight), floatsOf[float32](bias), layout, stats, eps)
+	case []float64:
+		if batchStats {
+			stats = groupStats(v, layout, rms)
+		}
+		data = normForward(v, floatsOf[float64](weight), floatsOf[float64](bias), layout, stats, eps)
+	default:
+		return nil, nil, errors.New("unsupported data type")
+	}
+	out := &tensors.Tensor{Shape: append([]int{}, x.Shape...), Data: data, Dtype: x.Dtype}
+
+	inputs := []*tensors.Tensor{x}
+	saved := []*tensors.Tensor{x.Detach()}
+	px, pw := saved[0], (*tensors.Tensor)(nil)
+	if weight != nil {
+		pw = weight.Detach()
+		inputs, saved = append(inputs, weight), append(saved, pw)
+	}
+	if bias != nil {
+		inputs = append(inputs, bias)
+	}
+	hasWeight, hasBias := weight != nil, bias != nil
+	err := tensors.Record(out, name+"Backward", inputs, saved, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		var gx, gw, gb interface{}
+		switch gy := grad.Data.(type) {
+		case []float32:
+			gx, gw, gb = normBackward(gy, px.Data.([]float32), floatsOf[float32](pw), layout, stats, eps, batchStats, rms, hasBias)
+		case []float64:
+			gx, gw, gb = normBackward(gy, px.Data.([]float64), floatsOf[float64](pw), layout, stats, eps, batchStats, rms, hasBias)
+		default:
+			return nil, errors.New("unsupported data type")
+		}
+		grads := []*tensors.Tensor{{Shape: append([]int{}, px.Shape...), Data: gx, Dtype: px.Dtype}}
+		if hasWeight {
+			grads = append(grads, &tensors.Tensor{Shape: append([]int{}, pw.Shape...), Data: gw, Dtype: px.Dtype})
+		}
+		if hasBias {
+			grads = append(grads, &tensors.Tensor{Shape: append([]int{}, bias.Shape...), Data: gb, Dtype: px.Dtype})
+		}
+		return grads, nil
+	})
+	return out, stats, err
+}
+
+// floatsOf returns the data of t, or nil for a nil tensor.
+func floatsOf[T float32 | float64](t *tensors.Tensor) []T {
+	if t == nil {
+		return nil
+	}
+	return t.Data.([]T)
+}
+
+// groupStats computes the mean and biased variance of every group in
+// float64 with Welford's algorithm, or the mean square for RMS
+// normalization.
+func groupStats[T float32 | float64](x []T, l normLayout, rms bool) *normStats {
+	stats := &normStats{mean: make([]float64, l.groups), variance: make([]float64, l.groups)}
+	parallel.For(l.groups, l.groupSize(), func(start, end int) {
+		for g := start; g < end; g++ {
+			var count, mean, m2 float64
+			for o := 0; o < l.outer; o++ {
+				base := (o*l.groups + g) * l.inner
+				for _, v := range x[base : base+l.inner] {
+					f := float64(v)
+					if rms {
+						m2 += f * f
+						continue
+					}
+					count++
+					d := f - mean
+					mean += d / count
+					m2 += d * (f - mean)
+				}
+			}
+			stats.mean[g] = mean
+			stats.variance[g] = m2 / float64(l.groupSize())
+		}
+	})
+	return stats
+}
+
+func normForward[T float32 | float64](x, w, b []T, l normLayout, stats *normStats, eps float64) []T {
+	y := make([]T, len(x))
+	parallel.For(l.groups, l.groupSize(), func(start, end int) {
+		for g := start; g < end; g++ {
+			mean, invstd := stats.mean[g], 1/math.Sqrt(stats.variance[g]+eps)
+			for o := 0; o < l.outer; o++ {
+				base := (o*l.groups + g) * l.inner
+				for i := base; i < base+l.inner; i++ {
+					v := (float64(x[i]) - mean) * invstd
+					a := i / l.affineInner % l.affineCount
+					if w != nil {
+						v *= float64(w[a])
+					}
+					if b != nil {
+						v += float64(b[a])
+					}
+					y[i] = T(v)
+				}
+			}
+		}
+	})
+	return y
+}
+
+// normBackward returns the gradients of the input, weight and bias. With
+// batch statistics the input gradient of every group is
+// invstd * (g - mean(g) - xhat*mean(g*xhat)), where g is the gradient of
+// the normalized input xhat; RMS normalization drops the mean(g) term.
+func normBackward[T float32 | float64](gy, x, w []T, l normLayout, stats *normStats, eps float64, batchStats, rms, hasBias bool) ([]T, []T, []T) {
+	invstd := make([]float64, l.groups)
+	for g := range invstd {
+		invstd[g] = 1 / math.Sqrt(stats.variance[g]+eps)
+	}
+	xhat := func(i int) float64 {
+		g := i / l.inner % l.groups
+		return (float64(x[i]) - stats.mean[g]) * invstd[g]
+	}
+	scaled := func(i int) float64 {
+		if w == nil {
+			return float64(gy[i])
+		}
+		return float64(gy[i]) * float64(w[i/l.affineInner%l.affineCount])
+	}
+
+	gx := make([]T, len(x))
+	m := float64(l.groupSize())
+	parallel.For(l.groups, 2*l.groupSize(), func(start, end int) {
+		for g := start; g < end; g++ {
+			var sum, dot float64
+			if batchStats {
+				for o := 0; o < l.outer; o++ {
+					base := (o*l.groups + g) * l.inner
+					for i := base; i < base+l.inner; i++ {
+						sum += scaled(i)
+						dot += scaled(i) * xhat(i)
+					}
+				}
+				if rms {
+					sum = 0
+				}
+			}
+			for o := 0; o < l.outer; o++ {
+				base := (o*l.groups + g) * l.inner
+				for i := base; i < base+l.inner; i++ {
+					gx[i] = T(invstd[g] * (scaled(i) - sum/m - xhat(i)*dot/m))
+				}
+			}
+		}
+	})
+
+	var gw, gb []T
+	if w != nil || hasBias {
+		if w != nil {
+			gw = make([]T, l.affineCount)
+		}
+		if hasBias {
+			gb = make([]T, l.affineCount)
+		}
+		outer := len(x) / (l.affineCount * l.affineInner)
+		parallel.For(l.affineCount, outer*l.affineInner, func(start, end int) {
+			for a := start; a < end; a++ {
+				var sw, sb float64
+				for o := 0; o < outer; o++ {
+					base := (o*l.affineCount + a) * l.affineInner
+					for i := base; i < base+l.affineInner; i++ {
+						sw += float64(gy[i]) * xhat(i)
+						sb += float64(gy[i])
+					}
+				}
+				if gw != nil {
+					gw[a] = T(sw)
+				}
+				if gb != nil {
+					gb[a] = T(sb)
+				}
+			}
+		})
+	}
+	return gx, gw, gb
+}
@@ -0,0 +1,577 @@
+package functional
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gotorch/internal/parallel"
+	"gotorch/tensors"
+)
+
+// PoolOptions configures a pooling layer. Stride, Padding and Dilation hold
+// either one value shared by every spatial dimension or one value per
+// dimension.
+type PoolOptions struct {
+	Stride          []int // defaults to the kernel size
+	Padding         []int // implicit padding on both sides, at most half the kernel size
+	Dilation        []int // spacing between window elements, max pooling only
+	CeilMode        bool  // round the output size up instead of down
+	CountExcludePad bool  // average pooling divides by the number of non-padding elements, PyTorch's count_include_pad=False
+}
+
+// MaxPool1d takes the maximum over sliding windows of an (n, c, l) or
+// (c, l) input.
+func MaxPool1d(input *tensors.Tensor, kernelSize []int, opts PoolOptions) (*tensors.Tensor, error) {
+	out, _, err := maxPool(input, kernelSize, opts, 1)
+	return out, err
+}
+
+// MaxPool2d takes the maximum over sliding windows of an (n, c, h, w) or
+// (c, h, w) input.
+func MaxPool2d(input *tensors.Tensor, kernelSize []int, opts PoolOptions) (*tensors.Tensor, error) {
+	out, _, err := maxPool(input, kernelSize, opts, 2)
+	return out, err
+}
+
+// MaxPool3d takes the maximum over sliding windows of an (n, c, d, h, w)
+// or (c, d, h, w) input.
+func MaxPool3d(input *tensors.Tensor, kernelSize []int, opts PoolOptions) (*tensors.Tensor, error) {
+	out, _, err := maxPool(input, kernelSize, opts, 3)
+	return out, err
+}
+
+// MaxPool1dWithIndices is MaxPool1d that also returns the int64 position
+// of every maximum within its input plane, as accepted by MaxUnpool1d.
+func MaxPool1dWithIndices(input *tensors.Tensor, kernelSize []int, opts PoolOptions) (*tensors.Tensor, *tensors.Tensor, error) {
+	return maxPool(input, kernelSize, opts, 1)
+}
+
+// MaxPool2dWithIndices is MaxPool2d that also returns the int64 flat
+// position of every maximum within its input plane, as accepted by
+// MaxUnpool2d.
+func MaxPool2dWithIndices(input *tensors.Tensor, kernelSize []int, opts PoolOptions) (*tensors.Tensor, *tensors.Tensor, error) {
+	return maxPool(input, kernelSize, opts, 2)
+}
+
+// MaxPool3dWithIndices is MaxPool3d that also returns the int64 flat
+// position of every maximum within its input plane, as accepted by
+// MaxUnpool3d.
+func MaxPool3dWithIndices(input *tensors.Tensor, kernelSize []int, opts PoolOptions) (*tensors.Tensor, *tensors.Tensor, error) {
+	return maxPool(input, kernelSize, opts, 3)
+}
+
+// AvgPool1d averages sliding windows of an (n, c, l) or (c, l) input.
+func AvgPool1d(input *tensors.Tensor, kernelSize []int, opts PoolOptions) (*tensors.Tensor, error) {
+	return avgPool(input, kernelSize, opts, 1)
+}
+
+// AvgPool2d averages sliding windows of an (n, c, h, w) or (c, h, w)
+// input.
+func AvgPool2d(input *tensors.Tensor, kernelSize []int, opts PoolOptions) (*tensors.Tensor, error) {
+	return avgPool(input, kernelSize, opts, 2)
+}
+
+// AvgPool3d averages sliding windows of an (n, c, d, h, w) or
+// (c, d, h, w) input.
+func AvgPool3d(input *tensors.Tensor, kernelSize []int, opts PoolOptions) (*tensors.Tensor, error) {
+	return avgPool(input, kernelSize, opts, 3)
+}
+
+// LPPool1d computes the p-norm (sum x^p)^(1/p) of sliding windows of an
+// (n, c, l) or (c, l) input.
+func LPPool1d(input *tensors.Tensor, normType float64, kernelSize []int, opts PoolOptions) (*tensors.Tensor, error) {
+	return lpPool(input, normType, kernelSize, opts, 1)
+}
+
+// LPPool2d computes the p-norm (sum x^p)^(1/p) of sliding windows of an
+// (n, c, h, w) or (c, h, w) input.
+func LPPool2d(input *tensors.Tensor, normType float64, kernelSize []int, opts PoolOptions) (*tensors.Tensor, error) {
+	return lpPool(input, normType, kernelSize, opts, 2)
+}
+
+// MaxUnpool1d inverts MaxPool1d by placing every value of input at the
+// position given by indices in a zero output. outputSize, which may be
+// nil, selects among the input sizes that pool to the size of input.
+func MaxUnpool1d(input, indices *tensors.Tensor, kernelSize []int, opts PoolOptions, outputSize []int) (*tensors.Tensor, error) {
+	return maxUnpool(input, indices, kernelSize, opts, outputSize, 1)
+}
+
+// MaxUnpool2d inverts MaxPool2d by placing every value of input at the
+// position given by indices in a zero output. outputSize, which may be
+// nil, selects among the input sizes that pool to the size of input.
+func MaxUnpool2d(input, indices *tensors.Tensor, kernelSize []int, opts PoolOptions, outputSize []int) (*tensors.Tensor, error) {
+	return maxUnpool(input, indices, kernelSize, opts, outputSize, 2)
+}
+
+// MaxUnpool3d inverts MaxPool3d by placing every value of input at the
+// position given by indices in a zero output. outputSize, which may be
+// nil, selects among the input sizes that pool to the size of input.
+func MaxUnpool3d(input, indices *tensors.Tensor, kernelSize []int, opts PoolOptions, outputSize []int) (*tensors.Tensor, error) {
+	return maxUnpool(input, indices, kernelSize, opts, outputSize, 3)
+}
+
+// poolPlanes checks the rank of a pooling input over dims spatial
+// dimensions and returns the number of planes it holds.
+func poolPlanes(input *tensors.Tensor, dims int, name string) (int, error) {
+	if len(input.Shape) != dims+1 && len(input.Shape) != dims+2 {
+		return 0, fmt.Errorf("%s expects a %d-d or %d-d input, got shape %v", name, dims+1, dims+2, input.Shape)
+	}
+	return leading(input.Shape, dims), nil
+}
+
+// newPoolGeometry resolves the windows of a pooling layer over the spatial
+// input size in.
+func newPoolGeometry(in, kernelSize []int, opts PoolOptions, name string) (*convGeometry, error) {
+	dims := len(in)
+	kernel, err := expandInts(kernelSize, dims, 0, "kernel size", name)
+	if err != nil {
+		return nil, err
+	}
+	stride, err := expandInts(opts.Stride, dims, 0, "stride", name)
+	if err != nil {
+		return nil, err
+	}
+	padding, err := expandInts(opts.Padding, dims, 0, "padding", name)
+	if err != nil {
+		return nil, err
+	}
+	dilation, err := expandInts(opts.Dilation, dims, 1, "dilation", name)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &convGeometry{channels: 1}
+	for i := range g.in {
+		g.in[i], g.kernel[i], g.out[i], g.stride[i], g.dilation[i] = 1, 1, 1, 1, 1
+	}
+	off := 3 - dims
+	for i := 0; i < dims; i++ {
+		k, s, p, d := kernel[i], stride[i], padding[i], dilation[i]
+		if s == 0 {
+			s = k
+		}
+		if k <= 0 || s <= 0 || d <= 0 {
+			return nil, fmt.Errorf("%s requires positive kernel size, stride and dilation", name)
+		}
+		if p < 0 || 2*p > k {
+			return nil, fmt.Errorf("%s: padding must be non-negative and at most half the kernel size", name)
+		}
+		span := in[i] + 2*p - d*(k-1) - 1
+		out := floorDiv(span, s) + 1
+		if opts.CeilMode {
+			out = floorDiv(span+s-1, s) + 1
+			// The last window must start inside the input or its left
+			// padding.
+			if (out-1)*s >= in[i]+p {
+				out--
+			}
+		}
+		if out <= 0 {
+			return nil, fmt.Errorf("%s: kernel of size %v is larger than the padded input of size %v", name, kernel, in)
+		}
+		g.in[off+i], g.kernel[off+i], g.out[off+i] = in[i], k, out
+		g.stride[off+i], g.dilation[off+i], g.pad[off+i] = s, d, p
+	}
+	return g, nil
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// pooledShape replaces the last dims dimensions of shape with out.
+func pooledShape(shape []int, out []int) []int {
+	return append(append([]int{}, shape[:len(shape)-len(out)]...), out...)
+}
+
+func maxPool(input *tensors.Tensor, kernelSize []int, opts PoolOptions, dims int) (*tensors.Tensor, *tensors.Tensor, error) {
+	name := fmt.Sprintf("MaxPool%dd", dims)
+	planes, err := poolPlanes(input, dims, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	g, err := newPoolGeometry(input.Shape[len(input.Shape)-dims:], kernelSize, opts, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		data    interface{}
+		indices []int64
+	)
+	switch x := input.Data.(type) {
+	case []float32:
+		data, indices = maxPoolKernel(x, planes, g)
+	case []float64:
+		data, indices = maxPoolKernel(x, planes, g)
+	default:
+		return nil, nil, errors.New("unsupported data type")
+	}
+	shape := pooledShape(input.Shape, g.out[3-dims:])
+	out := &tensors.Tensor{Shape: shape, Data: data, Dtype: input.Dtype}
+	idx := &tensors.Tensor{Shape: append([]int{}, shape...), Data: indices, Dtype: tensors.Int64{}}
+	inShape := append([]int{}, input.Shape...)
+	err = tensors.Record(out, fmt.Sprintf("MaxPool%ddBackward", dims), []*tensors.Tensor{input}, nil, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		gx, err := scatterPlanes(grad, indices, inShape, planes, true)
+		return []*tensors.Tensor{gx}, err
+	})
+	return out, idx, err
+}
+
+func maxPoolKernel[T float32 | float64](x []T, planes int, g *convGeometry) ([]T, []int64) {
+	plane, l := g.inSize(), g.outSize()
+	y := make([]T, planes*l)
+	indices := make([]int64, planes*l)
+	parallel.For(planes, g.kernelSize()*l, func(start, end int) {
+		for p := start; p < end; p++ {
+			src := x[p*plane : (p+1)*plane]
+			dst, at := y[p*l:(p+1)*l], indices[p*l:(p+1)*l]
+			for i := range at {
+				at[i] = -1
+			}
+			g.window(func(_, pos, idx int) {
+				if idx < 0 {
+					return
+				}
+				// NaN wins so that it propagates, as in PyTorch.
+				v := src[idx]
+				if at[pos] < 0 || v > dst[pos] || (v != v && dst[pos] == dst[pos]) {
+					dst[pos], at[pos] = v, int64(idx)
+				}
+			})
+		}
+	})
+	return y, indices
+}
+
+// scatterPlanes moves every value of src, which holds planes equal-sized
+// planes, to the position given by indices within the corresponding plane
+// of a zero tensor of the given shape. Values landing on the same position
+// are summed when accumulate is set and overwrite each other otherwise.
+func scatterPlanes(src *tensors.Tensor, indices []int64, shape []int, planes int, accumulate bool) (*tensors.Tensor, error) {
+	out := &tensors.Tensor{Shape: append([]int{}, shape...), Dtype: src.Dtype}
+	switch v := src.Data.(type) {
+	case []float32:
+		out.Data = scatterKernel(v, indices, planes, shapeSize(shape)/planes, accumulate)
+	case []float64:
+		out.Data = scatterKernel(v, indices, planes, shapeSize(shape)/planes, accumulate)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	return out, nil
+}
+
+func scatterKernel[T float32 | float64](src []T, indices []int64, planes, plane int, accumulate bool) []T {
+	dst := make([]T, planes*plane)
+	l := len(src) / planes
+	parallel.For(planes, l, func(start, end int) {
+		for p := start; p < end; p++ {
+			out := dst[p*plane : (p+1)*plane]
+			for i, idx := range indices[p*l : (p+1)*l] {
+				if accumulate {
+					out[idx] += src[p*l+i]
+				} else {
+					out[idx] = src[p*l+i]
+				}
+			}
+		}
+	})
+	return dst
+}
+
+// gatherPlanes reads, for every entry of indices, the value at that
+// position within the corresponding plane of src.
+func gatherPlanes(src *tensors.Tensor, indices []int64, shape []int, planes int) (*tensors.Tensor, error) {
+	out := &tensors.Tensor{Shape: append([]int{}, shape...), Dtype: src.Dtype}
+	switch v := src.Data.(type) {
+	case []float32:
+		out.Data = gatherKernel(v, indices, planes)
+	case []float64:
+		out.Data = gatherKernel(v, indices, planes)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	return out, nil
+}
+
+func gatherKernel[T float32 | float64](src []T, indices []int64, planes int) []T {
+	dst := make([]T, len(indices))
+	plane, l := len(src)/planes, len(indices)/planes
+	parallel.For(planes, l, func(start, end int) {
+		for p := start; p < end; p++ {
+			for i, idx := range indices[p*l : (p+1)*l] {
+				dst[p*l+i] = src[p*plane+int(idx)]
+			}
+		}
+	})
+	return dst
+}
+
+func avgPool(input *tensors.Tensor, kernelSize []int, opts PoolOptions, dims int) (*tensors.Tensor, error) {
+	name := fmt.Sprintf("AvgPool%dd", dims)
+	if len(opts.Dilation) > 0 {
+		return nil, fmt.Errorf("%s does not support dilation", name)
+	}
+	planes, err := poolPlanes(input, dims, name)
+	if err != nil {
+		return nil, err
+	}
+	g, err := newPoolGeometry(input.Shape[len(input.Shape)-dims:], kernelSize, opts, name)
+	if err != nil {
+		return nil, err
+	}
+	divisors := g.avgDivisors(opts.CountExcludePad)
+
+	var data interface{}
+	switch x := input.Data.(type) {
+	case []float32:
+		data = avgPoolKernel(x, planes, g, divisors)
+	case []float64:
+		data = avgPoolKernel(x, planes, g, divisors)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	out := &tensors.Tensor{Shape: pooledShape(input.Shape, g.out[3-dims:]), Data: data, Dtype: input.Dtype}
+	inShape := append([]int{}, input.Shape...)
+	err = tensors.Record(out, fmt.Sprintf("AvgPool%ddBackward", dims), []*tensors.Tensor{input}, nil, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		gx := &tensors.Tensor{Shape: inShape, Dtype: grad.Dtype}
+		switch gy := grad.Data.(type) {
+		case []float32:
+			gx.Data = avgPoolBackward(gy, planes, g, divisors)
+		case []float64:
+			gx.Data = avgPoolBackward(gy, planes, g, divisors)
+		default:
+			return nil, errors.New("unsupported data type")
+		}
+		return []*tensors.Tensor{gx}, nil
+	})
+	return out, err
+}
+
+// avgDivisors returns the number of elements every window is averaged
+// over. Windows count the padding they cover unless excludePad is set,
+// but never the part of a ceil-mode window that hangs past the padding.
+func (g *convGeometry) avgDivisors(excludePad bool) []float64 {
+	var sizes [3][]int
+	for d := range sizes {
+		sizes[d] = make([]int, g.out[d])
+		for o := range sizes[d] {
+			start := o*g.stride[d] - g.pad[d]
+			end := min(start+g.kernel[d], g.in[d]+g.pad[d])
+			if excludePad {
+				start, end = max(start, 0), min(end, g.in[d])
+			}
+			sizes[d][o] = end - start
+		}
+	}
+	divisors := make([]float64, 0, g.outSize())
+	for _, a := range sizes[0] {
+		for _, b := range sizes[1] {
+			for _, c := range sizes[2] {
+				divisors = append(divisors, float64(a*b*c))
+			}
+		}
+	}
+	return divisors
+}
+
+func avgPoolKernel[T float32 | float64](x []T, planes int, g *convGeometry, divisors []float64) []T {
+	plane, l := g.inSize(), g.outSize()
+	y := make([]T, planes*l)
+	parallel.For(planes, g.kernelSize()*l, func(start, end int) {
+		for p := start; p < end; p++ {
+			src, dst := x[p*plane:(p+1)*plane], y[p*l:(p+1)*l]
+			g.window(func(_, pos, idx int) {
+				if idx >= 0 {
+					dst[pos] += src[idx]
+				}
+			})
+			for i := range dst {
+				dst[i] /= T(divisors[i])
+			}
+		}
+	})
+	return y
+}
+
+func avgPoolBackward[T float32 | float64](gy []T, planes int, g *convGeometry, divisors []float64) []T {
+	plane, l := g.inSize(), g.outSize()
+	gx := make([]T, planes*plane)
+	parallel.For(planes, g.kernelSize()*l, func(start, end int) {
+		for p := start; p < end; p++ {
+			src, dst := gy[p*l:(p+1)*l], gx[p*plane:(p+1)*plane]
+			g.window(func(_, pos, idx int) {
+				if idx >= 0 {
+					dst[idx] += src[pos] / T(divisors[pos])
+				}
+			})
+		}
+	})
+	return gx
+}
+
+func lpPool(input *tensors.Tensor, normType float64, kernelSize []int, opts PoolOptions, dims int) (*tensors.Tensor, error) {
+	name := fmt.Sprintf("LPPool%dd", dims)
+	if normType <= 0 || math.IsInf(normType, 0) {
+		return nil, fmt.Errorf("%s requires a positive finite norm type, got %v", name, normType)
+	}
+	if len(opts.Dilation) > 0 {
+		return nil, fmt.Errorf("%s does not support dilation", name)
+	}
+	planes, err := poolPlanes(input, dims, name)
+	if err != nil {
+		return nil, err
+	}
+	g, err := newPoolGeometry(input.Shape[len(input.Shape)-dims:], kernelSize, opts, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+	switch x := input.Data.(type) {
+	case []float32:
+		data = lpPoolKernel(x, planes, g, float32(normType))
+	case []float64:
+		data = lpPoolKernel(x, planes, g, normType)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	out := &tensors.Tensor{Shape: pooledShape(input.Shape, g.out[3-dims:]), Data: data, Dtype: input.Dtype}
+	px, py := input.Detach(), out.Detach()
+	err = tensors.Record(out, fmt.Sprintf("LPPool%ddBackward", dims), []*tensors.Tensor{input}, []*tensors.Tensor{px, py}, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		gx := &tensors.Tensor{Shape: append([]int{}, px.Shape...), Dtype: grad.Dtype}
+		switch gy := grad.Data.(type) {
+		case []float32:
+			gx.Data = lpPoolBackward(gy, px.Data.([]float32), py.Data.([]float32), planes, g, float32(normType))
+		case []float64:
+			gx.Data = lpPoolBackward(gy, px.Data.([]float64), py.Data.([]float64), planes, g, normType)
+		default:
+			return nil, errors.New("unsupported data type")
+		}
+		return []*tensors.Tensor{gx}, nil
+	})
+	return out, err
+}
+
+func lpPoolKernel[T float32 | float64](x []T, planes int, g *convGeometry, p T) []T {
+	plane, l := g.inSize(), g.outSize()
+	y := make([]T, planes*l)
+	parallel.For(planes, g.kernelSize()*l, func(start, end int) {
+		for q := start; q < end; q++ {
+			src, dst := x[q*plane:(q+1)*plane], y[q*l:(q+1)*l]
+			g.window(func(_, pos, idx int) {
+				if idx >= 0 {
+					dst[pos] += T(math.Pow(float64(src[idx]), float64(p)))
+				}
+			})
+			for i, s := range dst {
+				dst[i] = T(math.Pow(float64(s), 1/float64(p)))
+			}
+		}
+	})
+	return y
+}
+
+// lpPoolBackward uses d/dx (sum x^p)^(1/p) = y^(1-p) x^(p-1), taking the
+// gradient of an all-zero window as zero.
+func lpPoolBackward[T float32 | float64](gy, x, y []T, planes int, g *convGeometry, p T) []T {
+	plane, l := g.inSize(), g.outSize()
+	gx := make([]T, planes*plane)
+	parallel.For(planes, g.kernelSize()*l, func(start, end int) {
+		for q := start; q < end; q++ {
+			src, dst := x[q*plane:(q+1)*plane], gx[q*plane:(q+1)*plane]
+			out, gout := y[q*l:(q+1)*l], gy[q*l:(q+1)*l]
+			g.window(func(_, pos, idx int) {
+				if idx < 0 || out[pos] == 0 {
+					return
+				}
+				scale := math.Pow(float64(out[pos]), float64(1-p)) * math.Pow(float64(src[idx]), float64(p-1))
+				dst[idx] += gout[pos] * T(scale)
+			})
+		}
+	})
+	return gx
+}
+
+func maxUnpool(input, indices *tensors.Tensor, kernelSize []int, opts PoolOptions, outputSize []int, dims int) (*tensors.Tensor, error) {
+	name := fmt.Sprintf("MaxUnpool%dd", dims)
+	planes, err := poolPlanes(input, dims, name)
+	if err != nil {
+		return nil, err
+	}
+	idx, ok := indices.Data.([]int64)
+	if !ok || !equalInts(indices.Shape, input.Shape) {
+		return nil, fmt.Errorf("%s expects int64 indices of shape %v", name, input.Shape)
+	}
+
+	in := input.Shape[len(input.Shape)-dims:]
+	kernel, err := expandInts(kernelSize, dims, 0, "kernel size", name)
+	if err != nil {
+		return nil, err
+	}
+	stride, err := expandInts(opts.Stride, dims, 0, "stride", name)
+	if err != nil {
+		return nil, err
+	}
+	padding, err := expandInts(opts.Padding, dims, 0, "padding", name)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int, dims)
+	for i := range out {
+		s := stride[i]
+		if s == 0 {
+			s = kernel[i]
+		}
+		out[i] = (in[i]-1)*s - 2*padding[i] + kernel[i]
+	}
+	if outputSize != nil {
+		if len(outputSize) < dims {
+			return nil, fmt.Errorf("%s expects an output size with %d spatial dimensions, got %v", name, dims, outputSize)
+		}
+		out = append([]int{}, outputSize[len(outputSize)-dims:]...)
+	}
+	shape := pooledShape(input.Shape, out)
+	plane := shapeSize(out)
+	for _, v := range idx {
+		if v < 0 || int(v) >= plane {
+			return nil, fmt.Errorf("%s: index %d is out of bounds for an output plane of size %d", name, v, plane)
+		}
+	}
+
+	y, err := scatterPlanes(input, idx, shape, planes, false)
+	if err != nil {
+		return nil, err
+	}
+	inShape := append([]int{}, input.Shape...)
+	err = tensors.Record(y, fmt.Sprintf("MaxUnpool%ddBackward", dims), []*tensors.Tensor{input}, nil, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		gx, err := gatherPlanes(grad, idx, inShape, planes)
+		return []*tensors.Tensor{gx}, err
+	})
+	return y, err
+}
+
+func shapeSize(shape []int) int {
+	size := 1
+	for _, d := range shape {
+		size *= d
+	}
+	return size
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
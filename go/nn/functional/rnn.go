@@ -0,0 +1,479 @@
+package functional
+
+import (
+	"errors"
+	"fmt"
+
+	"gotorch/tensors"
+)
+
+// RecurrentMode selects the cell of a recurrent network.
+type RecurrentMode string
+
+const (
+	ModeRNNTanh RecurrentMode = "RNN_TANH" // h' = tanh(W_ih x + b_ih + W_hh h + b_hh)
+	ModeRNNReLU RecurrentMode = "RNN_RELU" // h' = relu(W_ih x + b_ih + W_hh h + b_hh)
+	ModeLSTM    RecurrentMode = "LSTM"     // gates i, f, g, o stacked in that order
+	ModeGRU     RecurrentMode = "GRU"      // gates r, z, n stacked in that order
+)
+
+// gates returns the number of gates stacked in the weights of a cell.
+func (m RecurrentMode) gates() (int, error) {
+	switch m {
+	case ModeRNNTanh, ModeRNNReLU:
+		return 1, nil
+	case ModeLSTM:
+		return 4, nil
+	case ModeGRU:
+		return 3, nil
+	}
+	return 0, fmt.Errorf("unknown recurrent mode %q", string(m))
+}
+
+// states returns the number of state tensors a cell carries.
+func (m RecurrentMode) states() int {
+	if m == ModeLSTM {
+		return 2
+	}
+	return 1
+}
+
+// RecurrentWeights holds the weights of one layer and direction of a
+// recurrent network, in the PyTorch layout.
+type RecurrentWeights struct {
+	WeightIH *tensors.Tensor // (gates*hidden, input)
+	WeightHH *tensors.Tensor // (gates*hidden, hidden)
+	BiasIH   *tensors.Tensor // (gates*hidden), nil without bias
+	BiasHH   *tensors.Tensor // (gates*hidden), nil without bias
+}
+
+// RecurrentOptions configures Recurrent.
+type RecurrentOptions struct {
+	NumLayers     int
+	Bidirectional bool
+	// BatchFirst makes the input and output (batch, seq, feature) instead
+	// of (seq, batch, feature). The states are not affected.
+	BatchFirst bool
+	// Dropout is the probability of dropping the outputs of every layer
+	// but the last when Training is set. The masks are drawn from
+	// Generator, or the default generator when nil.
+	Dropout   float64
+	Training  bool
+	Generator *tensors.Generator
+}
+
+// Recurrent runs a multi-layer, optionally bidirectional recurrent network
+// over a (seq, batch, input) input, or a (seq, input) unbatched one.
+// weights holds one entry per layer and direction, layer by layer with the
+// forward direction first. states holds the initial hidden state and, for
+// ModeLSTM, the initial cell state, each of shape
+// (layers*directions, batch, hidden) or (layers*directions, hidden); nil
+// states start at zero. It returns the hidden states of the last layer for
+// every step, of shape (seq, batch, directions*hidden), and the final
+// states in the layout of the initial ones.
+func Recurrent(mode RecurrentMode, input *tensors.Tensor, states []*tensors.Tensor, weights []RecurrentWeights, opts RecurrentOptions) (*tensors.Tensor, []*tensors.Tensor, error) {
+	gates, err := mode.gates()
+	if err != nil {
+		return nil, nil, err
+	}
+	dirs := 1
+	if opts.Bidirectional {
+		dirs = 2
+	}
+	if opts.NumLayers <= 0 || len(weights) != opts.NumLayers*dirs {
+		return nil, nil, fmt.Errorf("Recurrent expects weights for %d layers and %d directions, got %d", opts.NumLayers, dirs, len(weights))
+	}
+	if len(states) > mode.states() {
+		return nil, nil, fmt.Errorf("Recurrent in mode %s takes at most %d initial states, got %d", mode, mode.states(), len(states))
+	}
+	batched := len(input.Shape) == 3
+	if !batched && len(input.Shape) != 2 {
+		return nil, nil, fmt.Errorf("Recurrent expects a 2-d or 3-d input, got shape %v", input.Shape)
+	}
+
+	x, stepDim := input, 0
+	if !batched {
+		if x, err = tensors.Reshape(input, []int{input.Shape[0], 1, input.Shape[1]}); err != nil {
+			return nil, nil, err
+		}
+	} else if opts.BatchFirst {
+		stepDim = 1
+	}
+	steps, batch := x.Shape[stepDim], x.Shape[1-stepDim]
+	if steps == 0 {
+		return nil, nil, errors.New("Recurrent expects a non-empty sequence")
+	}
+	xs := make([]*tensors.Tensor, steps)
+	for t := range xs {
+		step, err := tensors.Narrow(x, stepDim, t, 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if xs[t], err = tensors.Reshape(step, []int{batch, x.Shape[2]}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if weights[0].WeightHH == nil || len(weights[0].WeightHH.Shape) != 2 {
+		return nil, nil, errors.New("Recurrent expects 2-d weights")
+	}
+	hidden := weights[0].WeightHH.Shape[1]
+	initial := make([][]*tensors.Tensor, len(weights))
+	for k := range initial {
+		initial[k] = make([]*tensors.Tensor, mode.states())
+	}
+	for s := 0; s < mode.states(); s++ {
+		var state *tensors.Tensor
+		if s < len(states) {
+			state = states[s]
+		}
+		if state == nil {
+			for k := range initial {
+				if initial[k][s], err = zeros(input, []int{batch, hidden}); err != nil {
+					return nil, nil, err
+				}
+			}
+			continue
+		}
+		want := []int{len(weights), batch, hidden}
+		if !batched {
+			want = []int{len(weights), hidden}
+		}
+		if !equalInts(state.Shape, want) {
+			return nil, nil, fmt.Errorf("Recurrent expects initial states of shape %v, got %v", want, state.Shape)
+		}
+		for k := range initial {
+			slice, err := tensors.Narrow(state, 0, k, 1)
+			if err != nil {
+				return nil, nil, err
+			}
+			if initial[k][s], err = tensors.Reshape(slice, []int{batch, hidden}); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	finals := make([][]*tensors.Tensor, mode.states())
+	for layer := 0; layer < opts.NumLayers; layer++ {
+		outputs := make([][]*tensors.Tensor, dirs)
+		for d := 0; d < dirs; d++ {
+			k := layer*dirs + d
+			cell, err := newCellWeights(weights[k], gates, xs[0].Shape[1], hidden)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Recurrent layer %d: %w", layer, err)
+			}
+			var final []*tensors.Tensor
+			if outputs[d], final, err = runDirection(mode, cell, xs, initial[k], d == 1); err != nil {
+				return nil, nil, err
+			}
+			for s := range finals {
+				finals[s] = append(finals[s], final[s])
+			}
+		}
+		next := outputs[0]
+		if dirs == 2 {
+			next = make([]*tensors.Tensor, steps)
+			for t := range next {
+				if next[t], err = tensors.Cat([]*tensors.Tensor{outputs[0][t], outputs[1][t]}, 1); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+		if layer < opts.NumLayers-1 && opts.Training && opts.Dropout > 0 {
+			for t := range next {
+				if next[t], err = Dropout(next[t], opts.Dropout, true, opts.Generator); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+		xs = next
+	}
+
+	out, err := tensors.Stack(xs, stepDim)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !batched {
+		if out, err = tensors.Reshape(out, []int{steps, out.Shape[2]}); err != nil {
+			return nil, nil, err
+		}
+	}
+	result := make([]*tensors.Tensor, len(finals))
+	for s, final := range finals {
+		if result[s], err = tensors.Stack(final, 0); err != nil {
+			return nil, nil, err
+		}
+		if !batched {
+			if result[s], err = tensors.Reshape(result[s], []int{len(weights), hidden}); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return out, result, nil
+}
+
+// RNNCell runs a single step of an Elman cell with the given nonlinearity,
+// ModeRNNTanh or ModeRNNReLU, on a (batch, input) or (input) input. hx
+// has shape (batch, hidden) or (hidden) and may be nil for zeros.
+func RNNCell(input, hx, weightIH, weightHH, biasIH, biasHH *tensors.Tensor, nonlinearity RecurrentMode) (*tensors.Tensor, error) {
+	if nonlinearity != ModeRNNTanh && nonlinearity != ModeRNNReLU {
+		return nil, fmt.Errorf("RNNCell: unknown nonlinearity %q", string(nonlinearity))
+	}
+	out, err := runCell("RNNCell", nonlinearity, input, []*tensors.Tensor{hx}, RecurrentWeights{weightIH, weightHH, biasIH, biasHH})
+	if err != nil {
+		return nil, err
+	}
+	return out[0], nil
+}
+
+// LSTMCell runs a single step of an LSTM cell on a (batch, input) or
+// (input) input and returns the next hidden and cell states. hx and cx
+// have shape (batch, hidden) or (hidden) and may be nil for zeros.
+func LSTMCell(input, hx, cx, weightIH, weightHH, biasIH, biasHH *tensors.Tensor) (*tensors.Tensor, *tensors.Tensor, error) {
+	out, err := runCell("LSTMCell", ModeLSTM, input, []*tensors.Tensor{hx, cx}, RecurrentWeights{weightIH, weightHH, biasIH, biasHH})
+	if err != nil {
+		return nil, nil, err
+	}
+	return out[0], out[1], nil
+}
+
+// GRUCell runs a single step of a GRU cell on a (batch, input) or (input)
+// input. hx has shape (batch, hidden) or (hidden) and may be nil for zeros.
+func GRUCell(input, hx, weightIH, weightHH, biasIH, biasHH *tensors.Tensor) (*tensors.Tensor, error) {
+	out, err := runCell("GRUCell", ModeGRU, input, []*tensors.Tensor{hx}, RecurrentWeights{weightIH, weightHH, biasIH, biasHH})
+	if err != nil {
+		return nil, err
+	}
+	return out[0], nil
+}
+
+func runCell(name string, mode RecurrentMode, input *tensors.Tensor, states []*tensors.Tensor, weights RecurrentWeights) ([]*tensors.Tensor, error) {
+	gates, _ := mode.gates()
+	batched := len(input.Shape) == 2
+	if !batched && len(input.Shape) != 1 {
+		return nil, fmt.Errorf("%s expects a 1-d or 2-d input, got shape %v", name, input.Shape)
+	}
+	if weights.WeightIH == nil || weights.WeightHH == nil || len(weights.WeightHH.Shape) != 2 {
+		return nil, fmt.Errorf("%s expects 2-d weights", name)
+	}
+	hidden := weights.WeightHH.Shape[1]
+	x := input
+	var err error
+	if !batched {
+		if x, err = tensors.Reshape(input, []int{1, input.Shape[0]}); err != nil {
+			return nil, err
+		}
+	}
+	cell, err := newCellWeights(weights, gates, x.Shape[1], hidden)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	shape := []int{x.Shape[0], hidden}
+	state := make([]*tensors.Tensor, len(states))
+	for i, s := range states {
+		if s == nil {
+			if state[i], err = zeros(input, shape); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if len(s.Shape) != len(input.Shape) || s.Shape[len(s.Shape)-1] != hidden || (batched && s.Shape[0] != shape[0]) {
+			return nil, fmt.Errorf("%s expects states of shape %v, got %v", name, shape[2-len(input.Shape):], s.Shape)
+		}
+		if state[i], err = tensors.Reshape(s, shape); err != nil {
+			return nil, err
+		}
+	}
+	gi, err := affine(x, cell.wih, cell.bih)
+	if err != nil {
+		return nil, err
+	}
+	next, err := cellStep(mode, cell, gi, state)
+	if err != nil || batched {
+		return next, err
+	}
+	for i := range next {
+		if next[i], err = tensors.Reshape(next[i], []int{hidden}); err != nil {
+			return nil, err
+		}
+	}
+	return next, nil
+}
+
+// cellWeights holds the transposed weights of one cell, ready for
+// x @ w + b.
+type cellWeights struct {
+	wih, whh, bih, bhh *tensors.Tensor
+	hidden             int
+}
+
+func newCellWeights(w RecurrentWeights, gates, in, hidden int) (cellWeights, error) {
+	rows := gates * hidden
+	if w.WeightIH == nil || !equalInts(w.WeightIH.Shape, []int{rows, in}) {
+		return cellWeights{}, fmt.Errorf("expected an input weight of shape [%d %d]", rows, in)
+	}
+	if w.WeightHH == nil || !equalInts(w.WeightHH.Shape, []int{rows, hidden}) {
+		return cellWeights{}, fmt.Errorf("expected a hidden weight of shape [%d %d]", rows, hidden)
+	}
+	for _, b := range []*tensors.Tensor{w.BiasIH, w.BiasHH} {
+		if b != nil && !equalInts(b.Shape, []int{rows}) {
+			return cellWeights{}, fmt.Errorf("expected biases of shape [%d], got %v", rows, b.Shape)
+		}
+	}
+	wih, err := tensors.Adjoint(w.WeightIH)
+	if err != nil {
+		return cellWeights{}, err
+	}
+	whh, err := tensors.Adjoint(w.WeightHH)
+	if err != nil {
+		return cellWeights{}, err
+	}
+	return cellWeights{wih: wih, whh: whh, bih: w.BiasIH, bhh: w.BiasHH, hidden: hidden}, nil
+}
+
+// runDirection runs a cell over the steps xs, in reverse order if reverse
+// is set, and returns the hidden state after every step, in the order of
+// xs, together with the final states.
+func runDirection(mode RecurrentMode, cell cellWeights, xs, state []*tensors.Tensor, reverse bool) ([]*tensors.Tensor, []*tensors.Tensor, error) {
+	outputs := make([]*tensors.Tensor, len(xs))
+	for i := range xs {
+		t := i
+		if reverse {
+			t = len(xs) - 1 - i
+		}
+		gi, err := affine(xs[t], cell.wih, cell.bih)
+		if err != nil {
+			return nil, nil, err
+		}
+		if state, err = cellStep(mode, cell, gi, state); err != nil {
+			return nil, nil, err
+		}
+		outputs[t] = state[0]
+	}
+	return outputs, state, nil
+}
+
+// cellStep advances the states of a cell given the input projection gi.
+func cellStep(mode RecurrentMode, cell cellWeights, gi *tensors.Tensor, state []*tensors.Tensor) ([]*tensors.Tensor, error) {
+	h := state[0]
+	gh, err := affine(h, cell.whh, cell.bhh)
+	if err != nil {
+		return nil, err
+	}
+	switch mode {
+	case ModeRNNTanh, ModeRNNReLU:
+		pre, err := tensors.Add(gi, gh)
+		if err != nil {
+			return nil, err
+		}
+		act := Tanh
+		if mode == ModeRNNReLU {
+			act = ReLU
+		}
+		next, err := act(pre)
+		return []*tensors.Tensor{next}, err
+
+	case ModeLSTM:
+		pre, err := tensors.Add(gi, gh)
+		if err != nil {
+			return nil, err
+		}
+		g, err := splitGates(pre, cell.hidden, Sigmoid, Sigmoid, Tanh, Sigmoid)
+		if err != nil {
+			return nil, err
+		}
+		keep, err := tensors.Mul(g[1], state[1])
+		if err != nil {
+			return nil, err
+		}
+		write, err := tensors.Mul(g[0], g[2])
+		if err != nil {
+			return nil, err
+		}
+		c, err := tensors.Add(keep, write)
+		if err != nil {
+			return nil, err
+		}
+		tc, err := Tanh(c)
+		if err != nil {
+			return nil, err
+		}
+		next, err := tensors.Mul(g[3], tc)
+		return []*tensors.Tensor{next, c}, err
+
+	default: // ModeGRU
+		xi, err := splitGates(gi, cell.hidden, nil, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		hi, err := splitGates(gh, cell.hidden, nil, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var rz [2]*tensors.Tensor
+		for k := range rz {
+			pre, err := tensors.Add(xi[k], hi[k])
+			if err != nil {
+				return nil, err
+			}
+			if rz[k], err = Sigmoid(pre); err != nil {
+				return nil, err
+			}
+		}
+		reset, err := tensors.Mul(rz[0], hi[2])
+		if err != nil {
+			return nil, err
+		}
+		pre, err := tensors.Add(xi[2], reset)
+		if err != nil {
+			return nil, err
+		}
+		n, err := Tanh(pre)
+		if err != nil {
+			return nil, err
+		}
+		// h' = (1-z)*n + z*h = n + z*(h-n)
+		diff, err := tensors.Sub(h, n)
+		if err != nil {
+			return nil, err
+		}
+		update, err := tensors.Mul(rz[1], diff)
+		if err != nil {
+			return nil, err
+		}
+		next, err := tensors.Add(n, update)
+		return []*tensors.Tensor{next}, err
+	}
+}
+
+// splitGates cuts a (batch, gates*hidden) tensor into its gates and applies
+// the matching activation, if any, to each.
+func splitGates(t *tensors.Tensor, hidden int, acts ...func(*tensors.Tensor) (*tensors.Tensor, error)) ([]*tensors.Tensor, error) {
+	out := make([]*tensors.Tensor, len(acts))
+	for k, act := range acts {
+		g, err := tensors.Narrow(t, 1, k*hidden, hidden)
+		if err != nil {
+			return nil, err
+		}
+		if act != nil {
+			if g, err = act(g); err != nil {
+				return nil, err
+			}
+		}
+		out[k] = g
+	}
+	return out, nil
+}
+
+// affine returns x @ wt + b for a 2-d x, where b may be nil.
+func affine(x, wt, b *tensors.Tensor) (*tensors.Tensor, error) {
+	if b == nil {
+		return tensors.MatMul(x, wt)
+	}
+	return tensors.Addmm(b, x, wt, 1, 1)
+}
+
+// zeros returns a tensor of zeros with the data type of like.
+func zeros(like *tensors.Tensor, shape []int) (*tensors.Tensor, error) {
+	return fromFloat64(make([]float64, shapeSize(shape)), shape, like.Dtype)
+}
@@ -0,0 +1,143 @@
+package functional
+
+import (
+	"errors"
+	"math"
+
+	"gotorch/internal/parallel"
+	"gotorch/tensors"
+)
+
+// Softmax returns exp(x)/sum(exp(x)) along dim, which may be negative to
+// count from the end. The maximum of every slice is subtracted first, so
+// large inputs do not overflow.
+func Softmax(input *tensors.Tensor, dim int) (*tensors.Tensor, error) {
+	return softmax(input, dim, "Softmax", 1, false)
+}
+
+// LogSoftmax returns x - log(sum(exp(x))) along dim. It is more accurate
+// than taking the logarithm of Softmax.
+func LogSoftmax(input *tensors.Tensor, dim int) (*tensors.Tensor, error) {
+	return softmax(input, dim, "LogSoftmax", 1, true)
+}
+
+// Softmin returns Softmax(-x) along dim.
+func Softmin(input *tensors.Tensor, dim int) (*tensors.Tensor, error) {
+	return softmax(input, dim, "Softmin", -1, false)
+}
+
+// softmaxLayout views a tensor as (outer, n, inner) around the reduced
+// dimension.
+type softmaxLayout struct {
+	outer, n, inner int
+}
+
+func newSoftmaxLayout(shape []int, dim int) (softmaxLayout, error) {
+	if len(shape) == 0 {
+		if dim != 0 && dim != -1 {
+			return softmaxLayout{}, errors.New("dimension out of range")
+		}
+		return softmaxLayout{1, 1, 1}, nil
+	}
+	if dim < 0 {
+		dim += len(shape)
+	}
+	if dim < 0 || dim >= len(shape) {
+		return softmaxLayout{}, errors.New("dimension out of range")
+	}
+	return softmaxLayout{shapeSize(shape[:dim]), shape[dim], shapeSize(shape[dim+1:])}, nil
+}
+
+// softmax computes the softmax of sign*x, or its logarithm with log.
+func softmax(input *tensors.Tensor, dim int, name string, sign float64, log bool) (*tensors.Tensor, error) {
+	l, err := newSoftmaxLayout(input.Shape, dim)
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	switch x := input.Data.(type) {
+	case []float32:
+		data = softmaxForward(x, l, sign, log)
+	case []float64:
+		data = softmaxForward(x, l, sign, log)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+	out := &tensors.Tensor{Shape: append([]int{}, input.Shape...), Data: data, Dtype: input.Dtype}
+
+	py := out.Detach()
+	err = tensors.Record(out, name+"Backward", []*tensors.Tensor{input}, []*tensors.Tensor{py}, func(grad *tensors.Tensor) ([]*tensors.Tensor, error) {
+		gx := &tensors.Tensor{Shape: append([]int{}, grad.Shape...), Dtype: grad.Dtype}
+		switch gy := grad.Data.(type) {
+		case []float32:
+			gx.Data = softmaxBackward(gy, py.Data.([]float32), l, sign, log)
+		case []float64:
+			gx.Data = softmaxBackward(gy, py.Data.([]float64), l, sign, log)
+		default:
+			return nil, errors.New("unsupported data type")
+		}
+		return []*tensors.Tensor{gx}, nil
+	})
+	return out, err
+}
+
+func softmaxForward[T float32 | float64](x []T, l softmaxLayout, sign float64, log bool) []T {
+	y := make([]T, len(x))
+	parallel.For(l.outer*l.inner, l.n*pointwiseCost, func(start, end int) {
+		for row := start; row < end; row++ {
+			base := row/l.inner*l.n*l.inner + row%l.inner
+			m := math.Inf(-1)
+			for k := 0; k < l.n; k++ {
+				m = math.Max(m, sign*float64(x[base+k*l.inner]))
+			}
+			if math.IsInf(m, 0) {
+				// Infinite inputs give the same zeros and NaNs as PyTorch
+				// without the shift.
+				m = 0
+			}
+			var sum float64
+			for k := 0; k < l.n; k++ {
+				sum += math.Exp(sign*float64(x[base+k*l.inner]) - m)
+			}
+			lse := m + math.Log(sum)
+			for k := 0; k < l.n; k++ {
+				i := base + k*l.inner
+				if log {
+					y[i] = T(sign*float64(x[i]) - lse)
+				} else {
+					y[i] = T(math.Exp(sign*float64(x[i]) - lse))
+				}
+			}
+		}
+	})
+	return y
+}
+
+// softmaxBackward returns sign*y*(gy - sum(gy*y)) for softmax and
+// gy - exp(y)*sum(gy) for its logarithm.
+func softmaxBackward[T float32 | float64](gy, y []T, l softmaxLayout, sign float64, log bool) []T {
+	gx := make([]T, len(gy))
+	parallel.For(l.outer*l.inner, l.n*pointwiseCost, func(start, end int) {
+		for row := start; row < end; row++ {
+			base := row/l.inner*l.n*l.inner + row%l.inner
+			var sum float64
+			for k := 0; k < l.n; k++ {
+				i := base + k*l.inner
+				if log {
+					sum += float64(gy[i])
+				} else {
+					sum += float64(gy[i]) * float64(y[i])
+				}
+			}
+			for k := 0; k < l.n; k++ {
+				i := base + k*l.inner
+				if log {
+					gx[i] = T(sign * (float64(gy[i]) - math.Exp(float64(y[i]))*sum))
+				} else {
+					gx[i] = T(sign * float64(y[i]) * (float64(gy[i]) - sum))
+				}
+			}
+		}
+	})
+	return gx
+}
@@ -0,0 +1,160 @@
+package nn
+
+import (
+	"sync/atomic"
+
+	"gotorch/tensors"
+)
+
+var nextHookID atomic.Int64
+
+type hook[F any] struct {
+	id int64
+	fn F
+}
+
+type moduleHooks struct {
+	forwardPre  []hook[func(m Module, inputs []*tensors.Tensor) []*tensors.Tensor]
+	forward     []hook[func(m Module, inputs []*tensors.Tensor, output *tensors.Tensor) *tensors.Tensor]
+	backwardPre []hook[func(m Module, gradOutput *tensors.Tensor) *tensors.Tensor]
+	backward    []hook[func(m Module, gradInputs []*tensors.Tensor, gradOutput *tensors.Tensor)]
+}
+
+func addHook[F any](hooks *[]hook[F], fn F) *tensors.HookHandle {
+	id := nextHookID.Add(1)
+	*hooks = append(*hooks, hook[F]{id: id, fn: fn})
+	return tensors.NewHookHandle(func() {
+		for i, h := range *hooks {
+			if h.id == id {
+				*hooks = append((*hooks)[:i:i], (*hooks)[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+// RegisterForwardPreHook registers fn to run before every Call of the
+// module. A non-nil return value replaces the inputs.
+func (b *Base) RegisterForwardPreHook(fn func(m Module, inputs []*tensors.Tensor) []*tensors.Tensor) *tensors.HookHandle {
+	return addHook(&b.hooks.forwardPre, fn)
+}
+
+// RegisterForwardHook registers fn to run after every Call of the module. A
+// non-nil return value replaces the output.
+func (b *Base) RegisterForwardHook(fn func(m Module, inputs []*tensors.Tensor, output *tensors.Tensor) *tensors.Tensor) *tensors.HookHandle {
+	return addHook(&b.hooks.forward, fn)
+}
+
+// RegisterFullBackwardPreHook registers fn to run when the gradient with
+// respect to the module output has been computed. A non-nil return value
+// replaces that gradient.
+func (b *Base) RegisterFullBackwardPreHook(fn func(m Module, gradOutput *tensors.Tensor) *tensors.Tensor) *tensors.HookHandle {
+	return addHook(&b.hooks.backwardPre, fn)
+}
+
+// RegisterFullBackwardHook registers fn to run once per backward pass that
+// reaches the module output, after the gradients with respect to the
+// module inputs have been computed. gradInputs is indexed like the inputs;
+// entries for inputs that do not require grad or that the output does not
+// depend on are nil.
+func (b *Base) RegisterFullBackwardHook(fn func(m Module, gradInputs []*tensors.Tensor, gradOutput *tensors.Tensor)) *tensors.HookHandle {
+	return addHook(&b.hooks.backward, fn)
+}
+
+// Call runs m.Forward on inputs together with the hooks registered on m.
+// Containers call their children through Call, so hooks on nested modules
+// fire as well.
+func Call(m Module, inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	hooks := &m.base().hooks
+	for _, h := range hooks.forwardPre {
+		if replaced := h.fn(m, inputs); replaced != nil {
+			inputs = replaced
+		}
+	}
+
+	var state *backwardHookState
+	if len(hooks.backward) > 0 && tensors.IsGradEnabled() {
+		var err error
+		if inputs, state, err = wrapInputs(inputs); err != nil {
+			return nil, err
+		}
+	}
+
+	output, err := m.Forward(inputs...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range hooks.forward {
+		if replaced := h.fn(m, inputs, output); replaced != nil {
+			output = replaced
+		}
+	}
+
+	if (len(hooks.backwardPre) > 0 || state != nil) && output.RequiresGrad {
+		// Hook a fresh view so the hooks do not stick to a tensor the
+		// module merely passed through.
+		if output, err = tensors.Reshape(output, output.Shape); err != nil {
+			return nil, err
+		}
+		if _, err = output.RegisterHook(func(grad *tensors.Tensor) *tensors.Tensor {
+			for _, h := range hooks.backwardPre {
+				if replaced := h.fn(m, grad); replaced != nil {
+					grad = replaced
+				}
+			}
+			if state != nil {
+				state.gradOutput = grad
+				// The input gradients arrive later in the same pass, if at
+				// all. Gradient hooks only run during a pass, so queueing
+				// cannot fail.
+				_ = tensors.QueueCallback(func() { state.fire(m) })
+			}
+			return grad
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return output, nil
+}
+
+type backwardHookState struct {
+	gradInputs []*tensors.Tensor
+	gradOutput *tensors.Tensor
+}
+
+// fire runs the full backward hooks of m with the gradients collected in
+// the pass and clears them for the next one.
+func (s *backwardHookState) fire(m Module) {
+	for _, h := range m.base().hooks.backward {
+		h.fn(m, s.gradInputs, s.gradOutput)
+	}
+	s.gradInputs = make([]*tensors.Tensor, len(s.gradInputs))
+	s.gradOutput = nil
+}
+
+// wrapInputs replaces every input that requires grad with a view whose
+// gradient hook collects the input gradients for the full backward hooks.
+func wrapInputs(inputs []*tensors.Tensor) ([]*tensors.Tensor, *backwardHookState, error) {
+	state := &backwardHookState{gradInputs: make([]*tensors.Tensor, len(inputs))}
+	wrapped := make([]*tensors.Tensor, len(inputs))
+	for i, in := range inputs {
+		wrapped[i] = in
+		if in == nil || !in.RequiresGrad {
+			continue
+		}
+		view, err := tensors.Reshape(in, in.Shape)
+		if err != nil {
+			return nil, nil, err
+		}
+		_, err = view.RegisterHook(func(grad *tensors.Tensor) *tensors.Tensor {
+			state.gradInputs[i] = grad
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		wrapped[i] = view
+	}
+	return wrapped, state, nil
+}
@@ -0,0 +1,62 @@
+package nn
+
+import (
+	"testing"
+
+	"gotorch/tensors"
+)
+
+// firstOnly squares its first input and ignores the second.
+type firstOnly struct {
+	Base
+}
+
+func (m *firstOnly) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	return tensors.Mul(inputs[0], inputs[0])
+}
+
+func TestFullBackwardHookWithUnusedInput(t *testing.T) {
+	a, err := tensors.NewTensor([]float64{1, 2}, []int{2}, "float64", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := tensors.NewTensor([]float64{3, 4}, []int{2}, "float64", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &firstOnly{}
+	calls := 0
+	var gradInputs []*tensors.Tensor
+	m.RegisterFullBackwardHook(func(_ Module, gi []*tensors.Tensor, _ *tensors.Tensor) {
+		calls++
+		gradInputs = gi
+	})
+	out, err := Call(m, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ones, err := tensors.NewOnes([]int{2}, tensors.Float64{}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Backward(ones); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("hook ran %d times, want 1", calls)
+	}
+	if len(gradInputs) != 2 {
+		t.Fatalf("got %d input gradients, want 2", len(gradInputs))
+	}
+	if gradInputs[0] == nil {
+		t.Fatal("gradient of the used input is nil")
+	}
+	if got := gradInputs[0].Data.([]float64); got[0] != 2 || got[1] != 4 {
+		t.Errorf("gradient of the used input = %v, want [2 4]", got)
+	}
+	if gradInputs[1] != nil {
+		t.Errorf("gradient of the unused input = %v, want nil", gradInputs[1].Data)
+	}
+}
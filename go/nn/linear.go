@@ -0,0 +1,124 @@
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gotorch/nn/functional"
+	"gotorch/tensors"
+)
+
+// Identity returns its input unchanged. It is useful as a placeholder for
+// an optional layer.
+type Identity struct {
+	Base
+}
+
+// NewIdentity returns an Identity module.
+func NewIdentity() *Identity {
+	return &Identity{}
+}
+
+func (m *Identity) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("Identity takes at least one input")
+	}
+	return inputs[0], nil
+}
+
+// Linear applies y = x W^T + b to the last dimension of its input, which may
+// have any number of leading batch dimensions.
+type Linear struct {
+	Base
+	InFeatures  int
+	OutFeatures int
+	Weight      *Parameter // (out_features, in_features)
+	Bias        *Parameter // (out_features), nil when created without bias
+}
+
+// NewLinear returns a Linear layer mapping in features to out features,
+// initialized like PyTorch: the weight is Kaiming uniform with a = sqrt(5)
+// and the bias uniform, both within ±1/sqrt(in).
+func NewLinear(in, out int, bias bool) (*Linear, error) {
+	if in <= 0 || out <= 0 {
+		return nil, fmt.Errorf("Linear requires positive feature counts, got %d and %d", in, out)
+	}
+	m := &Linear{InFeatures: in, OutFeatures: out}
+	w, err := uniform([]int{out, in}, kaimingUniformBound(in, math.Sqrt(5)))
+	if err != nil {
+		return nil, err
+	}
+	m.Weight = NewParameter(w)
+	if bias {
+		b, err := uniform([]int{out}, 1/math.Sqrt(float64(in)))
+		if err != nil {
+			return nil, err
+		}
+		m.Bias = NewParameter(b)
+	}
+	return m, Register(m)
+}
+
+func (m *Linear) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(inputs) != 1 {
+		return nil, errors.New("Linear takes exactly one input")
+	}
+	return functional.Linear(inputs[0], m.Weight.Tensor, parameterTensor(m.Bias))
+}
+
+// Bilinear applies y = x1^T A x2 + b to the last dimensions of its two
+// inputs.
+type Bilinear struct {
+	Base
+	In1Features int
+	In2Features int
+	OutFeatures int
+	Weight      *Parameter // (out_features, in1_features, in2_features)
+	Bias        *Parameter // (out_features), nil when created without bias
+}
+
+// NewBilinear returns a Bilinear layer whose weight and bias are drawn
+// uniformly within ±1/sqrt(in1) as in PyTorch.
+func NewBilinear(in1, in2, out int, bias bool) (*Bilinear, error) {
+	if in1 <= 0 || in2 <= 0 || out <= 0 {
+		return nil, fmt.Errorf("Bilinear requires positive feature counts, got %d, %d and %d", in1, in2, out)
+	}
+	m := &Bilinear{In1Features: in1, In2Features: in2, OutFeatures: out}
+	bound := 1 / math.Sqrt(float64(in1))
+	w, err := uniform([]int{out, in1, in2}, bound)
+	if err != nil {
+		return nil, err
+	}
+	m.Weight = NewParameter(w)
+	if bias {
+		b, err := uniform([]int{out}, bound)
+		if err != nil {
+			return nil, err
+		}
+		m.Bias = NewParameter(b)
+	}
+	return m, Register(m)
+}
+
+func (m *Bilinear) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(inputs) != 2 {
+		return nil, errors.New("Bilinear takes exactly two inputs")
+	}
+	return functional.Bilinear(inputs[0], inputs[1], m.Weight.Tensor, parameterTensor(m.Bias))
+}
+
+// kaimingUniformBound returns the bound of the Kaiming uniform distribution
+// for a leaky ReLU with negative slope a: gain * sqrt(3 / fanIn).
+func kaimingUniformBound(fanIn int, a float64) float64 {
+	gain := math.Sqrt(2 / (1 + a*a))
+	return gain * math.Sqrt(3/float64(fanIn))
+}
+
+// parameterTensor returns the tensor of p, or nil for a nil parameter.
+func parameterTensor(p *Parameter) *tensors.Tensor {
+	if p == nil {
+		return nil
+	}
+	return p.Tensor
+}
@@ -0,0 +1,345 @@
+package nn
+
+import (
+	"errors"
+
+	"gotorch/nn/functional"
+	"gotorch/tensors"
+)
+
+// The loss modules take an input and a target and reduce the per-element
+// losses by Reduction, one of functional.ReductionNone, ReductionMean or
+// ReductionSum. PyTorch defaults the reduction to the mean.
+
+// MSELoss measures the squared error between input and target.
+type MSELoss struct {
+	Base
+	Reduction string
+}
+
+// NewMSELoss returns an MSELoss.
+func NewMSELoss(reduction string) *MSELoss { return &MSELoss{Reduction: reduction} }
+
+func (m *MSELoss) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, target, err := lossInputs(inputs, "MSELoss")
+	if err != nil {
+		return nil, err
+	}
+	return functional.MSELoss(input, target, m.Reduction)
+}
+
+// L1Loss measures the absolute error between input and target.
+type L1Loss struct {
+	Base
+	Reduction string
+}
+
+// NewL1Loss returns an L1Loss.
+func NewL1Loss(reduction string) *L1Loss { return &L1Loss{Reduction: reduction} }
+
+func (m *L1Loss) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, target, err := lossInputs(inputs, "L1Loss")
+	if err != nil {
+		return nil, err
+	}
+	return functional.L1Loss(input, target, m.Reduction)
+}
+
+// SmoothL1Loss is quadratic for errors below Beta and linear above.
+type SmoothL1Loss struct {
+	Base
+	Reduction string
+	Beta      float64
+}
+
+// NewSmoothL1Loss returns a SmoothL1Loss. PyTorch defaults beta to 1.
+func NewSmoothL1Loss(reduction string, beta float64) *SmoothL1Loss {
+	return &SmoothL1Loss{Reduction: reduction, Beta: beta}
+}
+
+func (m *SmoothL1Loss) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, target, err := lossInputs(inputs, "SmoothL1Loss")
+	if err != nil {
+		return nil, err
+	}
+	return functional.SmoothL1Loss(input, target, m.Reduction, m.Beta)
+}
+
+// HuberLoss is quadratic for errors below Delta and linear with slope
+// Delta above.
+type HuberLoss struct {
+	Base
+	Reduction string
+	Delta     float64
+}
+
+// NewHuberLoss returns a HuberLoss. PyTorch defaults delta to 1.
+func NewHuberLoss(reduction string, delta float64) *HuberLoss {
+	return &HuberLoss{Reduction: reduction, Delta: delta}
+}
+
+func (m *HuberLoss) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, target, err := lossInputs(inputs, "HuberLoss")
+	if err != nil {
+		return nil, err
+	}
+	return functional.HuberLoss(input, target, m.Reduction, m.Delta)
+}
+
+// NLLLoss is the negative log-likelihood of class indices under
+// log-probabilities.
+type NLLLoss struct {
+	Base
+	Weight      *tensors.Tensor // (classes), nil for equal weights
+	IgnoreIndex int
+	Reduction   string
+}
+
+// NewNLLLoss returns an NLLLoss. weight may be nil; PyTorch defaults
+// ignoreIndex to -100.
+func NewNLLLoss(weight *tensors.Tensor, ignoreIndex int, reduction string) *NLLLoss {
+	m := &NLLLoss{Weight: weight, IgnoreIndex: ignoreIndex, Reduction: reduction}
+	m.RegisterBuffer("weight", weight, true)
+	return m
+}
+
+func (m *NLLLoss) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, target, err := lossInputs(inputs, "NLLLoss")
+	if err != nil {
+		return nil, err
+	}
+	return functional.NLLLoss(input, target, m.Weight, m.IgnoreIndex, m.Reduction)
+}
+
+// CrossEntropyLoss is the cross entropy between logits and class indices
+// or class probabilities.
+type CrossEntropyLoss struct {
+	Base
+	Weight         *tensors.Tensor // (classes), nil for equal weights
+	IgnoreIndex    int
+	Reduction      string
+	LabelSmoothing float64
+}
+
+// NewCrossEntropyLoss returns a CrossEntropyLoss. weight may be nil;
+// PyTorch defaults ignoreIndex to -100 and labelSmoothing to 0.
+func NewCrossEntropyLoss(weight *tensors.Tensor, ignoreIndex int, reduction string, labelSmoothing float64) *CrossEntropyLoss {
+	m := &CrossEntropyLoss{Weight: weight, IgnoreIndex: ignoreIndex, Reduction: reduction, LabelSmoothing: labelSmoothing}
+	m.RegisterBuffer("weight", weight, true)
+	return m
+}
+
+func (m *CrossEntropyLoss) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, target, err := lossInputs(inputs, "CrossEntropyLoss")
+	if err != nil {
+		return nil, err
+	}
+	return functional.CrossEntropy(input, target, m.Weight, m.IgnoreIndex, m.Reduction, m.LabelSmoothing)
+}
+
+// BCELoss is the binary cross entropy between probabilities and targets.
+type BCELoss struct {
+	Base
+	Weight    *tensors.Tensor // broadcasts to the input, nil for equal weights
+	Reduction string
+}
+
+// NewBCELoss returns a BCELoss. weight may be nil.
+func NewBCELoss(weight *tensors.Tensor, reduction string) *BCELoss {
+	m := &BCELoss{Weight: weight, Reduction: reduction}
+	m.RegisterBuffer("weight", weight, true)
+	return m
+}
+
+func (m *BCELoss) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, target, err := lossInputs(inputs, "BCELoss")
+	if err != nil {
+		return nil, err
+	}
+	return functional.BinaryCrossEntropy(input, target, m.Weight, m.Reduction)
+}
+
+// BCEWithLogitsLoss is the binary cross entropy between the sigmoid of
+// logits and targets, computed stably from the logits.
+type BCEWithLogitsLoss struct {
+	Base
+	Weight    *tensors.Tensor // broadcasts to the input, nil for equal weights
+	Reduction string
+	PosWeight *tensors.Tensor // weight of positive targets, nil for 1
+}
+
+// NewBCEWithLogitsLoss returns a BCEWithLogitsLoss. weight and posWeight
+// may be nil.
+func NewBCEWithLogitsLoss(weight *tensors.Tensor, reduction string, posWeight *tensors.Tensor) *BCEWithLogitsLoss {
+	m := &BCEWithLogitsLoss{Weight: weight, Reduction: reduction, PosWeight: posWeight}
+	m.RegisterBuffer("weight", weight, true)
+	m.RegisterBuffer("pos_weight", posWeight, true)
+	return m
+}
+
+func (m *BCEWithLogitsLoss) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, target, err := lossInputs(inputs, "BCEWithLogitsLoss")
+	if err != nil {
+		return nil, err
+	}
+	return functional.BinaryCrossEntropyWithLogits(input, target, m.Weight, m.PosWeight, m.Reduction)
+}
+
+// KLDivLoss is the Kullback-Leibler divergence of log-probabilities from a
+// target distribution. Reduction may also be
+// functional.ReductionBatchMean, which matches the mathematical
+// definition.
+type KLDivLoss struct {
+	Base
+	Reduction string
+	LogTarget bool
+}
+
+// NewKLDivLoss returns a KLDivLoss. With logTarget the target holds
+// log-probabilities.
+func NewKLDivLoss(reduction string, logTarget bool) *KLDivLoss {
+	return &KLDivLoss{Reduction: reduction, LogTarget: logTarget}
+}
+
+func (m *KLDivLoss) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, target, err := lossInputs(inputs, "KLDivLoss")
+	if err != nil {
+		return nil, err
+	}
+	return functional.KLDiv(input, target, m.Reduction, m.LogTarget)
+}
+
+// MarginRankingLoss ranks pairs of inputs: it penalizes x1 falling below
+// x2 by less than Margin where the target is 1, and the reverse where it
+// is -1. Forward takes x1, x2 and the target.
+type MarginRankingLoss struct {
+	Base
+	Margin    float64
+	Reduction string
+}
+
+// NewMarginRankingLoss returns a MarginRankingLoss. PyTorch defaults
+// margin to 0.
+func NewMarginRankingLoss(margin float64, reduction string) *MarginRankingLoss {
+	return &MarginRankingLoss{Margin: margin, Reduction: reduction}
+}
+
+func (m *MarginRankingLoss) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(inputs) != 3 {
+		return nil, errors.New("MarginRankingLoss takes two inputs and a target")
+	}
+	return functional.MarginRankingLoss(inputs[0], inputs[1], inputs[2], m.Margin, m.Reduction)
+}
+
+// TripletMarginLoss pulls anchors towards positives and pushes them at
+// least Margin further from negatives, measured by the P-norm distance.
+// Forward takes the anchor, positive and negative.
+type TripletMarginLoss struct {
+	Base
+	Margin    float64
+	P         float64
+	Eps       float64
+	Swap      bool // use the positive-negative distance when it is smaller
+	Reduction string
+}
+
+// NewTripletMarginLoss returns a TripletMarginLoss. PyTorch defaults margin
+// to 1, p to 2, eps to 1e-6 and swap to false.
+func NewTripletMarginLoss(margin, p, eps float64, swap bool, reduction string) *TripletMarginLoss {
+	return &TripletMarginLoss{Margin: margin, P: p, Eps: eps, Swap: swap, Reduction: reduction}
+}
+
+func (m *TripletMarginLoss) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(inputs) != 3 {
+		return nil, errors.New("TripletMarginLoss takes an anchor, a positive and a negative")
+	}
+	return functional.TripletMarginLoss(inputs[0], inputs[1], inputs[2], m.Margin, m.P, m.Eps, m.Swap, m.Reduction)
+}
+
+// CosineEmbeddingLoss makes pairs labeled 1 similar and pairs labeled -1
+// dissimilar beyond Margin in cosine similarity. Forward takes x1, x2 and
+// the target.
+type CosineEmbeddingLoss struct {
+	Base
+	Margin    float64
+	Reduction string
+}
+
+// NewCosineEmbeddingLoss returns a CosineEmbeddingLoss. PyTorch defaults
+// margin to 0.
+func NewCosineEmbeddingLoss(margin float64, reduction string) *CosineEmbeddingLoss {
+	return &CosineEmbeddingLoss{Margin: margin, Reduction: reduction}
+}
+
+func (m *CosineEmbeddingLoss) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(inputs) != 3 {
+		return nil, errors.New("CosineEmbeddingLoss takes two inputs and a target")
+	}
+	return functional.CosineEmbeddingLoss(inputs[0], inputs[1], inputs[2], m.Margin, m.Reduction)
+}
+
+// MultiLabelSoftMarginLoss is the per-class binary cross entropy of
+// logits, averaged over the classes of every sample.
+type MultiLabelSoftMarginLoss struct {
+	Base
+	Weight    *tensors.Tensor // (classes), nil for equal weights
+	Reduction string
+}
+
+// NewMultiLabelSoftMarginLoss returns a MultiLabelSoftMarginLoss. weight
+// may be nil.
+func NewMultiLabelSoftMarginLoss(weight *tensors.Tensor, reduction string) *MultiLabelSoftMarginLoss {
+	m := &MultiLabelSoftMarginLoss{Weight: weight, Reduction: reduction}
+	m.RegisterBuffer("weight", weight, true)
+	return m
+}
+
+func (m *MultiLabelSoftMarginLoss) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, target, err := lossInputs(inputs, "MultiLabelSoftMarginLoss")
+	if err != nil {
+		return nil, err
+	}
+	return functional.MultiLabelSoftMarginLoss(input, target, m.Weight, m.Reduction)
+}
+
+// CTCLoss is the connectionist temporal classification loss of unaligned
+// label sequences under per-step log-probabilities.
+type CTCLoss struct {
+	Base
+	Blank        int
+	Reduction    string
+	ZeroInfinity bool
+}
+
+// NewCTCLoss returns a CTCLoss. PyTorch defaults blank to 0 and
+// zeroInfinity to false.
+func NewCTCLoss(blank int, reduction string, zeroInfinity bool) *CTCLoss {
+	return &CTCLoss{Blank: blank, Reduction: reduction, ZeroInfinity: zeroInfinity}
+}
+
+// Forward takes the log-probabilities, the targets and the int64 input and
+// target lengths; see functional.CTCLoss for their shapes.
+func (m *CTCLoss) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(inputs) != 4 {
+		return nil, errors.New("CTCLoss takes log-probabilities, targets, input lengths and target lengths")
+	}
+	var lengths [2][]int
+	for i, t := range inputs[2:] {
+		values, ok := t.Data.([]int64)
+		if !ok || len(t.Shape) > 1 {
+			return nil, errors.New("CTCLoss expects 0-d or 1-d int64 lengths")
+		}
+		for _, v := range values {
+			lengths[i] = append(lengths[i], int(v))
+		}
+	}
+	return functional.CTCLoss(inputs[0], inputs[1], lengths[0], lengths[1], m.Blank, m.Reduction, m.ZeroInfinity)
+}
+
+// lossInputs returns the input and target of a loss module.
+func lossInputs(inputs []*tensors.Tensor, name string) (*tensors.Tensor, *tensors.Tensor, error) {
+	if len(inputs) != 2 {
+		return nil, nil, errors.New(name + " takes an input and a target")
+	}
+	return inputs[0], inputs[1], nil
+}
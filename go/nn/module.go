@@ -0,0 +1,236 @@
+// Package nn provides neural network layers built on the tensors package.
+package nn
+
+import (
+	"fmt"
+	"strings"
+
+	"gotorch/tensors"
+)
+
+// Module is a layer or a composition of layers. Concrete modules embed Base,
+// which keeps track of their parameters, buffers and child modules, and
+// implement Forward.
+type Module interface {
+	Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error)
+
+	Parameters() []*Parameter
+	NamedParameters() []NamedParameter
+	Buffers() []*tensors.Tensor
+	NamedBuffers() []NamedBuffer
+	Children() []Module
+	NamedChildren() []NamedModule
+	Train()
+	Eval()
+	IsTraining() bool
+	ZeroGrad()
+	StateDict() map[string]*tensors.Tensor
+	LoadStateDict(stateDict map[string]*tensors.Tensor, strict bool) (LoadResult, error)
+
+	base() *Base
+}
+
+// NamedParameter pairs a parameter with its dotted path inside a module.
+type NamedParameter struct {
+	Name      string
+	Parameter *Parameter
+}
+
+// NamedBuffer pairs a buffer with its dotted path inside a module.
+type NamedBuffer struct {
+	Name   string
+	Buffer *tensors.Tensor
+}
+
+// NamedModule pairs a child module with its name.
+type NamedModule struct {
+	Name   string
+	Module Module
+}
+
+type buffer struct {
+	name       string
+	tensor     *tensors.Tensor
+	persistent bool
+}
+
+// Base implements the bookkeeping shared by every Module. The zero value is
+// an empty module in training mode.
+type Base struct {
+	params   []NamedParameter
+	buffers  []buffer
+	children []NamedModule
+	eval     bool
+	hooks    moduleHooks
+}
+
+func (b *Base) base() *Base {
+	return b
+}
+
+// RegisterParameter adds a parameter under name, replacing any parameter
+// already registered under it. p may be nil for optional parameters such
+// as a disabled bias; nil parameters are skipped by Parameters and
+// StateDict. It panics if name is empty or contains a dot.
+func (b *Base) RegisterParameter(name string, p *Parameter) {
+	checkName(name)
+	for i := range b.params {
+		if b.params[i].Name == name {
+			b.params[i].Parameter = p
+			return
+		}
+	}
+	b.params = append(b.params, NamedParameter{Name: name, Parameter: p})
+}
+
+// RegisterBuffer adds a tensor that is part of the module state but is not
+// trained, such as running statistics. Persistent buffers are included in
+// the state dict. It panics if name is empty or contains a dot.
+func (b *Base) RegisterBuffer(name string, t *tensors.Tensor, persistent bool) {
+	checkName(name)
+	for i := range b.buffers {
+		if b.buffers[i].name == name {
+			b.buffers[i] = buffer{name: name, tensor: t, persistent: persistent}
+			return
+		}
+	}
+	b.buffers = append(b.buffers, buffer{name: name, tensor: t, persistent: persistent})
+}
+
+// RegisterModule adds a child module under name, replacing any child
+// already registered under it. It panics if name is empty or contains a dot.
+func (b *Base) RegisterModule(name string, m Module) {
+	checkName(name)
+	for i := range b.children {
+		if b.children[i].Name == name {
+			b.children[i].Module = m
+			return
+		}
+	}
+	b.children = append(b.children, NamedModule{Name: name, Module: m})
+}
+
+func checkName(name string) {
+	if name == "" || strings.Contains(name, ".") {
+		panic(fmt.Sprintf("nn: invalid name %q", name))
+	}
+}
+
+// Parameters returns the parameters of the module and all of its
+// descendants. A parameter shared between modules is returned once.
+func (b *Base) Parameters() []*Parameter {
+	named := b.NamedParameters()
+	params := make([]*Parameter, len(named))
+	for i, np := range named {
+		params[i] = np.Parameter
+	}
+	return params
+}
+
+// NamedParameters returns the parameters of the module and all of its
+// descendants with their dotted paths, in registration order.
+func (b *Base) NamedParameters() []NamedParameter {
+	var named []NamedParameter
+	seen := make(map[*Parameter]bool)
+	b.walk("", func(prefix string, m *Base) {
+		for _, np := range m.params {
+			if np.Parameter == nil || seen[np.Parameter] {
+				continue
+			}
+			seen[np.Parameter] = true
+			named = append(named, NamedParameter{Name: prefix + np.Name, Parameter: np.Parameter})
+		}
+	})
+	return named
+}
+
+// Buffers returns the buffers of the module and all of its descendants.
+func (b *Base) Buffers() []*tensors.Tensor {
+	named := b.NamedBuffers()
+	buffers := make([]*tensors.Tensor, len(named))
+	for i, nb := range named {
+		buffers[i] = nb.Buffer
+	}
+	return buffers
+}
+
+// NamedBuffers returns the buffers of the module and all of its descendants
+// with their dotted paths.
+func (b *Base) NamedBuffers() []NamedBuffer {
+	var named []NamedBuffer
+	seen := make(map[*tensors.Tensor]bool)
+	b.walk("", func(prefix string, m *Base) {
+		for _, buf := range m.buffers {
+			if buf.tensor == nil || seen[buf.tensor] {
+				continue
+			}
+			seen[buf.tensor] = true
+			named = append(named, NamedBuffer{Name: prefix + buf.name, Buffer: buf.tensor})
+		}
+	})
+	return named
+}
+
+// Children returns the direct child modules.
+func (b *Base) Children() []Module {
+	children := make([]Module, 0, len(b.children))
+	for _, c := range b.children {
+		if c.Module != nil {
+			children = append(children, c.Module)
+		}
+	}
+	return children
+}
+
+// NamedChildren returns the direct child modules with their names.
+func (b *Base) NamedChildren() []NamedModule {
+	children := make([]NamedModule, 0, len(b.children))
+	for _, c := range b.children {
+		if c.Module != nil {
+			children = append(children, c)
+		}
+	}
+	return children
+}
+
+// Train puts the module and all of its descendants in training mode.
+func (b *Base) Train() {
+	b.walk("", func(_ string, m *Base) { m.eval = false })
+}
+
+// Eval puts the module and all of its descendants in evaluation mode.
+func (b *Base) Eval() {
+	b.walk("", func(_ string, m *Base) { m.eval = true })
+}
+
+// IsTraining reports whether the module is in training mode.
+func (b *Base) IsTraining() bool {
+	return !b.eval
+}
+
+// ZeroGrad clears the gradients of every parameter.
+func (b *Base) ZeroGrad() {
+	for _, p := range b.Parameters() {
+		p.Grad = nil
+	}
+}
+
+// walk calls fn on the module and every descendant, depth first, with the
+// dotted prefix of that module's names.
+func (b *Base) walk(prefix string, fn func(prefix string, m *Base)) {
+	visited := make(map[*Base]bool)
+	var visit func(prefix string, m *Base)
+	visit = func(prefix string, m *Base) {
+		if visited[m] {
+			return
+		}
+		visited[m] = true
+		fn(prefix, m)
+		for _, c := range m.children {
+			if c.Module != nil {
+				visit(prefix+c.Name+".", c.Module.base())
+			}
+		}
+	}
+	visit(prefix, b)
+}
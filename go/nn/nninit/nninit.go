@@ -0,0 +1,370 @@
+// Package nninit fills tensors in place with the initialization schemes of
+// torch.nn.init. It is not called init because Go reserves that name for
+// package initializers.
+//
+// Every function overwrites the values of its tensor without recording the
+// write for autograd, so it can be applied to parameters that require
+// grad. Random schemes draw from the given generator, or from
+// tensors.DefaultGenerator when it is nil, one element at a time in order,
+// so a seed always gives the same values.
+package nninit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+
+	"gotorch/tensors"
+)
+
+// Fan modes of the Kaiming schemes.
+const (
+	FanIn  = "fan_in"  // preserve the variance of the activations
+	FanOut = "fan_out" // preserve the variance of the gradients
+)
+
+// CalculateGain returns the recommended gain for a nonlinearity: 1 for
+// "linear", "identity", the convolutions and "sigmoid", 5/3 for "tanh",
+// sqrt(2) for "relu", sqrt(2/(1+param^2)) for "leaky_relu", where param is
+// the negative slope, and 3/4 for "selu".
+func CalculateGain(nonlinearity string, param float64) (float64, error) {
+	switch nonlinearity {
+	case "linear", "identity", "conv1d", "conv2d", "conv3d",
+		"conv_transpose1d", "conv_transpose2d", "conv_transpose3d", "sigmoid":
+		return 1, nil
+	case "tanh":
+		return 5.0 / 3, nil
+	case "relu":
+		return math.Sqrt(2), nil
+	case "leaky_relu":
+		return math.Sqrt(2 / (1 + param*param)), nil
+	case "selu":
+		return 0.75, nil
+	}
+	return 0, fmt.Errorf("unsupported nonlinearity %q", nonlinearity)
+}
+
+// CalculateFanInAndFanOut returns the number of inputs and outputs of a
+// weight of shape (out, in, k1, k2, ...): in and out times the receptive
+// field size k1*k2*... .
+func CalculateFanInAndFanOut(t *tensors.Tensor) (int, int, error) {
+	if len(t.Shape) < 2 {
+		return 0, 0, errors.New("fan in and fan out can not be computed for tensors with fewer than 2 dimensions")
+	}
+	receptive := 1
+	for _, d := range t.Shape[2:] {
+		receptive *= d
+	}
+	return t.Shape[1] * receptive, t.Shape[0] * receptive, nil
+}
+
+// Uniform fills t with samples from U(a, b).
+func Uniform(t *tensors.Tensor, a, b float64, generator *tensors.Generator) error {
+	return fill(t, generator, func(r *rand.Rand) float64 { return a + (b-a)*r.Float64() })
+}
+
+// Normal fills t with samples from N(mean, std^2).
+func Normal(t *tensors.Tensor, mean, std float64, generator *tensors.Generator) error {
+	return fill(t, generator, func(r *rand.Rand) float64 { return mean + std*r.NormFloat64() })
+}
+
+// TruncNormal fills t with samples from N(mean, std^2) conditioned on
+// lying in [a, b], drawn by inverting the normal distribution function.
+// PyTorch defaults to mean 0, std 1, a = -2 and b = 2.
+func TruncNormal(t *tensors.Tensor, mean, std, a, b float64, generator *tensors.Generator) error {
+	if !(a < b) || std <= 0 {
+		return fmt.Errorf("TruncNormal requires a < b and a positive std, got [%v, %v] and %v", a, b, std)
+	}
+	cdf := func(x float64) float64 { return 0.5 * math.Erfc(-x/math.Sqrt2) }
+	lo, hi := 2*cdf((a-mean)/std)-1, 2*cdf((b-mean)/std)-1
+	return fill(t, generator, func(r *rand.Rand) float64 {
+		u := lo + (hi-lo)*r.Float64()
+		x := mean + std*math.Sqrt2*math.Erfinv(u)
+		return math.Min(math.Max(x, a), b)
+	})
+}
+
+// Constant fills t with value.
+func Constant(t *tensors.Tensor, value float64) error {
+	var err error
+	tensors.NoGrad(func() { err = t.Fill(value) })
+	return err
+}
+
+// Zeros fills t with zeros.
+func Zeros(t *tensors.Tensor) error {
+	return Constant(t, 0)
+}
+
+// Ones fills t with ones.
+func Ones(t *tensors.Tensor) error {
+	return Constant(t, 1)
+}
+
+// XavierUniform fills t from U(-bound, bound) with
+// bound = gain*sqrt(6/(fan_in+fan_out)), as in Glorot and Bengio,
+// "Understanding the difficulty of training deep feedforward neural
+// networks".
+func XavierUniform(t *tensors.Tensor, gain float64, generator *tensors.Generator) error {
+	std, err := xavierStd(t, gain)
+	if err != nil {
+		return err
+	}
+	bound := math.Sqrt(3) * std
+	return Uniform(t, -bound, bound, generator)
+}
+
+// XavierNormal fills t from N(0, std^2) with
+// std = gain*sqrt(2/(fan_in+fan_out)).
+func XavierNormal(t *tensors.Tensor, gain float64, generator *tensors.Generator) error {
+	std, err := xavierStd(t, gain)
+	if err != nil {
+		return err
+	}
+	return Normal(t, 0, std, generator)
+}
+
+func xavierStd(t *tensors.Tensor, gain float64) (float64, error) {
+	fanIn, fanOut, err := CalculateFanInAndFanOut(t)
+	if err != nil {
+		return 0, err
+	}
+	return gain * math.Sqrt(2/float64(fanIn+fanOut)), nil
+}
+
+// KaimingUniform fills t from U(-bound, bound) with
+// bound = gain*sqrt(3/fan), as in He et al., "Delving deep into
+// rectifiers". mode is FanIn or FanOut, and the gain is that of
+// nonlinearity with parameter a, the negative slope of a leaky ReLU.
+// PyTorch defaults to a = 0, FanIn and "leaky_relu".
+func KaimingUniform(t *tensors.Tensor, a float64, mode, nonlinearity string, generator *tensors.Generator) error {
+	std, err := kaimingStd(t, a, mode, nonlinearity)
+	if err != nil {
+		return err
+	}
+	bound := math.Sqrt(3) * std
+	return Uniform(t, -bound, bound, generator)
+}
+
+// KaimingNormal fills t from N(0, std^2) with std = gain/sqrt(fan), with
+// the arguments of KaimingUniform.
+func KaimingNormal(t *tensors.Tensor, a float64, mode, nonlinearity string, generator *tensors.Generator) error {
+	std, err := kaimingStd(t, a, mode, nonlinearity)
+	if err != nil {
+		return err
+	}
+	return Normal(t, 0, std, generator)
+}
+
+func kaimingStd(t *tensors.Tensor, a float64, mode, nonlinearity string) (float64, error) {
+	fanIn, fanOut, err := CalculateFanInAndFanOut(t)
+	if err != nil {
+		return 0, err
+	}
+	fan := fanIn
+	switch mode {
+	case FanIn:
+	case FanOut:
+		fan = fanOut
+	default:
+		return 0, fmt.Errorf("mode %q not supported, please use one of %s, %s", mode, FanIn, FanOut)
+	}
+	gain, err := CalculateGain(nonlinearity, a)
+	if err != nil {
+		return 0, err
+	}
+	return gain / math.Sqrt(float64(fan)), nil
+}
+
+// Orthogonal fills t, viewed as a (rows, cols) matrix with rows = t.Shape[0],
+// with a random semi-orthogonal matrix scaled by gain, as in Saxe et al.,
+// "Exact solutions to the nonlinear dynamics of learning in deep linear
+// neural networks". It takes the Q factor of the QR decomposition of a
+// standard normal matrix, with the signs fixed so that R has a positive
+// diagonal, which makes Q uniformly distributed.
+func Orthogonal(t *tensors.Tensor, gain float64, generator *tensors.Generator) error {
+	if len(t.Shape) < 2 {
+		return errors.New("only tensors with 2 or more dimensions are supported")
+	}
+	rows := t.Shape[0]
+	if rows == 0 {
+		return nil
+	}
+	cols := shapeSize(t.Shape) / rows
+	values := make([]float64, rows*cols)
+	generatorOrDefault(generator).Fill(values, (*rand.Rand).NormFloat64)
+
+	// Orthonormalize the columns of the taller of the matrix and its
+	// transpose.
+	m, n := rows, cols
+	a := values
+	if rows < cols {
+		m, n = cols, rows
+		a = transpose(values, rows, cols)
+	}
+	q := orthonormalColumns(a, m, n)
+	if rows < cols {
+		q = transpose(q, m, n)
+	}
+	for i := range q {
+		q[i] *= gain
+	}
+	return assign(t, q)
+}
+
+// orthonormalColumns returns the Q factor of the thin QR decomposition of
+// the m x n row-major matrix a, m >= n, by modified Gram-Schmidt with a
+// second orthogonalization pass. The diagonal of R is positive.
+func orthonormalColumns(a []float64, m, n int) []float64 {
+	q := append([]float64(nil), a...)
+	for j := 0; j < n; j++ {
+		for pass := 0; pass < 2; pass++ {
+			for i := 0; i < j; i++ {
+				var r float64
+				for k := 0; k < m; k++ {
+					r += q[k*n+i] * q[k*n+j]
+				}
+				for k := 0; k < m; k++ {
+					q[k*n+j] -= r * q[k*n+i]
+				}
+			}
+		}
+		var norm float64
+		for k := 0; k < m; k++ {
+			norm += q[k*n+j] * q[k*n+j]
+		}
+		norm = math.Sqrt(norm)
+		for k := 0; k < m; k++ {
+			q[k*n+j] /= norm
+		}
+	}
+	return q
+}
+
+func transpose(a []float64, rows, cols int) []float64 {
+	out := make([]float64, len(a))
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			out[j*rows+i] = a[i*cols+j]
+		}
+	}
+	return out
+}
+
+// Sparse fills the 2-d tensor t from N(0, std^2) and zeroes a fraction
+// sparsity of the rows of every column, chosen at random, as in Martens,
+// "Deep learning via Hessian-free optimization". PyTorch defaults std to
+// 0.01.
+func Sparse(t *tensors.Tensor, sparsity, std float64, generator *tensors.Generator) error {
+	if len(t.Shape) != 2 {
+		return errors.New("only tensors with 2 dimensions are supported")
+	}
+	if !(sparsity >= 0 && sparsity <= 1) {
+		return fmt.Errorf("sparsity has to be between 0 and 1, got %v", sparsity)
+	}
+	rows, cols := t.Shape[0], t.Shape[1]
+	zeros := int(math.Ceil(sparsity * float64(rows)))
+	g := generatorOrDefault(generator)
+	values := make([]float64, rows*cols)
+	g.Fill(values, func(r *rand.Rand) float64 { return std * r.NormFloat64() })
+	keys, order := make([]float64, rows), make([]int, rows)
+	for c := 0; c < cols; c++ {
+		// A random permutation of the rows: sort them by uniform keys.
+		g.Fill(keys, (*rand.Rand).Float64)
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool { return keys[order[i]] < keys[order[j]] })
+		for _, r := range order[:zeros] {
+			values[r*cols+c] = 0
+		}
+	}
+	return assign(t, values)
+}
+
+// Eye fills the 2-d tensor t with the identity matrix, keeping the
+// identity of the inputs of a Linear layer.
+func Eye(t *tensors.Tensor) error {
+	if len(t.Shape) != 2 {
+		return errors.New("only tensors with 2 dimensions are supported")
+	}
+	rows, cols := t.Shape[0], t.Shape[1]
+	values := make([]float64, rows*cols)
+	for i := 0; i < min(rows, cols); i++ {
+		values[i*cols+i] = 1
+	}
+	return assign(t, values)
+}
+
+// Dirac fills the 3, 4 or 5-d tensor t with the Dirac delta, keeping the
+// identity of the inputs of a convolution with as many channels as
+// possible preserved. With groups > 1 every group of output channels
+// preserves the identity of its inputs.
+func Dirac(t *tensors.Tensor, groups int) error {
+	dims := len(t.Shape)
+	if dims < 3 || dims > 5 {
+		return errors.New("only tensors with 3, 4, or 5 dimensions are supported")
+	}
+	if groups <= 0 || t.Shape[0]%groups != 0 {
+		return fmt.Errorf("dim 0 of size %d must be divisible by %d groups", t.Shape[0], groups)
+	}
+	perGroup := t.Shape[0] / groups
+	kernel := t.Shape[2:]
+	size := shapeSize(kernel)
+	center := 0
+	for _, k := range kernel {
+		center = center*k + k/2
+	}
+	values := make([]float64, shapeSize(t.Shape))
+	for g := 0; g < groups; g++ {
+		for d := 0; d < min(perGroup, t.Shape[1]); d++ {
+			values[((g*perGroup+d)*t.Shape[1]+d)*size+center] = 1
+		}
+	}
+	return assign(t, values)
+}
+
+// fill draws every element of t in order from sample.
+func fill(t *tensors.Tensor, generator *tensors.Generator, sample func(r *rand.Rand) float64) error {
+	values := make([]float64, shapeSize(t.Shape))
+	generatorOrDefault(generator).Fill(values, sample)
+	return assign(t, values)
+}
+
+// assign copies values into t in place without recording the copy.
+func assign(t *tensors.Tensor, values []float64) error {
+	var data interface{}
+	switch t.Data.(type) {
+	case []float32:
+		converted := make([]float32, len(values))
+		for i, v := range values {
+			converted[i] = float32(v)
+		}
+		data = converted
+	case []float64:
+		data = values
+	default:
+		return errors.New("unsupported data type")
+	}
+	src := &tensors.Tensor{Shape: append([]int{}, t.Shape...), Data: data, Dtype: t.Dtype}
+	var err error
+	tensors.NoGrad(func() { err = t.CopyFrom(src) })
+	return err
+}
+
+func generatorOrDefault(g *tensors.Generator) *tensors.Generator {
+	if g == nil {
+		return tensors.DefaultGenerator
+	}
+	return g
+}
+
+func shapeSize(shape []int) int {
+	size := 1
+	for _, d := range shape {
+		size *= d
+	}
+	return size
+}
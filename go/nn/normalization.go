@@ -0,0 +1,438 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+
+	"gotorch/nn/functional"
+	"gotorch/tensors"
+)
+
+// BatchNormNd holds the state shared by the batch normalization layers.
+// In training mode they normalize with the statistics of the batch and
+// update the running statistics; in evaluation mode they normalize with
+// the running statistics. Without running statistics the batch statistics
+// are used in both modes.
+type BatchNormNd struct {
+	Base
+	NumFeatures       int
+	Eps               float64
+	Momentum          float64
+	CumulativeAverage bool // replaces Momentum with 1/NumBatchesTracked, a plain average over all batches
+	Affine            bool
+	TrackRunningStats bool
+	Weight            *Parameter      // (num_features), nil without affine parameters
+	Bias              *Parameter      // (num_features), nil without affine parameters
+	RunningMean       *tensors.Tensor // (num_features), nil without running statistics
+	RunningVar        *tensors.Tensor // (num_features), nil without running statistics
+	NumBatchesTracked *tensors.Tensor // int64 scalar, nil without running statistics
+
+	name string
+	dims []int
+}
+
+// BatchNorm1d normalizes an (n, c) or (n, c, l) input per channel.
+type BatchNorm1d struct{ BatchNormNd }
+
+// BatchNorm2d normalizes an (n, c, h, w) input per channel.
+type BatchNorm2d struct{ BatchNormNd }
+
+// BatchNorm3d normalizes an (n, c, d, h, w) input per channel.
+type BatchNorm3d struct{ BatchNormNd }
+
+// NewBatchNorm1d returns a BatchNorm1d layer. PyTorch defaults to eps 1e-5,
+// momentum 0.1 and both affine and trackRunningStats set.
+func NewBatchNorm1d(numFeatures int, eps, momentum float64, affine, trackRunningStats bool) (*BatchNorm1d, error) {
+	m := &BatchNorm1d{}
+	return m, m.init(numFeatures, eps, momentum, affine, trackRunningStats, "BatchNorm1d", []int{2, 3})
+}
+
+// NewBatchNorm2d returns a BatchNorm2d layer. PyTorch defaults to eps 1e-5,
+// momentum 0.1 and both affine and trackRunningStats set.
+func NewBatchNorm2d(numFeatures int, eps, momentum float64, affine, trackRunningStats bool) (*BatchNorm2d, error) {
+	m := &BatchNorm2d{}
+	return m, m.init(numFeatures, eps, momentum, affine, trackRunningStats, "BatchNorm2d", []int{4})
+}
+
+// NewBatchNorm3d returns a BatchNorm3d layer. PyTorch defaults to eps 1e-5,
+// momentum 0.1 and both affine and trackRunningStats set.
+func NewBatchNorm3d(numFeatures int, eps, momentum float64, affine, trackRunningStats bool) (*BatchNorm3d, error) {
+	m := &BatchNorm3d{}
+	return m, m.init(numFeatures, eps, momentum, affine, trackRunningStats, "BatchNorm3d", []int{5})
+}
+
+// init validates the configuration and creates the weight (ones), the bias
+// (zeros) and the running_mean (zeros), running_var (ones) and
+// num_batches_tracked buffers.
+func (m *BatchNormNd) init(numFeatures int, eps, momentum float64, affine, trackRunningStats bool, name string, dims []int) error {
+	if numFeatures <= 0 {
+		return fmt.Errorf("%s requires a positive number of features, got %d", name, numFeatures)
+	}
+	m.NumFeatures, m.Eps, m.Momentum = numFeatures, eps, momentum
+	m.Affine, m.TrackRunningStats = affine, trackRunningStats
+	m.name, m.dims = name, dims
+	if affine {
+		w, b, err := affineParameters([]int{numFeatures}, true)
+		if err != nil {
+			return err
+		}
+		m.Weight, m.Bias = w, b
+	}
+	m.RegisterParameter("weight", m.Weight)
+	m.RegisterParameter("bias", m.Bias)
+	if trackRunningStats {
+		mean, variance, tracked, err := runningStats(numFeatures)
+		if err != nil {
+			return err
+		}
+		m.RunningMean, m.RunningVar, m.NumBatchesTracked = mean, variance, tracked
+		m.RegisterBuffer("running_mean", m.RunningMean, true)
+		m.RegisterBuffer("running_var", m.RunningVar, true)
+		m.RegisterBuffer("num_batches_tracked", m.NumBatchesTracked, true)
+	}
+	return nil
+}
+
+// ResetRunningStats sets the running mean to zeros, the running variance
+// to ones and the number of tracked batches to zero.
+func (m *BatchNormNd) ResetRunningStats() {
+	resetRunningStats(m.RunningMean, m.RunningVar, m.NumBatchesTracked)
+}
+
+func (m *BatchNormNd) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, m.name)
+	if err != nil {
+		return nil, err
+	}
+	if !containsInt(m.dims, len(input.Shape)) {
+		return nil, fmt.Errorf("%s expects an input with %s dimensions, got shape %v", m.name, joinInts(m.dims), input.Shape)
+	}
+	training := m.IsTraining() || !m.TrackRunningStats
+	momentum := m.Momentum
+	track := m.IsTraining() && m.TrackRunningStats
+	if track && m.CumulativeAverage {
+		momentum = 1 / float64(m.NumBatchesTracked.Data.([]int64)[0]+1)
+	}
+	out, err := functional.BatchNorm(input, m.RunningMean, m.RunningVar, parameterTensor(m.Weight), parameterTensor(m.Bias), training, momentum, m.Eps)
+	if err != nil {
+		return nil, err
+	}
+	if track {
+		m.NumBatchesTracked.Data.([]int64)[0]++
+	}
+	return out, nil
+}
+
+// InstanceNormNd holds the state shared by the instance normalization
+// layers, which normalize every channel of every sample over its spatial
+// dimensions. With running statistics, evaluation mode normalizes with
+// them instead.
+type InstanceNormNd struct {
+	Base
+	NumFeatures       int
+	Eps               float64
+	Momentum          float64
+	Affine            bool
+	TrackRunningStats bool
+	Weight            *Parameter      // (num_features), nil without affine parameters
+	Bias              *Parameter      // (num_features), nil without affine parameters
+	RunningMean       *tensors.Tensor // (num_features), nil without running statistics
+	RunningVar        *tensors.Tensor // (num_features), nil without running statistics
+	NumBatchesTracked *tensors.Tensor // int64 scalar, nil without running statistics
+
+	name string
+	dims int
+}
+
+// InstanceNorm1d normalizes an (n, c, l) or (c, l) input.
+type InstanceNorm1d struct{ InstanceNormNd }
+
+// InstanceNorm2d normalizes an (n, c, h, w) or (c, h, w) input.
+type InstanceNorm2d struct{ InstanceNormNd }
+
+// InstanceNorm3d normalizes an (n, c, d, h, w) or (c, d, h, w) input.
+type InstanceNorm3d struct{ InstanceNormNd }
+
+// NewInstanceNorm1d returns an InstanceNorm1d layer. PyTorch defaults to
+// eps 1e-5, momentum 0.1 and neither affine nor trackRunningStats set.
+func NewInstanceNorm1d(numFeatures int, eps, momentum float64, affine, trackRunningStats bool) (*InstanceNorm1d, error) {
+	m := &InstanceNorm1d{}
+	return m, m.init(numFeatures, eps, momentum, affine, trackRunningStats, "InstanceNorm1d", 1)
+}
+
+// NewInstanceNorm2d returns an InstanceNorm2d layer. PyTorch defaults to
+// eps 1e-5, momentum 0.1 and neither affine nor trackRunningStats set.
+func NewInstanceNorm2d(numFeatures int, eps, momentum float64, affine, trackRunningStats bool) (*InstanceNorm2d, error) {
+	m := &InstanceNorm2d{}
+	return m, m.init(numFeatures, eps, momentum, affine, trackRunningStats, "InstanceNorm2d", 2)
+}
+
+// NewInstanceNorm3d returns an InstanceNorm3d layer. PyTorch defaults to
+// eps 1e-5, momentum 0.1 and neither affine nor trackRunningStats set.
+func NewInstanceNorm3d(numFeatures int, eps, momentum float64, affine, trackRunningStats bool) (*InstanceNorm3d, error) {
+	m := &InstanceNorm3d{}
+	return m, m.init(numFeatures, eps, momentum, affine, trackRunningStats, "InstanceNorm3d", 3)
+}
+
+// init validates the configuration and creates the parameters and buffers
+// like BatchNormNd.
+func (m *InstanceNormNd) init(numFeatures int, eps, momentum float64, affine, trackRunningStats bool, name string, dims int) error {
+	if numFeatures <= 0 {
+		return fmt.Errorf("%s requires a positive number of features, got %d", name, numFeatures)
+	}
+	m.NumFeatures, m.Eps, m.Momentum = numFeatures, eps, momentum
+	m.Affine, m.TrackRunningStats = affine, trackRunningStats
+	m.name, m.dims = name, dims
+	if affine {
+		w, b, err := affineParameters([]int{numFeatures}, true)
+		if err != nil {
+			return err
+		}
+		m.Weight, m.Bias = w, b
+	}
+	m.RegisterParameter("weight", m.Weight)
+	m.RegisterParameter("bias", m.Bias)
+	if trackRunningStats {
+		mean, variance, tracked, err := runningStats(numFeatures)
+		if err != nil {
+			return err
+		}
+		m.RunningMean, m.RunningVar, m.NumBatchesTracked = mean, variance, tracked
+		m.RegisterBuffer("running_mean", m.RunningMean, true)
+		m.RegisterBuffer("running_var", m.RunningVar, true)
+		m.RegisterBuffer("num_batches_tracked", m.NumBatchesTracked, true)
+	}
+	return nil
+}
+
+// ResetRunningStats sets the running mean to zeros, the running variance
+// to ones and the number of tracked batches to zero.
+func (m *InstanceNormNd) ResetRunningStats() {
+	resetRunningStats(m.RunningMean, m.RunningVar, m.NumBatchesTracked)
+}
+
+func (m *InstanceNormNd) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, m.name)
+	if err != nil {
+		return nil, err
+	}
+	batched := len(input.Shape) == m.dims+2
+	if !batched && len(input.Shape) != m.dims+1 {
+		return nil, fmt.Errorf("%s expects an input with %d or %d dimensions, got shape %v", m.name, m.dims+1, m.dims+2, input.Shape)
+	}
+	x := input
+	if !batched {
+		if x, err = tensors.Reshape(input, append([]int{1}, input.Shape...)); err != nil {
+			return nil, err
+		}
+	}
+	useInputStats := m.IsTraining() || !m.TrackRunningStats
+	y, err := functional.InstanceNorm(x, m.RunningMean, m.RunningVar, parameterTensor(m.Weight), parameterTensor(m.Bias), useInputStats, m.Momentum, m.Eps)
+	if err != nil {
+		return nil, err
+	}
+	if m.IsTraining() && m.TrackRunningStats {
+		m.NumBatchesTracked.Data.([]int64)[0]++
+	}
+	if batched {
+		return y, nil
+	}
+	return tensors.Reshape(y, input.Shape)
+}
+
+// LayerNorm normalizes its input over the trailing dimensions given by
+// NormalizedShape.
+type LayerNorm struct {
+	Base
+	NormalizedShape   []int
+	Eps               float64
+	ElementwiseAffine bool
+	Weight            *Parameter // normalized shape, nil without elementwise affine parameters
+	Bias              *Parameter // normalized shape, nil without elementwise affine parameters or bias
+}
+
+// NewLayerNorm returns a LayerNorm layer. PyTorch defaults to eps 1e-5 and
+// both elementwiseAffine and bias set; bias only applies with
+// elementwiseAffine.
+func NewLayerNorm(normalizedShape []int, eps float64, elementwiseAffine, bias bool) (*LayerNorm, error) {
+	if err := checkNormalizedShape(normalizedShape, "LayerNorm"); err != nil {
+		return nil, err
+	}
+	m := &LayerNorm{NormalizedShape: append([]int{}, normalizedShape...), Eps: eps, ElementwiseAffine: elementwiseAffine}
+	if elementwiseAffine {
+		w, b, err := affineParameters(normalizedShape, bias)
+		if err != nil {
+			return nil, err
+		}
+		m.Weight, m.Bias = w, b
+	}
+	m.RegisterParameter("weight", m.Weight)
+	m.RegisterParameter("bias", m.Bias)
+	return m, nil
+}
+
+func (m *LayerNorm) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "LayerNorm")
+	if err != nil {
+		return nil, err
+	}
+	return functional.LayerNorm(input, m.NormalizedShape, parameterTensor(m.Weight), parameterTensor(m.Bias), m.Eps)
+}
+
+// GroupNorm splits the channels of an (n, c, ...) input into groups and
+// normalizes every group of every sample.
+type GroupNorm struct {
+	Base
+	NumGroups   int
+	NumChannels int
+	Eps         float64
+	Affine      bool
+	Weight      *Parameter // (num_channels), nil without affine parameters
+	Bias        *Parameter // (num_channels), nil without affine parameters
+}
+
+// NewGroupNorm returns a GroupNorm layer. numChannels must be divisible by
+// numGroups. PyTorch defaults to eps 1e-5 and affine set.
+func NewGroupNorm(numGroups, numChannels int, eps float64, affine bool) (*GroupNorm, error) {
+	if numGroups <= 0 || numChannels <= 0 || numChannels%numGroups != 0 {
+		return nil, fmt.Errorf("GroupNorm: %d channels are not divisible into %d groups", numChannels, numGroups)
+	}
+	m := &GroupNorm{NumGroups: numGroups, NumChannels: numChannels, Eps: eps, Affine: affine}
+	if affine {
+		w, b, err := affineParameters([]int{numChannels}, true)
+		if err != nil {
+			return nil, err
+		}
+		m.Weight, m.Bias = w, b
+	}
+	m.RegisterParameter("weight", m.Weight)
+	m.RegisterParameter("bias", m.Bias)
+	return m, nil
+}
+
+func (m *GroupNorm) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "GroupNorm")
+	if err != nil {
+		return nil, err
+	}
+	return functional.GroupNorm(input, m.NumGroups, parameterTensor(m.Weight), parameterTensor(m.Bias), m.Eps)
+}
+
+// RMSNorm divides its input by the root mean square over the trailing
+// dimensions given by NormalizedShape.
+type RMSNorm struct {
+	Base
+	NormalizedShape   []int
+	Eps               float64 // 0 uses the machine epsilon of the input data type
+	ElementwiseAffine bool
+	Weight            *Parameter // normalized shape, nil without elementwise affine parameters
+}
+
+// NewRMSNorm returns an RMSNorm layer. An eps of 0 uses the machine
+// epsilon of the input data type, like PyTorch's default.
+func NewRMSNorm(normalizedShape []int, eps float64, elementwiseAffine bool) (*RMSNorm, error) {
+	if err := checkNormalizedShape(normalizedShape, "RMSNorm"); err != nil {
+		return nil, err
+	}
+	m := &RMSNorm{NormalizedShape: append([]int{}, normalizedShape...), Eps: eps, ElementwiseAffine: elementwiseAffine}
+	if elementwiseAffine {
+		w, _, err := affineParameters(normalizedShape, false)
+		if err != nil {
+			return nil, err
+		}
+		m.Weight = w
+	}
+	m.RegisterParameter("weight", m.Weight)
+	return m, nil
+}
+
+func (m *RMSNorm) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, "RMSNorm")
+	if err != nil {
+		return nil, err
+	}
+	eps := m.Eps
+	if eps == 0 {
+		eps = math.Nextafter(1, 2) - 1
+		if input.Dtype.DataType() == "float32" {
+			eps = float64(math.Nextafter32(1, 2) - 1)
+		}
+	}
+	return functional.RMSNorm(input, m.NormalizedShape, parameterTensor(m.Weight), eps)
+}
+
+// affineParameters returns a weight of ones and, if bias is set, a bias of
+// zeros.
+func affineParameters(shape []int, bias bool) (*Parameter, *Parameter, error) {
+	w, err := full(shape, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bias {
+		return NewParameter(w), nil, nil
+	}
+	b, err := full(shape, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewParameter(w), NewParameter(b), nil
+}
+
+// runningStats returns a running mean of zeros, a running variance of ones
+// and an int64 scalar batch counter.
+func runningStats(numFeatures int) (*tensors.Tensor, *tensors.Tensor, *tensors.Tensor, error) {
+	mean, err := full([]int{numFeatures}, 0)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	variance, err := full([]int{numFeatures}, 1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tracked := &tensors.Tensor{Shape: []int{}, Data: []int64{0}, Dtype: tensors.Int64{}}
+	return mean, variance, tracked, nil
+}
+
+func resetRunningStats(mean, variance, tracked *tensors.Tensor) {
+	if mean == nil {
+		return
+	}
+	tensors.NoGrad(func() {
+		mean.Zero()
+		variance.Fill(1)
+		tracked.Zero()
+	})
+}
+
+func checkNormalizedShape(shape []int, name string) error {
+	if len(shape) == 0 {
+		return fmt.Errorf("%s requires a non-empty normalized shape", name)
+	}
+	for _, d := range shape {
+		if d <= 0 {
+			return fmt.Errorf("%s requires a positive normalized shape, got %v", name, shape)
+		}
+	}
+	return nil
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// joinInts formats values as "2 or 3".
+func joinInts(values []int) string {
+	s := ""
+	for i, v := range values {
+		if i > 0 {
+			s += " or "
+		}
+		s += fmt.Sprint(v)
+	}
+	return s
+}
@@ -0,0 +1,17 @@
+package nn
+
+import "gotorch/tensors"
+
+// Parameter is a tensor that is learned as part of a module. It always
+// requires grad.
+type Parameter struct {
+	*tensors.Tensor
+}
+
+// NewParameter wraps the data of t as a parameter. The parameter shares its
+// data with t but is a fresh leaf of the backward graph.
+func NewParameter(t *tensors.Tensor) *Parameter {
+	p := t.Detach()
+	p.RequiresGrad = true
+	return &Parameter{Tensor: p}
+}
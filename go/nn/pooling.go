@@ -0,0 +1,301 @@
+package nn
+
+import (
+	"errors"
+
+	"gotorch/nn/functional"
+	"gotorch/tensors"
+)
+
+type (
+	poolFunc        func(input *tensors.Tensor, kernelSize []int, opts functional.PoolOptions) (*tensors.Tensor, error)
+	poolIndicesFunc func(input *tensors.Tensor, kernelSize []int, opts functional.PoolOptions) (*tensors.Tensor, *tensors.Tensor, error)
+	adaptiveFunc    func(input *tensors.Tensor, outputSize []int) (*tensors.Tensor, *tensors.Tensor, error)
+	unpoolFunc      func(input, indices *tensors.Tensor, kernelSize []int, opts functional.PoolOptions, outputSize []int) (*tensors.Tensor, error)
+	lpPoolFunc      func(input *tensors.Tensor, normType float64, kernelSize []int, opts functional.PoolOptions) (*tensors.Tensor, error)
+)
+
+// MaxPoolNd holds the configuration shared by the max pooling layers.
+type MaxPoolNd struct {
+	Base
+	KernelSize []int
+	Options    functional.PoolOptions
+
+	name string
+	pool poolIndicesFunc
+}
+
+// MaxPool1d takes the maximum over sliding windows of an (n, c, l) or
+// (c, l) input.
+type MaxPool1d struct{ MaxPoolNd }
+
+// MaxPool2d takes the maximum over sliding windows of an (n, c, h, w) or
+// (c, h, w) input.
+type MaxPool2d struct{ MaxPoolNd }
+
+// MaxPool3d takes the maximum over sliding windows of an (n, c, d, h, w)
+// or (c, d, h, w) input.
+type MaxPool3d struct{ MaxPoolNd }
+
+// NewMaxPool1d returns a MaxPool1d layer. The stride defaults to the
+// kernel size.
+func NewMaxPool1d(kernelSize []int, opts functional.PoolOptions) *MaxPool1d {
+	return &MaxPool1d{MaxPoolNd{KernelSize: kernelSize, Options: opts, name: "MaxPool1d", pool: functional.MaxPool1dWithIndices}}
+}
+
+// NewMaxPool2d returns a MaxPool2d layer. The stride defaults to the
+// kernel size.
+func NewMaxPool2d(kernelSize []int, opts functional.PoolOptions) *MaxPool2d {
+	return &MaxPool2d{MaxPoolNd{KernelSize: kernelSize, Options: opts, name: "MaxPool2d", pool: functional.MaxPool2dWithIndices}}
+}
+
+// NewMaxPool3d returns a MaxPool3d layer. The stride defaults to the
+// kernel size.
+func NewMaxPool3d(kernelSize []int, opts functional.PoolOptions) *MaxPool3d {
+	return &MaxPool3d{MaxPoolNd{KernelSize: kernelSize, Options: opts, name: "MaxPool3d", pool: functional.MaxPool3dWithIndices}}
+}
+
+func (m *MaxPoolNd) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, m.name)
+	if err != nil {
+		return nil, err
+	}
+	out, _, err := m.pool(input, m.KernelSize, m.Options)
+	return out, err
+}
+
+// ForwardWithIndices returns the pooled input together with the int64
+// positions of the maxima, for use with MaxUnpool. It does not run hooks.
+func (m *MaxPoolNd) ForwardWithIndices(input *tensors.Tensor) (*tensors.Tensor, *tensors.Tensor, error) {
+	return m.pool(input, m.KernelSize, m.Options)
+}
+
+// AvgPoolNd holds the configuration shared by the average pooling layers.
+type AvgPoolNd struct {
+	Base
+	KernelSize []int
+	Options    functional.PoolOptions
+
+	name string
+	pool poolFunc
+}
+
+// AvgPool1d averages sliding windows of an (n, c, l) or (c, l) input.
+type AvgPool1d struct{ AvgPoolNd }
+
+// AvgPool2d averages sliding windows of an (n, c, h, w) or (c, h, w)
+// input.
+type AvgPool2d struct{ AvgPoolNd }
+
+// AvgPool3d averages sliding windows of an (n, c, d, h, w) or
+// (c, d, h, w) input.
+type AvgPool3d struct{ AvgPoolNd }
+
+// NewAvgPool1d returns an AvgPool1d layer. The stride defaults to the
+// kernel size, and padding counts towards the average unless
+// opts.CountExcludePad is set.
+func NewAvgPool1d(kernelSize []int, opts functional.PoolOptions) *AvgPool1d {
+	return &AvgPool1d{AvgPoolNd{KernelSize: kernelSize, Options: opts, name: "AvgPool1d", pool: functional.AvgPool1d}}
+}
+
+// NewAvgPool2d returns an AvgPool2d layer. The stride defaults to the
+// kernel size, and padding counts towards the average unless
+// opts.CountExcludePad is set.
+func NewAvgPool2d(kernelSize []int, opts functional.PoolOptions) *AvgPool2d {
+	return &AvgPool2d{AvgPoolNd{KernelSize: kernelSize, Options: opts, name: "AvgPool2d", pool: functional.AvgPool2d}}
+}
+
+// NewAvgPool3d returns an AvgPool3d layer. The stride defaults to the
+// kernel size, and padding counts towards the average unless
+// opts.CountExcludePad is set.
+func NewAvgPool3d(kernelSize []int, opts functional.PoolOptions) *AvgPool3d {
+	return &AvgPool3d{AvgPoolNd{KernelSize: kernelSize, Options: opts, name: "AvgPool3d", pool: functional.AvgPool3d}}
+}
+
+func (m *AvgPoolNd) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, m.name)
+	if err != nil {
+		return nil, err
+	}
+	return m.pool(input, m.KernelSize, m.Options)
+}
+
+// LPPoolNd holds the configuration shared by the power-average pooling
+// layers.
+type LPPoolNd struct {
+	Base
+	NormType   float64
+	KernelSize []int
+	Options    functional.PoolOptions
+
+	name string
+	pool lpPoolFunc
+}
+
+// LPPool1d computes the p-norm of sliding windows of an (n, c, l) or
+// (c, l) input.
+type LPPool1d struct{ LPPoolNd }
+
+// LPPool2d computes the p-norm of sliding windows of an (n, c, h, w) or
+// (c, h, w) input.
+type LPPool2d struct{ LPPoolNd }
+
+// NewLPPool1d returns an LPPool1d layer computing the normType-norm.
+func NewLPPool1d(normType float64, kernelSize []int, opts functional.PoolOptions) *LPPool1d {
+	return &LPPool1d{LPPoolNd{NormType: normType, KernelSize: kernelSize, Options: opts, name: "LPPool1d", pool: functional.LPPool1d}}
+}
+
+// NewLPPool2d returns an LPPool2d layer computing the normType-norm.
+func NewLPPool2d(normType float64, kernelSize []int, opts functional.PoolOptions) *LPPool2d {
+	return &LPPool2d{LPPoolNd{NormType: normType, KernelSize: kernelSize, Options: opts, name: "LPPool2d", pool: functional.LPPool2d}}
+}
+
+func (m *LPPoolNd) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, m.name)
+	if err != nil {
+		return nil, err
+	}
+	return m.pool(input, m.NormType, m.KernelSize, m.Options)
+}
+
+// AdaptivePoolNd holds the configuration shared by the adaptive pooling
+// layers.
+type AdaptivePoolNd struct {
+	Base
+	OutputSize []int // one value or one per spatial dimension; 0 keeps the input size
+
+	name string
+	pool adaptiveFunc
+}
+
+// AdaptiveAvgPool1d averages an (n, c, l) or (c, l) input down to a fixed
+// output size.
+type AdaptiveAvgPool1d struct{ AdaptivePoolNd }
+
+// AdaptiveAvgPool2d averages an (n, c, h, w) or (c, h, w) input down to a
+// fixed output size.
+type AdaptiveAvgPool2d struct{ AdaptivePoolNd }
+
+// AdaptiveAvgPool3d averages an (n, c, d, h, w) or (c, d, h, w) input down
+// to a fixed output size.
+type AdaptiveAvgPool3d struct{ AdaptivePoolNd }
+
+// AdaptiveMaxPool1d max-pools an (n, c, l) or (c, l) input down to a fixed
+// output size.
+type AdaptiveMaxPool1d struct{ AdaptivePoolNd }
+
+// AdaptiveMaxPool2d max-pools an (n, c, h, w) or (c, h, w) input down to a
+// fixed output size.
+type AdaptiveMaxPool2d struct{ AdaptivePoolNd }
+
+// AdaptiveMaxPool3d max-pools an (n, c, d, h, w) or (c, d, h, w) input
+// down to a fixed output size.
+type AdaptiveMaxPool3d struct{ AdaptivePoolNd }
+
+// NewAdaptiveAvgPool1d returns an AdaptiveAvgPool1d layer.
+func NewAdaptiveAvgPool1d(outputSize []int) *AdaptiveAvgPool1d {
+	return &AdaptiveAvgPool1d{AdaptivePoolNd{OutputSize: outputSize, name: "AdaptiveAvgPool1d", pool: withoutIndices(functional.AdaptiveAvgPool1d)}}
+}
+
+// NewAdaptiveAvgPool2d returns an AdaptiveAvgPool2d layer.
+func NewAdaptiveAvgPool2d(outputSize []int) *AdaptiveAvgPool2d {
+	return &AdaptiveAvgPool2d{AdaptivePoolNd{OutputSize: outputSize, name: "AdaptiveAvgPool2d", pool: withoutIndices(functional.AdaptiveAvgPool2d)}}
+}
+
+// NewAdaptiveAvgPool3d returns an AdaptiveAvgPool3d layer.
+func NewAdaptiveAvgPool3d(outputSize []int) *AdaptiveAvgPool3d {
+	return &AdaptiveAvgPool3d{AdaptivePoolNd{OutputSize: outputSize, name: "AdaptiveAvgPool3d", pool: withoutIndices(functional.AdaptiveAvgPool3d)}}
+}
+
+// NewAdaptiveMaxPool1d returns an AdaptiveMaxPool1d layer.
+func NewAdaptiveMaxPool1d(outputSize []int) *AdaptiveMaxPool1d {
+	return &AdaptiveMaxPool1d{AdaptivePoolNd{OutputSize: outputSize, name: "AdaptiveMaxPool1d", pool: functional.AdaptiveMaxPool1dWithIndices}}
+}
+
+// NewAdaptiveMaxPool2d returns an AdaptiveMaxPool2d layer.
+func NewAdaptiveMaxPool2d(outputSize []int) *AdaptiveMaxPool2d {
+	return &AdaptiveMaxPool2d{AdaptivePoolNd{OutputSize: outputSize, name: "AdaptiveMaxPool2d", pool: functional.AdaptiveMaxPool2dWithIndices}}
+}
+
+// NewAdaptiveMaxPool3d returns an AdaptiveMaxPool3d layer.
+func NewAdaptiveMaxPool3d(outputSize []int) *AdaptiveMaxPool3d {
+	return &AdaptiveMaxPool3d{AdaptivePoolNd{OutputSize: outputSize, name: "AdaptiveMaxPool3d", pool: functional.AdaptiveMaxPool3dWithIndices}}
+}
+
+func (m *AdaptivePoolNd) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	input, err := oneInput(inputs, m.name)
+	if err != nil {
+		return nil, err
+	}
+	out, _, err := m.pool(input, m.OutputSize)
+	return out, err
+}
+
+// ForwardWithIndices returns the pooled input together with the int64
+// positions of the maxima. The indices are nil for average pooling. It does
+// not run hooks.
+func (m *AdaptivePoolNd) ForwardWithIndices(input *tensors.Tensor) (*tensors.Tensor, *tensors.Tensor, error) {
+	return m.pool(input, m.OutputSize)
+}
+
+func withoutIndices(pool func(input *tensors.Tensor, outputSize []int) (*tensors.Tensor, error)) adaptiveFunc {
+	return func(input *tensors.Tensor, outputSize []int) (*tensors.Tensor, *tensors.Tensor, error) {
+		out, err := pool(input, outputSize)
+		return out, nil, err
+	}
+}
+
+// MaxUnpoolNd holds the configuration shared by the max unpooling layers.
+// Forward takes the pooled values and the indices returned by
+// ForwardWithIndices of the matching max pooling layer.
+type MaxUnpoolNd struct {
+	Base
+	KernelSize []int
+	Options    functional.PoolOptions
+	OutputSize []int // picks the output size when several inputs pool to the same size; nil infers it
+
+	name   string
+	unpool unpoolFunc
+}
+
+// MaxUnpool1d is the partial inverse of MaxPool1d.
+type MaxUnpool1d struct{ MaxUnpoolNd }
+
+// MaxUnpool2d is the partial inverse of MaxPool2d.
+type MaxUnpool2d struct{ MaxUnpoolNd }
+
+// MaxUnpool3d is the partial inverse of MaxPool3d.
+type MaxUnpool3d struct{ MaxUnpoolNd }
+
+// NewMaxUnpool1d returns a MaxUnpool1d layer matching a MaxPool1d with the
+// same kernel size and options.
+func NewMaxUnpool1d(kernelSize []int, opts functional.PoolOptions) *MaxUnpool1d {
+	return &MaxUnpool1d{MaxUnpoolNd{KernelSize: kernelSize, Options: opts, name: "MaxUnpool1d", unpool: functional.MaxUnpool1d}}
+}
+
+// NewMaxUnpool2d returns a MaxUnpool2d layer matching a MaxPool2d with the
+// same kernel size and options.
+func NewMaxUnpool2d(kernelSize []int, opts functional.PoolOptions) *MaxUnpool2d {
+	return &MaxUnpool2d{MaxUnpoolNd{KernelSize: kernelSize, Options: opts, name: "MaxUnpool2d", unpool: functional.MaxUnpool2d}}
+}
+
+// NewMaxUnpool3d returns a MaxUnpool3d layer matching a MaxPool3d with the
+// same kernel size and options.
+func NewMaxUnpool3d(kernelSize []int, opts functional.PoolOptions) *MaxUnpool3d {
+	return &MaxUnpool3d{MaxUnpoolNd{KernelSize: kernelSize, Options: opts, name: "MaxUnpool3d", unpool: functional.MaxUnpool3d}}
+}
+
+func (m *MaxUnpoolNd) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(inputs) != 2 {
+		return nil, errors.New(m.name + " takes the input and the indices")
+	}
+	return m.unpool(inputs[0], inputs[1], m.KernelSize, m.Options, m.OutputSize)
+}
+
+// oneInput returns the only input of a single-input module.
+func oneInput(inputs []*tensors.Tensor, name string) (*tensors.Tensor, error) {
+	if len(inputs) != 1 {
+		return nil, errors.New(name + " takes exactly one input")
+	}
+	return inputs[0], nil
+}
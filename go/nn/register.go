@@ -0,0 +1,217 @@
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gotorch/tensors"
+)
+
+var (
+	parameterType = reflect.TypeOf((*Parameter)(nil))
+	tensorType    = reflect.TypeOf((*tensors.Tensor)(nil))
+	moduleType    = reflect.TypeOf((*Module)(nil)).Elem()
+)
+
+// Register discovers the parameters and child modules held in the exported
+// fields of the struct m points to and registers them on m, so a model can
+// be declared as a plain struct of layers:
+//
+//	type MLP struct {
+//		nn.Base
+//		Hidden *nn.Linear
+//		Out    *nn.Linear
+//		Scale  *nn.Parameter `nn:"name=alpha"`
+//		Cache  *nn.Linear    `nn:"-"`
+//		Stats  *tensors.Tensor `nn:"buffer"`
+//	}
+//
+// Fields are registered under the snake_case form of their name unless a
+// name is given in the tag. A tag of "-" skips the field, and "buffer"
+// registers a *tensors.Tensor field as a persistent buffer. Slices, arrays
+// and string-keyed maps of parameters or modules are registered as a child
+// whose entries are named by index or key; nil entries keep their index
+// and map keys must be non-empty and free of dots. Child modules are registered
+// recursively, so nested structs only need Register called on the root.
+// Calling Register again picks up fields that have been replaced.
+func Register(m Module) error {
+	return register(m, make(map[Module]bool))
+}
+
+func register(m Module, visited map[Module]bool) error {
+	if visited[m] {
+		return nil
+	}
+	visited[m] = true
+
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("Register requires a pointer to a struct")
+	}
+	v = v.Elem()
+	b := m.base()
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, skip, isBuffer := parseTag(field)
+		if skip {
+			continue
+		}
+		fv := v.Field(i)
+
+		switch {
+		case isBuffer:
+			if fv.Type() != tensorType {
+				return fmt.Errorf("field %s: buffers must be *tensors.Tensor", field.Name)
+			}
+			b.RegisterBuffer(name, fv.Interface().(*tensors.Tensor), true)
+		case fv.Type() == parameterType:
+			b.RegisterParameter(name, fv.Interface().(*Parameter))
+		case isModule(fv):
+			child := fv.Interface().(Module)
+			b.RegisterModule(name, child)
+			if err := register(child, visited); err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		case fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array || fv.Kind() == reflect.Map:
+			group, err := collectionModule(fv, visited)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			if group != nil {
+				b.RegisterModule(name, group)
+			}
+		}
+	}
+	return nil
+}
+
+// collectionModule wraps the parameters or modules of a slice, array or map
+// in a ParameterList, ParameterDict, ModuleList or ModuleDict, or returns
+// nil for collections of anything else.
+func collectionModule(v reflect.Value, visited map[Module]bool) (Module, error) {
+	elem := v.Type().Elem()
+	isParams := elem == parameterType
+	if !isParams && !elem.Implements(moduleType) {
+		return nil, nil
+	}
+	if v.Kind() == reflect.Map && v.Type().Key().Kind() != reflect.String {
+		return nil, errors.New("maps of parameters or modules must have string keys")
+	}
+
+	var names []string
+	values := make(map[string]reflect.Value)
+	if v.Kind() == reflect.Map {
+		for _, key := range v.MapKeys() {
+			if name := key.String(); name == "" || strings.Contains(name, ".") {
+				return nil, fmt.Errorf("invalid map key %q: keys must be non-empty and contain no dot", name)
+			}
+			names = append(names, key.String())
+			values[key.String()] = v.MapIndex(key)
+		}
+		sort.Strings(names)
+	} else {
+		for i := 0; i < v.Len(); i++ {
+			name := strconv.Itoa(i)
+			names = append(names, name)
+			values[name] = v.Index(i)
+		}
+	}
+
+	if isParams {
+		params := make(map[string]*Parameter, len(names))
+		for _, name := range names {
+			params[name] = values[name].Interface().(*Parameter)
+		}
+		if v.Kind() == reflect.Map {
+			return NewParameterDict(params), nil
+		}
+		list := NewParameterList()
+		for _, name := range names {
+			list.Append(params[name])
+		}
+		return list, nil
+	}
+
+	modules := make(map[string]Module, len(names))
+	for _, name := range names {
+		ev := values[name]
+		if !isModule(ev) {
+			continue
+		}
+		child := ev.Interface().(Module)
+		if err := register(child, visited); err != nil {
+			return nil, err
+		}
+		modules[name] = child
+	}
+	if v.Kind() == reflect.Map {
+		return NewModuleDict(modules), nil
+	}
+	// Nil entries stay in the list so later entries keep their index, and
+	// with it their state dict keys.
+	list := NewModuleList()
+	for _, name := range names {
+		list.Append(modules[name])
+	}
+	return list, nil
+}
+
+// isModule reports whether v holds a non-nil Module.
+func isModule(v reflect.Value) bool {
+	if !v.Type().Implements(moduleType) {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		return !v.IsNil()
+	}
+	return true
+}
+
+// parseTag reads the nn struct tag of a field.
+func parseTag(field reflect.StructField) (name string, skip, isBuffer bool) {
+	name = snakeCase(field.Name)
+	tag, ok := field.Tag.Lookup("nn")
+	if !ok {
+		return name, false, false
+	}
+	if tag == "-" {
+		return "", true, false
+	}
+	for _, opt := range strings.Split(tag, ",") {
+		switch opt = strings.TrimSpace(opt); {
+		case strings.HasPrefix(opt, "name="):
+			name = strings.TrimPrefix(opt, "name=")
+		case opt == "buffer":
+			isBuffer = true
+		}
+	}
+	return name, false, isBuffer
+}
+
+// snakeCase converts a Go field name such as RunningMean or WeightIH to
+// running_mean or weight_ih.
+func snakeCase(s string) string {
+	runes := []rune(s)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,281 @@
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gotorch/nn/functional"
+	"gotorch/tensors"
+)
+
+// RNNBase holds the state shared by RNN, LSTM and GRU. The weights of
+// layer k are registered as weight_ih_lk, weight_hh_lk, bias_ih_lk and
+// bias_hh_lk, with a _reverse suffix for the backward direction, as in
+// PyTorch.
+type RNNBase struct {
+	Base
+	Mode          functional.RecurrentMode
+	InputSize     int
+	HiddenSize    int
+	NumLayers     int
+	Bias          bool
+	BatchFirst    bool
+	Dropout       float64
+	Bidirectional bool
+	Generator     *tensors.Generator // source of the dropout masks; nil uses the default generator
+
+	name    string
+	weights [][]*Parameter
+}
+
+// RNN is a multi-layer Elman network with a tanh or ReLU nonlinearity.
+type RNN struct{ RNNBase }
+
+// LSTM is a multi-layer long short-term memory network.
+type LSTM struct{ RNNBase }
+
+// GRU is a multi-layer gated recurrent unit network.
+type GRU struct{ RNNBase }
+
+// NewRNN returns an RNN layer. nonlinearity is functional.ModeRNNTanh or
+// functional.ModeRNNReLU. Dropout applies to the outputs of every layer
+// but the last in training mode. PyTorch defaults to one layer, a tanh
+// nonlinearity, bias, sequence-first input, no dropout and one direction.
+func NewRNN(inputSize, hiddenSize, numLayers int, nonlinearity functional.RecurrentMode, bias, batchFirst bool, dropout float64, bidirectional bool) (*RNN, error) {
+	if nonlinearity != functional.ModeRNNTanh && nonlinearity != functional.ModeRNNReLU {
+		return nil, fmt.Errorf("RNN: unknown nonlinearity %q", string(nonlinearity))
+	}
+	m := &RNN{}
+	return m, m.init(nonlinearity, "RNN", 1, inputSize, hiddenSize, numLayers, bias, batchFirst, dropout, bidirectional)
+}
+
+// NewLSTM returns an LSTM layer. Dropout applies to the outputs of every
+// layer but the last in training mode.
+func NewLSTM(inputSize, hiddenSize, numLayers int, bias, batchFirst bool, dropout float64, bidirectional bool) (*LSTM, error) {
+	m := &LSTM{}
+	return m, m.init(functional.ModeLSTM, "LSTM", 4, inputSize, hiddenSize, numLayers, bias, batchFirst, dropout, bidirectional)
+}
+
+// NewGRU returns a GRU layer. Dropout applies to the outputs of every
+// layer but the last in training mode.
+func NewGRU(inputSize, hiddenSize, numLayers int, bias, batchFirst bool, dropout float64, bidirectional bool) (*GRU, error) {
+	m := &GRU{}
+	return m, m.init(functional.ModeGRU, "GRU", 3, inputSize, hiddenSize, numLayers, bias, batchFirst, dropout, bidirectional)
+}
+
+// init validates the configuration and creates the weights, all drawn from
+// U(-1/sqrt(hidden_size), 1/sqrt(hidden_size)) like PyTorch.
+func (m *RNNBase) init(mode functional.RecurrentMode, name string, gates int, inputSize, hiddenSize, numLayers int, bias, batchFirst bool, dropout float64, bidirectional bool) error {
+	if inputSize <= 0 || hiddenSize <= 0 || numLayers <= 0 {
+		return fmt.Errorf("%s requires positive input size, hidden size and number of layers", name)
+	}
+	if !(dropout >= 0 && dropout <= 1) {
+		return fmt.Errorf("%s dropout has to be between 0 and 1, got %v", name, dropout)
+	}
+	m.Mode, m.InputSize, m.HiddenSize, m.NumLayers = mode, inputSize, hiddenSize, numLayers
+	m.Bias, m.BatchFirst, m.Dropout, m.Bidirectional = bias, batchFirst, dropout, bidirectional
+	m.name = name
+
+	dirs := m.directions()
+	bound := 1 / math.Sqrt(float64(hiddenSize))
+	for layer := 0; layer < numLayers; layer++ {
+		in := inputSize
+		if layer > 0 {
+			in = hiddenSize * dirs
+		}
+		for d := 0; d < dirs; d++ {
+			suffix := fmt.Sprintf("_l%d", layer)
+			if d == 1 {
+				suffix += "_reverse"
+			}
+			params, err := cellParameters(in, hiddenSize, gates, bias, bound)
+			if err != nil {
+				return err
+			}
+			for i, prefix := range []string{"weight_ih", "weight_hh", "bias_ih", "bias_hh"} {
+				if params[i] != nil {
+					m.RegisterParameter(prefix+suffix, params[i])
+				}
+			}
+			m.weights = append(m.weights, params)
+		}
+	}
+	return nil
+}
+
+func (m *RNNBase) directions() int {
+	if m.Bidirectional {
+		return 2
+	}
+	return 1
+}
+
+// AllWeights returns the weight_ih, weight_hh, bias_ih and bias_hh
+// parameters of every layer and direction, in the order of the state
+// layout. The biases are nil when the layer has none.
+func (m *RNNBase) AllWeights() [][]*Parameter {
+	all := make([][]*Parameter, len(m.weights))
+	for i, params := range m.weights {
+		all[i] = append([]*Parameter{}, params...)
+	}
+	return all
+}
+
+// Forward takes the input and optionally the initial hidden state and, for
+// LSTM, the initial cell state, and returns the output of the last layer.
+func (m *RNNBase) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New(m.name + " takes an input and optional initial states")
+	}
+	out, _, err := m.ForwardWithState(inputs[0], inputs[1:]...)
+	return out, err
+}
+
+// ForwardWithState runs the network over a (seq, batch, input_size) input,
+// or (batch, seq, input_size) with BatchFirst, or an unbatched
+// (seq, input_size) one. states holds the initial hidden state and, for
+// LSTM, the initial cell state, each of shape
+// (num_layers*directions, batch, hidden_size) or, unbatched,
+// (num_layers*directions, hidden_size); missing states start at zero. It
+// returns the output of the last layer for every step, with
+// directions*hidden_size features, and the final states.
+func (m *RNNBase) ForwardWithState(input *tensors.Tensor, states ...*tensors.Tensor) (*tensors.Tensor, []*tensors.Tensor, error) {
+	weights := make([]functional.RecurrentWeights, len(m.weights))
+	for i, p := range m.weights {
+		weights[i] = functional.RecurrentWeights{
+			WeightIH: p[0].Tensor,
+			WeightHH: p[1].Tensor,
+			BiasIH:   parameterTensor(p[2]),
+			BiasHH:   parameterTensor(p[3]),
+		}
+	}
+	return functional.Recurrent(m.Mode, input, states, weights, functional.RecurrentOptions{
+		NumLayers:     m.NumLayers,
+		Bidirectional: m.Bidirectional,
+		BatchFirst:    m.BatchFirst,
+		Dropout:       m.Dropout,
+		Training:      m.IsTraining(),
+		Generator:     m.Generator,
+	})
+}
+
+// RNNCellBase holds the state shared by RNNCell, LSTMCell and GRUCell.
+type RNNCellBase struct {
+	Base
+	Mode       functional.RecurrentMode
+	InputSize  int
+	HiddenSize int
+	Bias       bool
+	WeightIH   *Parameter // (gates*hidden_size, input_size)
+	WeightHH   *Parameter // (gates*hidden_size, hidden_size)
+	BiasIH     *Parameter // (gates*hidden_size), nil without bias
+	BiasHH     *Parameter // (gates*hidden_size), nil without bias
+
+	name string
+}
+
+// RNNCell is a single step of an Elman network.
+type RNNCell struct{ RNNCellBase }
+
+// LSTMCell is a single step of an LSTM.
+type LSTMCell struct{ RNNCellBase }
+
+// GRUCell is a single step of a GRU.
+type GRUCell struct{ RNNCellBase }
+
+// NewRNNCell returns an RNNCell. nonlinearity is functional.ModeRNNTanh or
+// functional.ModeRNNReLU.
+func NewRNNCell(inputSize, hiddenSize int, bias bool, nonlinearity functional.RecurrentMode) (*RNNCell, error) {
+	if nonlinearity != functional.ModeRNNTanh && nonlinearity != functional.ModeRNNReLU {
+		return nil, fmt.Errorf("RNNCell: unknown nonlinearity %q", string(nonlinearity))
+	}
+	m := &RNNCell{}
+	return m, m.init(nonlinearity, "RNNCell", inputSize, hiddenSize, 1, bias)
+}
+
+// NewLSTMCell returns an LSTMCell.
+func NewLSTMCell(inputSize, hiddenSize int, bias bool) (*LSTMCell, error) {
+	m := &LSTMCell{}
+	return m, m.init(functional.ModeLSTM, "LSTMCell", inputSize, hiddenSize, 4, bias)
+}
+
+// NewGRUCell returns a GRUCell.
+func NewGRUCell(inputSize, hiddenSize int, bias bool) (*GRUCell, error) {
+	m := &GRUCell{}
+	return m, m.init(functional.ModeGRU, "GRUCell", inputSize, hiddenSize, 3, bias)
+}
+
+func (m *RNNCellBase) init(mode functional.RecurrentMode, name string, inputSize, hiddenSize, gates int, bias bool) error {
+	if inputSize <= 0 || hiddenSize <= 0 {
+		return fmt.Errorf("%s requires positive input and hidden sizes", name)
+	}
+	m.Mode, m.InputSize, m.HiddenSize, m.Bias, m.name = mode, inputSize, hiddenSize, bias, name
+	params, err := cellParameters(inputSize, hiddenSize, gates, bias, 1/math.Sqrt(float64(hiddenSize)))
+	if err != nil {
+		return err
+	}
+	m.WeightIH, m.WeightHH, m.BiasIH, m.BiasHH = params[0], params[1], params[2], params[3]
+	m.RegisterParameter("weight_ih", m.WeightIH)
+	m.RegisterParameter("weight_hh", m.WeightHH)
+	m.RegisterParameter("bias_ih", m.BiasIH)
+	m.RegisterParameter("bias_hh", m.BiasHH)
+	return nil
+}
+
+// Forward takes a (batch, input_size) or (input_size) input and optionally
+// the hidden state and, for LSTMCell, the cell state, and returns the next
+// hidden state.
+func (m *RNNCellBase) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New(m.name + " takes an input and optional states")
+	}
+	states, err := m.ForwardWithState(inputs[0], inputs[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return states[0], nil
+}
+
+// ForwardWithState runs one step and returns the next hidden state and,
+// for LSTMCell, the next cell state. Missing states start at zero.
+func (m *RNNCellBase) ForwardWithState(input *tensors.Tensor, states ...*tensors.Tensor) ([]*tensors.Tensor, error) {
+	want := 1
+	if m.Mode == functional.ModeLSTM {
+		want = 2
+	}
+	if len(states) > want {
+		return nil, fmt.Errorf("%s takes at most %d states, got %d", m.name, want, len(states))
+	}
+	state := make([]*tensors.Tensor, want)
+	copy(state, states)
+	w, b := []*tensors.Tensor{m.WeightIH.Tensor, m.WeightHH.Tensor}, []*tensors.Tensor{parameterTensor(m.BiasIH), parameterTensor(m.BiasHH)}
+	switch m.Mode {
+	case functional.ModeLSTM:
+		h, c, err := functional.LSTMCell(input, state[0], state[1], w[0], w[1], b[0], b[1])
+		return []*tensors.Tensor{h, c}, err
+	case functional.ModeGRU:
+		h, err := functional.GRUCell(input, state[0], w[0], w[1], b[0], b[1])
+		return []*tensors.Tensor{h}, err
+	}
+	h, err := functional.RNNCell(input, state[0], w[0], w[1], b[0], b[1], m.Mode)
+	return []*tensors.Tensor{h}, err
+}
+
+// cellParameters returns weight_ih, weight_hh, bias_ih and bias_hh of one
+// cell drawn from U(-bound, bound); the biases are nil without bias.
+func cellParameters(in, hidden, gates int, bias bool, bound float64) ([]*Parameter, error) {
+	shapes := [][]int{{gates * hidden, in}, {gates * hidden, hidden}}
+	if bias {
+		shapes = append(shapes, []int{gates * hidden}, []int{gates * hidden})
+	}
+	params := make([]*Parameter, 4)
+	for i, shape := range shapes {
+		t, err := uniform(shape, bound)
+		if err != nil {
+			return nil, err
+		}
+		params[i] = NewParameter(t)
+	}
+	return params, nil
+}
@@ -0,0 +1,117 @@
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gotorch/tensors"
+)
+
+// LoadResult lists the keys that did not line up when loading a state dict.
+type LoadResult struct {
+	MissingKeys    []string // expected by the module but absent from the state dict
+	UnexpectedKeys []string // present in the state dict but unknown to the module
+}
+
+// StateDict returns the parameters and persistent buffers of the module and
+// its descendants keyed by dotted path. The tensors share data with the
+// module but are detached from the backward graph.
+func (b *Base) StateDict() map[string]*tensors.Tensor {
+	stateDict := make(map[string]*tensors.Tensor)
+	for name, t := range b.stateTensors() {
+		stateDict[name] = t.Detach()
+	}
+	return stateDict
+}
+
+// LoadStateDict copies the tensors of stateDict into the matching
+// parameters and buffers. In strict mode any missing or unexpected key is
+// an error and nothing is copied; otherwise the matching keys are loaded and
+// the mismatches are reported in the result. A shape mismatch is always an
+// error.
+func (b *Base) LoadStateDict(stateDict map[string]*tensors.Tensor, strict bool) (LoadResult, error) {
+	targets := b.stateTensors()
+
+	var result LoadResult
+	for name := range targets {
+		if _, ok := stateDict[name]; !ok {
+			result.MissingKeys = append(result.MissingKeys, name)
+		}
+	}
+	for name := range stateDict {
+		if _, ok := targets[name]; !ok {
+			result.UnexpectedKeys = append(result.UnexpectedKeys, name)
+		}
+	}
+	sort.Strings(result.MissingKeys)
+	sort.Strings(result.UnexpectedKeys)
+
+	if strict && (len(result.MissingKeys) > 0 || len(result.UnexpectedKeys) > 0) {
+		var msgs []string
+		if len(result.MissingKeys) > 0 {
+			msgs = append(msgs, "missing keys: "+strings.Join(result.MissingKeys, ", "))
+		}
+		if len(result.UnexpectedKeys) > 0 {
+			msgs = append(msgs, "unexpected keys: "+strings.Join(result.UnexpectedKeys, ", "))
+		}
+		return result, errors.New("error loading state dict: " + strings.Join(msgs, "; "))
+	}
+
+	for name, dst := range targets {
+		src, ok := stateDict[name]
+		if !ok {
+			continue
+		}
+		if !equalShapes(src.Shape, dst.Shape) {
+			return result, fmt.Errorf("size mismatch for %s: copying a tensor of shape %v into one of shape %v", name, src.Shape, dst.Shape)
+		}
+	}
+
+	var err error
+	tensors.NoGrad(func() {
+		for name, dst := range targets {
+			src, ok := stateDict[name]
+			if !ok {
+				continue
+			}
+			if err = dst.CopyFrom(src); err != nil {
+				err = fmt.Errorf("loading %s: %w", name, err)
+				return
+			}
+		}
+	})
+	return result, err
+}
+
+// stateTensors maps the dotted path of every parameter and persistent
+// buffer to the tensor itself.
+func (b *Base) stateTensors() map[string]*tensors.Tensor {
+	targets := make(map[string]*tensors.Tensor)
+	b.walk("", func(prefix string, m *Base) {
+		for _, np := range m.params {
+			if np.Parameter != nil {
+				targets[prefix+np.Name] = np.Parameter.Tensor
+			}
+		}
+		for _, buf := range m.buffers {
+			if buf.tensor != nil && buf.persistent {
+				targets[prefix+buf.name] = buf.tensor
+			}
+		}
+	})
+	return targets
+}
+
+func equalShapes(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,323 @@
+package nn
+
+import (
+	"errors"
+	"fmt"
+
+	"gotorch/nn/functional"
+	"gotorch/tensors"
+)
+
+// Activation is the elementwise function of a Transformer feed-forward
+// block.
+type Activation func(input *tensors.Tensor) (*tensors.Tensor, error)
+
+// TransformerEncoderLayer is a self-attention block followed by a
+// feed-forward block, each wrapped in a residual connection and a layer
+// norm. With NormFirst the norm is applied to the input of each block
+// (pre-norm); otherwise it is applied after the residual sum (post-norm).
+type TransformerEncoderLayer struct {
+	Base
+	SelfAttn   *MultiheadAttention
+	Linear1    *Linear
+	Dropout    *Dropout
+	Linear2    *Linear
+	Norm1      *LayerNorm
+	Norm2      *LayerNorm
+	Dropout1   *Dropout
+	Dropout2   *Dropout
+	NormFirst  bool
+	Activation Activation
+}
+
+// NewTransformerEncoderLayer returns a TransformerEncoderLayer with
+// nhead-headed attention over dModel features and a feed-forward block of
+// width dimFeedforward using ReLU. PyTorch defaults dimFeedforward to 2048,
+// dropout to 0.1 and layerNormEps to 1e-5, with sequence-first input,
+// post-norm and bias.
+func NewTransformerEncoderLayer(dModel, nhead, dimFeedforward int, dropout, layerNormEps float64, batchFirst, normFirst, bias bool) (*TransformerEncoderLayer, error) {
+	m := &TransformerEncoderLayer{NormFirst: normFirst, Activation: functional.ReLU}
+	var err error
+	if m.SelfAttn, err = NewMultiheadAttention(dModel, nhead, dropout, bias, batchFirst, 0, 0); err != nil {
+		return nil, err
+	}
+	if m.Linear1, m.Linear2, m.Dropout, err = feedForward(dModel, dimFeedforward, dropout, bias); err != nil {
+		return nil, err
+	}
+	if m.Norm1, err = NewLayerNorm([]int{dModel}, layerNormEps, true, bias); err != nil {
+		return nil, err
+	}
+	if m.Norm2, err = NewLayerNorm([]int{dModel}, layerNormEps, true, bias); err != nil {
+		return nil, err
+	}
+	m.Dropout1, m.Dropout2 = NewDropout(dropout), NewDropout(dropout)
+	return m, Register(m)
+}
+
+// Forward runs the layer over src without masks.
+func (m *TransformerEncoderLayer) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	src, err := oneInput(inputs, "TransformerEncoderLayer")
+	if err != nil {
+		return nil, err
+	}
+	return m.ForwardWithMasks(src, AttentionMasks{})
+}
+
+// ForwardWithMasks runs the layer over src, a (seq, batch, d_model) input
+// or the layout of the self-attention, applying masks to it.
+func (m *TransformerEncoderLayer) ForwardWithMasks(src *tensors.Tensor, masks AttentionMasks) (*tensors.Tensor, error) {
+	selfAttention := func(x *tensors.Tensor) (*tensors.Tensor, error) {
+		y, err := m.SelfAttn.ForwardWithMasks(x, x, x, masks)
+		if err != nil {
+			return nil, err
+		}
+		return Call(m.Dropout1, y)
+	}
+	x, err := residual(src, m.Norm1, m.NormFirst, selfAttention)
+	if err != nil {
+		return nil, err
+	}
+	return residual(x, m.Norm2, m.NormFirst, func(x *tensors.Tensor) (*tensors.Tensor, error) {
+		y, err := runFeedForward(x, m.Linear1, m.Dropout, m.Linear2, m.Activation)
+		if err != nil {
+			return nil, err
+		}
+		return Call(m.Dropout2, y)
+	})
+}
+
+// TransformerDecoderLayer is a self-attention block, an attention block
+// over the encoder output and a feed-forward block, each wrapped in a
+// residual connection and a layer norm placed as in
+// TransformerEncoderLayer.
+type TransformerDecoderLayer struct {
+	Base
+	SelfAttn      *MultiheadAttention
+	MultiheadAttn *MultiheadAttention
+	Linear1       *Linear
+	Dropout       *Dropout
+	Linear2       *Linear
+	Norm1         *LayerNorm
+	Norm2         *LayerNorm
+	Norm3         *LayerNorm
+	Dropout1      *Dropout
+	Dropout2      *Dropout
+	Dropout3      *Dropout
+	NormFirst     bool
+	Activation    Activation
+}
+
+// NewTransformerDecoderLayer returns a TransformerDecoderLayer, with the
+// arguments and defaults of NewTransformerEncoderLayer.
+func NewTransformerDecoderLayer(dModel, nhead, dimFeedforward int, dropout, layerNormEps float64, batchFirst, normFirst, bias bool) (*TransformerDecoderLayer, error) {
+	m := &TransformerDecoderLayer{NormFirst: normFirst, Activation: functional.ReLU}
+	var err error
+	if m.SelfAttn, err = NewMultiheadAttention(dModel, nhead, dropout, bias, batchFirst, 0, 0); err != nil {
+		return nil, err
+	}
+	if m.MultiheadAttn, err = NewMultiheadAttention(dModel, nhead, dropout, bias, batchFirst, 0, 0); err != nil {
+		return nil, err
+	}
+	if m.Linear1, m.Linear2, m.Dropout, err = feedForward(dModel, dimFeedforward, dropout, bias); err != nil {
+		return nil, err
+	}
+	for _, norm := range []**LayerNorm{&m.Norm1, &m.Norm2, &m.Norm3} {
+		if *norm, err = NewLayerNorm([]int{dModel}, layerNormEps, true, bias); err != nil {
+			return nil, err
+		}
+	}
+	m.Dropout1, m.Dropout2, m.Dropout3 = NewDropout(dropout), NewDropout(dropout), NewDropout(dropout)
+	return m, Register(m)
+}
+
+// Forward takes the target and the encoder output (memory) and runs the
+// layer without masks.
+func (m *TransformerDecoderLayer) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(inputs) != 2 {
+		return nil, errors.New("TransformerDecoderLayer takes a target and a memory")
+	}
+	return m.ForwardWithMasks(inputs[0], inputs[1], AttentionMasks{}, AttentionMasks{})
+}
+
+// ForwardWithMasks runs the layer over tgt, applying tgtMasks to its
+// self-attention and memoryMasks to its attention over memory.
+func (m *TransformerDecoderLayer) ForwardWithMasks(tgt, memory *tensors.Tensor, tgtMasks, memoryMasks AttentionMasks) (*tensors.Tensor, error) {
+	x, err := residual(tgt, m.Norm1, m.NormFirst, func(x *tensors.Tensor) (*tensors.Tensor, error) {
+		y, err := m.SelfAttn.ForwardWithMasks(x, x, x, tgtMasks)
+		if err != nil {
+			return nil, err
+		}
+		return Call(m.Dropout1, y)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if x, err = residual(x, m.Norm2, m.NormFirst, func(x *tensors.Tensor) (*tensors.Tensor, error) {
+		y, err := m.MultiheadAttn.ForwardWithMasks(x, memory, memory, memoryMasks)
+		if err != nil {
+			return nil, err
+		}
+		return Call(m.Dropout2, y)
+	}); err != nil {
+		return nil, err
+	}
+	return residual(x, m.Norm3, m.NormFirst, func(x *tensors.Tensor) (*tensors.Tensor, error) {
+		y, err := runFeedForward(x, m.Linear1, m.Dropout, m.Linear2, m.Activation)
+		if err != nil {
+			return nil, err
+		}
+		return Call(m.Dropout3, y)
+	})
+}
+
+// TransformerEncoder is a stack of encoder layers with an optional final
+// layer norm.
+type TransformerEncoder struct {
+	Base
+	Layers []*TransformerEncoderLayer
+	Norm   *LayerNorm // nil for none
+}
+
+// NewTransformerEncoder returns a stack of numLayers layers, each built by
+// newLayer so that they do not share parameters, followed by norm, which
+// may be nil.
+func NewTransformerEncoder(newLayer func() (*TransformerEncoderLayer, error), numLayers int, norm *LayerNorm) (*TransformerEncoder, error) {
+	if numLayers <= 0 {
+		return nil, fmt.Errorf("TransformerEncoder requires a positive number of layers, got %d", numLayers)
+	}
+	m := &TransformerEncoder{Norm: norm}
+	for i := 0; i < numLayers; i++ {
+		layer, err := newLayer()
+		if err != nil {
+			return nil, err
+		}
+		m.Layers = append(m.Layers, layer)
+	}
+	return m, Register(m)
+}
+
+// Forward runs the stack over src without masks.
+func (m *TransformerEncoder) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	src, err := oneInput(inputs, "TransformerEncoder")
+	if err != nil {
+		return nil, err
+	}
+	return m.ForwardWithMasks(src, AttentionMasks{})
+}
+
+// ForwardWithMasks runs every layer over src with the same masks.
+func (m *TransformerEncoder) ForwardWithMasks(src *tensors.Tensor, masks AttentionMasks) (*tensors.Tensor, error) {
+	x := src
+	for _, layer := range m.Layers {
+		var err error
+		if x, err = layer.ForwardWithMasks(x, masks); err != nil {
+			return nil, err
+		}
+	}
+	if m.Norm == nil {
+		return x, nil
+	}
+	return Call(m.Norm, x)
+}
+
+// TransformerDecoder is a stack of decoder layers with an optional final
+// layer norm.
+type TransformerDecoder struct {
+	Base
+	Layers []*TransformerDecoderLayer
+	Norm   *LayerNorm // nil for none
+}
+
+// NewTransformerDecoder returns a stack of numLayers layers, each built by
+// newLayer, followed by norm, which may be nil.
+func NewTransformerDecoder(newLayer func() (*TransformerDecoderLayer, error), numLayers int, norm *LayerNorm) (*TransformerDecoder, error) {
+	if numLayers <= 0 {
+		return nil, fmt.Errorf("TransformerDecoder requires a positive number of layers, got %d", numLayers)
+	}
+	m := &TransformerDecoder{Norm: norm}
+	for i := 0; i < numLayers; i++ {
+		layer, err := newLayer()
+		if err != nil {
+			return nil, err
+		}
+		m.Layers = append(m.Layers, layer)
+	}
+	return m, Register(m)
+}
+
+// Forward takes the target and the memory and runs the stack without
+// masks.
+func (m *TransformerDecoder) Forward(inputs ...*tensors.Tensor) (*tensors.Tensor, error) {
+	if len(inputs) != 2 {
+		return nil, errors.New("TransformerDecoder takes a target and a memory")
+	}
+	return m.ForwardWithMasks(inputs[0], inputs[1], AttentionMasks{}, AttentionMasks{})
+}
+
+// ForwardWithMasks runs every layer over tgt with the same memory and
+// masks.
+func (m *TransformerDecoder) ForwardWithMasks(tgt, memory *tensors.Tensor, tgtMasks, memoryMasks AttentionMasks) (*tensors.Tensor, error) {
+	x := tgt
+	for _, layer := range m.Layers {
+		var err error
+		if x, err = layer.ForwardWithMasks(x, memory, tgtMasks, memoryMasks); err != nil {
+			return nil, err
+		}
+	}
+	if m.Norm == nil {
+		return x, nil
+	}
+	return Call(m.Norm, x)
+}
+
+// feedForward returns the two linear layers and the dropout of a
+// feed-forward block.
+func feedForward(dModel, dimFeedforward int, dropout float64, bias bool) (*Linear, *Linear, *Dropout, error) {
+	linear1, err := NewLinear(dModel, dimFeedforward, bias)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	linear2, err := NewLinear(dimFeedforward, dModel, bias)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return linear1, linear2, NewDropout(dropout), nil
+}
+
+// runFeedForward computes linear2(dropout(activation(linear1(x)))).
+func runFeedForward(x *tensors.Tensor, linear1 *Linear, dropout *Dropout, linear2 *Linear, activation Activation) (*tensors.Tensor, error) {
+	y, err := Call(linear1, x)
+	if err != nil {
+		return nil, err
+	}
+	if y, err = activation(y); err != nil {
+		return nil, err
+	}
+	if y, err = Call(dropout, y); err != nil {
+		return nil, err
+	}
+	return Call(linear2, y)
+}
+
+// residual returns x + block(norm(x)) when normFirst and
+// norm(x + block(x)) otherwise.
+func residual(x *tensors.Tensor, norm *LayerNorm, normFirst bool, block func(*tensors.Tensor) (*tensors.Tensor, error)) (*tensors.Tensor, error) {
+	if normFirst {
+		y, err := Call(norm, x)
+		if err != nil {
+			return nil, err
+		}
+		if y, err = block(y); err != nil {
+			return nil, err
+		}
+		return tensors.Add(x, y)
+	}
+	y, err := block(x)
+	if err != nil {
+		return nil, err
+	}
+	if y, err = tensors.Add(x, y); err != nil {
+		return nil, err
+	}
+	return Call(norm, y)
+}
@@ -0,0 +1,99 @@
+package optim
+
+import (
+	"errors"
+	"math"
+
+	"gotorch/nn"
+	"gotorch/tensors"
+)
+
+// AdadeltaOptions are the hyperparameters of Adadelta. Zero Rho and Eps
+// select the defaults of 0.9 and 1e-6. PyTorch defaults LR to 1.
+type AdadeltaOptions struct {
+	LR          float64
+	Rho         float64 // smoothing constant of the running averages
+	Eps         float64
+	WeightDecay float64
+	Maximize    bool
+}
+
+// Adadelta scales every gradient by the ratio of the root mean squares of
+// past updates and past gradients, so steps have the units of the
+// parameters.
+type Adadelta struct {
+	optimizer[AdadeltaOptions]
+}
+
+// NewAdadelta returns an Adadelta optimizer over params.
+func NewAdadelta(params []*nn.Parameter, opts AdadeltaOptions) (*Adadelta, error) {
+	o, err := newOptimizer("Adadelta", params, opts, prepareAdadelta, func(o *AdadeltaOptions) *float64 { return &o.LR })
+	if err != nil {
+		return nil, err
+	}
+	return &Adadelta{o}, nil
+}
+
+func prepareAdadelta(opts AdadeltaOptions) (AdadeltaOptions, error) {
+	if opts.Rho == 0 {
+		opts.Rho = 0.9
+	}
+	if opts.Eps == 0 {
+		opts.Eps = 1e-6
+	}
+	switch {
+	case opts.LR < 0:
+		return opts, errors.New("learning rate must be non-negative")
+	case opts.Rho < 0 || opts.Rho > 1:
+		return opts, errors.New("rho must be in [0, 1]")
+	case opts.Eps < 0:
+		return opts, errors.New("eps must be non-negative")
+	case opts.WeightDecay < 0:
+		return opts, errors.New("weight decay must be non-negative")
+	}
+	return opts, nil
+}
+
+func (o *Adadelta) Step(closure Closure) (float64, error) {
+	loss, err := evaluate(closure)
+	if err != nil {
+		return 0, err
+	}
+	return loss, o.update(func(p, grad *tensors.Tensor, opts AdadeltaOptions, s *ParamState) error {
+		switch p.Data.(type) {
+		case []float32:
+			return adadeltaStep[float32](p, grad, opts, s)
+		case []float64:
+			return adadeltaStep[float64](p, grad, opts, s)
+		}
+		return errDtype
+	})
+}
+
+func adadeltaStep[T float32 | float64](p, grad *tensors.Tensor, opts AdadeltaOptions, s *ParamState) error {
+	x, g := p.Data.([]T), grad.Data.([]T)
+	sq, _, err := buffer[T](s, "square_avg", p, 0)
+	if err != nil {
+		return err
+	}
+	acc, _, err := buffer[T](s, "acc_delta", p, 0)
+	if err != nil {
+		return err
+	}
+	s.Step++
+	rho := opts.Rho
+	delta := make([]T, len(x))
+	for i := range x {
+		d := float64(g[i])
+		if opts.Maximize {
+			d = -d
+		}
+		d += opts.WeightDecay * float64(x[i])
+		v := rho*float64(sq[i]) + (1-rho)*d*d
+		sq[i] = T(v)
+		u := math.Sqrt(float64(acc[i])+opts.Eps) / math.Sqrt(v+opts.Eps) * d
+		acc[i] = T(rho*float64(acc[i]) + (1-rho)*u*u)
+		delta[i] = T(-opts.LR * u)
+	}
+	return addInPlace(p, delta)
+}
@@ -0,0 +1,92 @@
+package optim
+
+import (
+	"errors"
+	"math"
+
+	"gotorch/nn"
+	"gotorch/tensors"
+)
+
+// AdagradOptions are the hyperparameters of Adagrad. A zero Eps selects the
+// default of 1e-10. PyTorch defaults LR to 1e-2.
+type AdagradOptions struct {
+	LR                      float64
+	LRDecay                 float64
+	WeightDecay             float64
+	InitialAccumulatorValue float64
+	Eps                     float64
+	Maximize                bool
+}
+
+// Adagrad divides every gradient by the root of the sum of its past
+// squares, so frequently updated elements take smaller steps.
+type Adagrad struct {
+	optimizer[AdagradOptions]
+}
+
+// NewAdagrad returns an Adagrad optimizer over params.
+func NewAdagrad(params []*nn.Parameter, opts AdagradOptions) (*Adagrad, error) {
+	o, err := newOptimizer("Adagrad", params, opts, prepareAdagrad, func(o *AdagradOptions) *float64 { return &o.LR })
+	if err != nil {
+		return nil, err
+	}
+	return &Adagrad{o}, nil
+}
+
+func prepareAdagrad(opts AdagradOptions) (AdagradOptions, error) {
+	if opts.Eps == 0 {
+		opts.Eps = 1e-10
+	}
+	switch {
+	case opts.LR < 0:
+		return opts, errors.New("learning rate must be non-negative")
+	case opts.LRDecay < 0:
+		return opts, errors.New("learning rate decay must be non-negative")
+	case opts.WeightDecay < 0:
+		return opts, errors.New("weight decay must be non-negative")
+	case opts.InitialAccumulatorValue < 0:
+		return opts, errors.New("initial accumulator value must be non-negative")
+	case opts.Eps < 0:
+		return opts, errors.New("eps must be non-negative")
+	}
+	return opts, nil
+}
+
+func (o *Adagrad) Step(closure Closure) (float64, error) {
+	loss, err := evaluate(closure)
+	if err != nil {
+		return 0, err
+	}
+	return loss, o.update(func(p, grad *tensors.Tensor, opts AdagradOptions, s *ParamState) error {
+		switch p.Data.(type) {
+		case []float32:
+			return adagradStep[float32](p, grad, opts, s)
+		case []float64:
+			return adagradStep[float64](p, grad, opts, s)
+		}
+		return errDtype
+	})
+}
+
+func adagradStep[T float32 | float64](p, grad *tensors.Tensor, opts AdagradOptions, s *ParamState) error {
+	x, g := p.Data.([]T), grad.Data.([]T)
+	sum, _, err := buffer[T](s, "sum", p, opts.InitialAccumulatorValue)
+	if err != nil {
+		return err
+	}
+	s.Step++
+	lr := opts.LR / (1 + float64(s.Step-1)*opts.LRDecay)
+	delta := make([]T, len(x))
+	for i := range x {
+		d := float64(g[i])
+		if opts.Maximize {
+			d = -d
+		}
+		d += opts.WeightDecay * float64(x[i])
+		acc := float64(sum[i]) + d*d
+		sum[i] = T(acc)
+		delta[i] = T(-lr * d / (math.Sqrt(acc) + opts.Eps))
+	}
+	return addInPlace(p, delta)
+}
@@ -0,0 +1,154 @@
+package optim
+
+import (
+	"errors"
+	"math"
+
+	"gotorch/nn"
+	"gotorch/tensors"
+)
+
+// AdamOptions are the hyperparameters of Adam and AdamW. Zero Betas and Eps
+// select the defaults of (0.9, 0.999) and 1e-8. PyTorch defaults LR to
+// 1e-3, and WeightDecay to 0 for Adam and 0.01 for AdamW.
+type AdamOptions struct {
+	LR          float64
+	Betas       [2]float64
+	Eps         float64
+	WeightDecay float64
+	AMSGrad     bool // use the maximum of the second moment estimates
+	Maximize    bool
+}
+
+// Adam adapts the step of every element from running estimates of the first
+// and second moments of its gradient. Weight decay is added to the
+// gradient as an L2 penalty.
+type Adam struct {
+	optimizer[AdamOptions]
+}
+
+// NewAdam returns an Adam optimizer over params.
+func NewAdam(params []*nn.Parameter, opts AdamOptions) (*Adam, error) {
+	o, err := newOptimizer("Adam", params, opts, prepareAdam, adamLR)
+	if err != nil {
+		return nil, err
+	}
+	return &Adam{o}, nil
+}
+
+// AdamW is Adam with decoupled weight decay: parameters shrink by
+// LR*WeightDecay every step instead of the decay entering the moments.
+type AdamW struct {
+	optimizer[AdamOptions]
+}
+
+// NewAdamW returns an AdamW optimizer over params.
+func NewAdamW(params []*nn.Parameter, opts AdamOptions) (*AdamW, error) {
+	o, err := newOptimizer("AdamW", params, opts, prepareAdam, adamLR)
+	if err != nil {
+		return nil, err
+	}
+	return &AdamW{o}, nil
+}
+
+func adamLR(o *AdamOptions) *float64 { return &o.LR }
+
+func prepareAdam(opts AdamOptions) (AdamOptions, error) {
+	if opts.Betas == [2]float64{} {
+		opts.Betas = [2]float64{0.9, 0.999}
+	}
+	if opts.Eps == 0 {
+		opts.Eps = 1e-8
+	}
+	switch {
+	case opts.LR < 0:
+		return opts, errors.New("learning rate must be non-negative")
+	case opts.Eps < 0:
+		return opts, errors.New("eps must be non-negative")
+	case opts.Betas[0] < 0 || opts.Betas[0] >= 1 || opts.Betas[1] < 0 || opts.Betas[1] >= 1:
+		return opts, errors.New("betas must be in [0, 1)")
+	case opts.WeightDecay < 0:
+		return opts, errors.New("weight decay must be non-negative")
+	}
+	return opts, nil
+}
+
+// Momentum returns the first beta of parameter group i.
+func (o *Adam) Momentum(i int) float64 { return o.groups[i].Options.Betas[0] }
+
+// SetMomentum sets the first beta of parameter group i.
+func (o *Adam) SetMomentum(i int, beta1 float64) { o.groups[i].Options.Betas[0] = beta1 }
+
+// Momentum returns the first beta of parameter group i.
+func (o *AdamW) Momentum(i int) float64 { return o.groups[i].Options.Betas[0] }
+
+// SetMomentum sets the first beta of parameter group i.
+func (o *AdamW) SetMomentum(i int, beta1 float64) { o.groups[i].Options.Betas[0] = beta1 }
+
+func (o *Adam) Step(closure Closure) (float64, error) {
+	return adamUpdate(&o.optimizer, closure, false)
+}
+
+func (o *AdamW) Step(closure Closure) (float64, error) {
+	return adamUpdate(&o.optimizer, closure, true)
+}
+
+func adamUpdate(o *optimizer[AdamOptions], closure Closure, decoupled bool) (float64, error) {
+	loss, err := evaluate(closure)
+	if err != nil {
+		return 0, err
+	}
+	return loss, o.update(func(p, grad *tensors.Tensor, opts AdamOptions, s *ParamState) error {
+		switch p.Data.(type) {
+		case []float32:
+			return adamStep[float32](p, grad, opts, s, decoupled)
+		case []float64:
+			return adamStep[float64](p, grad, opts, s, decoupled)
+		}
+		return errDtype
+	})
+}
+
+func adamStep[T float32 | float64](p, grad *tensors.Tensor, opts AdamOptions, s *ParamState, decoupled bool) error {
+	x, g := p.Data.([]T), grad.Data.([]T)
+	m, _, err := buffer[T](s, "exp_avg", p, 0)
+	if err != nil {
+		return err
+	}
+	v, _, err := buffer[T](s, "exp_avg_sq", p, 0)
+	if err != nil {
+		return err
+	}
+	var vmax []T
+	if opts.AMSGrad {
+		if vmax, _, err = buffer[T](s, "max_exp_avg_sq", p, 0); err != nil {
+			return err
+		}
+	}
+	s.Step++
+	beta1, beta2 := opts.Betas[0], opts.Betas[1]
+	stepSize := opts.LR / (1 - math.Pow(beta1, float64(s.Step)))
+	correction2 := math.Sqrt(1 - math.Pow(beta2, float64(s.Step)))
+	delta := make([]T, len(x))
+	for i := range x {
+		d := float64(g[i])
+		if opts.Maximize {
+			d = -d
+		}
+		decay := 0.0
+		if decoupled {
+			decay = -opts.LR * opts.WeightDecay * float64(x[i])
+		} else {
+			d += opts.WeightDecay * float64(x[i])
+		}
+		mi := beta1*float64(m[i]) + (1-beta1)*d
+		vi := beta2*float64(v[i]) + (1-beta2)*d*d
+		m[i], v[i] = T(mi), T(vi)
+		if vmax != nil {
+			vi = max(vi, float64(vmax[i]))
+			vmax[i] = T(vi)
+		}
+		delta[i] = T(decay - stepSize*mi/(math.Sqrt(vi)/correction2+opts.Eps))
+	}
+	return addInPlace(p, delta)
+}
@@ -0,0 +1,547 @@
+package optim
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gotorch/nn"
+	"gotorch/tensors"
+)
+
+// LBFGSOptions are the hyperparameters of LBFGS. Zero MaxIter, MaxEval,
+// ToleranceGrad, ToleranceChange and HistorySize select the defaults of
+// 20, MaxIter*5/4, 1e-7, 1e-9 and 100. PyTorch defaults LR to 1.
+type LBFGSOptions struct {
+	LR              float64
+	MaxIter         int     // iterations per Step
+	MaxEval         int     // closure evaluations per Step
+	ToleranceGrad   float64 // stop once no gradient element exceeds it
+	ToleranceChange float64 // stop once the step or the loss change falls below it
+	HistorySize     int     // number of past updates approximating the inverse Hessian
+	LineSearchFn    string  // "" for a fixed step of LR, or "strong_wolfe"
+}
+
+// LBFGS is the limited-memory BFGS quasi-Newton method. Every Step runs up
+// to MaxIter iterations, each re-evaluating the loss through the closure,
+// which makes it suited to deterministic full-batch problems. All
+// parameters form a single group, and the search directions are kept as
+// flat float64 vectors over all of them.
+type LBFGS struct {
+	optimizer[LBFGSOptions]
+	s lbfgsState
+}
+
+// lbfgsState is carried from one Step to the next.
+type lbfgsState struct {
+	funcEvals, nIter int
+	d, prevFlatGrad  []float64 // last direction and gradient
+	t                float64   // last step size
+	hDiag            float64   // scale of the initial inverse Hessian
+	prevLoss         float64
+	oldDirs, oldStps [][]float64 // gradient and parameter differences, oldest first
+	ro               []float64   // 1 / (y·s) of every pair
+}
+
+// NewLBFGS returns an LBFGS optimizer over params.
+func NewLBFGS(params []*nn.Parameter, opts LBFGSOptions) (*LBFGS, error) {
+	o, err := newOptimizer("LBFGS", params, opts, prepareLBFGS, func(o *LBFGSOptions) *float64 { return &o.LR })
+	if err != nil {
+		return nil, err
+	}
+	return &LBFGS{optimizer: o}, nil
+}
+
+func prepareLBFGS(opts LBFGSOptions) (LBFGSOptions, error) {
+	if opts.MaxIter == 0 {
+		opts.MaxIter = 20
+	}
+	if opts.MaxEval == 0 {
+		opts.MaxEval = opts.MaxIter * 5 / 4
+	}
+	if opts.ToleranceGrad == 0 {
+		opts.ToleranceGrad = 1e-7
+	}
+	if opts.ToleranceChange == 0 {
+		opts.ToleranceChange = 1e-9
+	}
+	if opts.HistorySize == 0 {
+		opts.HistorySize = 100
+	}
+	switch {
+	case opts.LR < 0:
+		return opts, errors.New("learning rate must be non-negative")
+	case opts.MaxIter < 0 || opts.MaxEval < 0 || opts.HistorySize < 0:
+		return opts, errors.New("iteration, evaluation and history limits must be positive")
+	case opts.LineSearchFn != "" && opts.LineSearchFn != "strong_wolfe":
+		return opts, fmt.Errorf("unknown line search %q", opts.LineSearchFn)
+	}
+	return opts, nil
+}
+
+// AddParamGroup always fails: LBFGS supports a single parameter group.
+func (o *LBFGS) AddParamGroup(params []*nn.Parameter, opts LBFGSOptions) error {
+	return errors.New("LBFGS does not support parameter groups")
+}
+
+// Step runs up to MaxIter iterations and returns the loss before the
+// first. closure is required.
+func (o *LBFGS) Step(closure Closure) (float64, error) {
+	if closure == nil {
+		return 0, errors.New("LBFGS requires a closure")
+	}
+	opts := o.groups[0].Options
+	s := &o.s
+
+	origLoss, err := closure()
+	if err != nil {
+		return 0, err
+	}
+	loss := origLoss
+	currentEvals := 1
+	s.funcEvals++
+	flatGrad, err := o.gatherFlatGrad()
+	if err != nil {
+		return 0, err
+	}
+	if maxAbs(flatGrad) <= opts.ToleranceGrad {
+		return origLoss, nil
+	}
+
+	d, t := s.d, s.t
+	for nIter := 1; nIter <= opts.MaxIter; nIter++ {
+		s.nIter++
+		if s.nIter == 1 {
+			d = scaled(flatGrad, -1)
+			s.oldDirs, s.oldStps, s.ro, s.hDiag = nil, nil, nil, 1
+		} else {
+			y, step := scaled(flatGrad, 1), scaled(d, t)
+			axpy(-1, s.prevFlatGrad, y)
+			if ys := dot(y, step); ys > 1e-10 {
+				if len(s.oldDirs) == opts.HistorySize {
+					s.oldDirs, s.oldStps, s.ro = s.oldDirs[1:], s.oldStps[1:], s.ro[1:]
+				}
+				s.oldDirs = append(s.oldDirs, y)
+				s.oldStps = append(s.oldStps, step)
+				s.ro = append(s.ro, 1/ys)
+				s.hDiag = ys / dot(y, y)
+			}
+			d = s.direction(flatGrad)
+		}
+		s.prevFlatGrad = append(s.prevFlatGrad[:0], flatGrad...)
+		prevLoss := loss
+		s.prevLoss = loss
+
+		// The first step is scaled down so it cannot be longer than 1.
+		t = opts.LR
+		if s.nIter == 1 {
+			t = min(1, 1/sumAbs(flatGrad)) * opts.LR
+		}
+		gtd := dot(flatGrad, d)
+		if gtd > -opts.ToleranceChange {
+			break
+		}
+
+		evals, optimal := 0, false
+		if opts.LineSearchFn == "strong_wolfe" {
+			x := o.cloneParams()
+			along := func(t float64) (float64, []float64, error) {
+				return o.directionalEvaluate(closure, x, t, d)
+			}
+			if loss, flatGrad, t, evals, err = strongWolfe(along, t, d, loss, flatGrad, gtd, opts.ToleranceChange, 25); err != nil {
+				return 0, err
+			}
+			if err := o.addDirection(t, d); err != nil {
+				return 0, err
+			}
+			optimal = maxAbs(flatGrad) <= opts.ToleranceGrad
+		} else {
+			if err := o.addDirection(t, d); err != nil {
+				return 0, err
+			}
+			if nIter != opts.MaxIter {
+				if loss, err = closure(); err != nil {
+					return 0, err
+				}
+				if flatGrad, err = o.gatherFlatGrad(); err != nil {
+					return 0, err
+				}
+				evals, optimal = 1, maxAbs(flatGrad) <= opts.ToleranceGrad
+			}
+		}
+		currentEvals += evals
+		s.funcEvals += evals
+
+		if currentEvals >= opts.MaxEval || optimal ||
+			maxAbs(d)*math.Abs(t) <= opts.ToleranceChange ||
+			math.Abs(loss-prevLoss) < opts.ToleranceChange {
+			break
+		}
+	}
+	s.d, s.t = d, t
+	return origLoss, nil
+}
+
+// direction returns the quasi-Newton direction for gradient g by the
+// two-loop recursion over the stored history.
+func (s *lbfgsState) direction(g []float64) []float64 {
+	q := scaled(g, -1)
+	al := make([]float64, len(s.oldDirs))
+	for i := len(s.oldDirs) - 1; i >= 0; i-- {
+		al[i] = dot(s.oldStps[i], q) * s.ro[i]
+		axpy(-al[i], s.oldDirs[i], q)
+	}
+	r := scaled(q, s.hDiag)
+	for i := range s.oldDirs {
+		be := dot(s.oldDirs[i], r) * s.ro[i]
+		axpy(al[i]-be, s.oldStps[i], r)
+	}
+	return r
+}
+
+// gatherFlatGrad concatenates the gradients of the parameters, taking
+// missing ones as zero.
+func (o *LBFGS) gatherFlatGrad() ([]float64, error) {
+	var flat []float64
+	for _, p := range o.groups[0].Params {
+		n := shapeSize(p.Shape)
+		if p.Grad == nil {
+			flat = append(flat, make([]float64, n)...)
+			continue
+		}
+		if shapeSize(p.Grad.Shape) != n {
+			return nil, fmt.Errorf("LBFGS: gradient does not match its parameter of shape %v", p.Shape)
+		}
+		switch g := p.Grad.Data.(type) {
+		case []float32:
+			for _, v := range g {
+				flat = append(flat, float64(v))
+			}
+		case []float64:
+			flat = append(flat, g...)
+		default:
+			return nil, errDtype
+		}
+	}
+	return flat, nil
+}
+
+// addDirection moves the parameters by t*d in place.
+func (o *LBFGS) addDirection(t float64, d []float64) error {
+	var err error
+	tensors.NoGrad(func() {
+		offset := 0
+		for _, p := range o.groups[0].Params {
+			n := shapeSize(p.Shape)
+			switch p.Data.(type) {
+			case []float32:
+				err = addScaled[float32](p.Tensor, t, d[offset:offset+n])
+			case []float64:
+				err = addScaled[float64](p.Tensor, t, d[offset:offset+n])
+			default:
+				err = errDtype
+			}
+			if err != nil {
+				return
+			}
+			offset += n
+		}
+	})
+	return err
+}
+
+func addScaled[T float32 | float64](p *tensors.Tensor, t float64, d []float64) error {
+	delta := make([]T, len(d))
+	for i, v := range d {
+		delta[i] = T(t * v)
+	}
+	return addInPlace(p, delta)
+}
+
+func (o *LBFGS) cloneParams() []*tensors.Tensor {
+	params := o.groups[0].Params
+	x := make([]*tensors.Tensor, len(params))
+	for i, p := range params {
+		x[i] = cloneTensor(p.Tensor)
+	}
+	return x
+}
+
+// directionalEvaluate returns the loss and flat gradient at x + t*d, then
+// puts the parameters back to x.
+func (o *LBFGS) directionalEvaluate(closure Closure, x []*tensors.Tensor, t float64, d []float64) (float64, []float64, error) {
+	if err := o.addDirection(t, d); err != nil {
+		return 0, nil, err
+	}
+	loss, err := closure()
+	if err != nil {
+		return 0, nil, err
+	}
+	flatGrad, err := o.gatherFlatGrad()
+	if err != nil {
+		return 0, nil, err
+	}
+	tensors.NoGrad(func() {
+		for i, p := range o.groups[0].Params {
+			if err = p.CopyFrom(x[i]); err != nil {
+				return
+			}
+		}
+	})
+	return loss, flatGrad, err
+}
+
+// strongWolfe searches along d for a step t satisfying the strong Wolfe
+// conditions, starting from t, by bracketing and cubic interpolation. f, g
+// and gtd are the loss, gradient and directional derivative at t = 0. It
+// returns the loss and gradient at the chosen step, the step and the
+// number of evaluations.
+func strongWolfe(evaluate func(t float64) (float64, []float64, error), t float64, d []float64, f float64, g []float64, gtd, toleranceChange float64, maxLS int) (float64, []float64, float64, int, error) {
+	const c1, c2 = 1e-4, 0.9
+	dNorm := maxAbs(d)
+	fNew, gNew, err := evaluate(t)
+	if err != nil {
+		return 0, nil, 0, 0, err
+	}
+	evals := 1
+	gtdNew := dot(gNew, d)
+
+	// Bracket an interval containing a point that satisfies the conditions.
+	tPrev, fPrev, gPrev, gtdPrev := 0.0, f, g, gtd
+	var bracket, bracketF, bracketGtd []float64
+	var bracketG [][]float64
+	done := false
+	iter := 0
+	for ; iter < maxLS; iter++ {
+		sufficientDecrease := fNew <= f+c1*t*gtd && (iter <= 1 || fNew < fPrev)
+		if sufficientDecrease && math.Abs(gtdNew) <= -c2*gtd {
+			bracket, bracketF, bracketG = []float64{t}, []float64{fNew}, [][]float64{gNew}
+			done = true
+			break
+		}
+		if !sufficientDecrease || gtdNew >= 0 {
+			bracket, bracketF = []float64{tPrev, t}, []float64{fPrev, fNew}
+			bracketG, bracketGtd = [][]float64{gPrev, gNew}, []float64{gtdPrev, gtdNew}
+			break
+		}
+		// Extrapolate within [t + 0.01*(t-tPrev), 10*t].
+		next := cubicInterpolate(tPrev, fPrev, gtdPrev, t, fNew, gtdNew, t+0.01*(t-tPrev), t*10)
+		tPrev, fPrev, gPrev, gtdPrev = t, fNew, gNew, gtdNew
+		t = next
+		if fNew, gNew, err = evaluate(t); err != nil {
+			return 0, nil, 0, 0, err
+		}
+		evals++
+		gtdNew = dot(gNew, d)
+	}
+	if iter == maxLS {
+		bracket, bracketF = []float64{0, t}, []float64{f, fNew}
+		bracketG, bracketGtd = [][]float64{g, gNew}, []float64{gtd, gtdNew}
+	}
+
+	// Zoom in on the bracket until a point satisfies the conditions.
+	insufficientProgress := false
+	low, high := 0, 1
+	if bracketF[0] > bracketF[len(bracketF)-1] {
+		low, high = 1, 0
+	}
+	for !done && iter < maxLS {
+		lo, hi := min(bracket[0], bracket[1]), max(bracket[0], bracket[1])
+		if (hi-lo)*dNorm < toleranceChange {
+			break
+		}
+		t = cubicInterpolate(bracket[0], bracketF[0], bracketGtd[0], bracket[1], bracketF[1], bracketGtd[1], lo, hi)
+		// Keep t away from the ends of the bracket, unless the last step
+		// already made too little progress there.
+		eps := 0.1 * (hi - lo)
+		if min(hi-t, t-lo) < eps {
+			if insufficientProgress || t >= hi || t <= lo {
+				if math.Abs(t-hi) < math.Abs(t-lo) {
+					t = hi - eps
+				} else {
+					t = lo + eps
+				}
+				insufficientProgress = false
+			} else {
+				insufficientProgress = true
+			}
+		} else {
+			insufficientProgress = false
+		}
+
+		if fNew, gNew, err = evaluate(t); err != nil {
+			return 0, nil, 0, 0, err
+		}
+		evals++
+		gtdNew = dot(gNew, d)
+		iter++
+
+		if fNew > f+c1*t*gtd || fNew >= bracketF[low] {
+			bracket[high], bracketF[high], bracketG[high], bracketGtd[high] = t, fNew, gNew, gtdNew
+			low, high = 0, 1
+			if bracketF[0] > bracketF[1] {
+				low, high = 1, 0
+			}
+			continue
+		}
+		if math.Abs(gtdNew) <= -c2*gtd {
+			done = true
+		} else if gtdNew*(bracket[high]-bracket[low]) >= 0 {
+			bracket[high], bracketF[high], bracketG[high], bracketGtd[high] = bracket[low], bracketF[low], bracketG[low], bracketGtd[low]
+		}
+		bracket[low], bracketF[low], bracketG[low], bracketGtd[low] = t, fNew, gNew, gtdNew
+	}
+	return bracketF[low], bracketG[low], bracket[low], evals, nil
+}
+
+// cubicInterpolate returns the minimiser, clamped to [lo, hi], of the cubic
+// through (x1, f1) and (x2, f2) with slopes g1 and g2, or the middle of
+// the bounds when the cubic has no minimum.
+func cubicInterpolate(x1, f1, g1, x2, f2, g2, lo, hi float64) float64 {
+	d1 := g1 + g2 - 3*(f1-f2)/(x1-x2)
+	d2Square := d1*d1 - g1*g2
+	if d2Square < 0 {
+		return (lo + hi) / 2
+	}
+	d2 := math.Sqrt(d2Square)
+	var minPos float64
+	if x1 <= x2 {
+		minPos = x2 - (x2-x1)*((g2+d2-d1)/(g2-g1+2*d2))
+	} else {
+		minPos = x1 - (x1-x2)*((g1+d2-d1)/(g1-g2+2*d2))
+	}
+	return min(max(minPos, lo), hi)
+}
+
+// StateDict returns the options and the history of the optimizer, stored
+// as the state of the first parameter. The history is copied.
+func (o *LBFGS) StateDict() StateDict {
+	sd := o.optimizer.StateDict()
+	s := &o.s
+	if s.nIter == 0 {
+		return sd
+	}
+	buffers := map[string]*tensors.Tensor{
+		"d":              vector(s.d),
+		"prev_flat_grad": vector(s.prevFlatGrad),
+		"ro":             vector(s.ro),
+	}
+	for i := range s.oldDirs {
+		buffers[fmt.Sprintf("old_dirs.%d", i)] = vector(s.oldDirs[i])
+		buffers[fmt.Sprintf("old_stps.%d", i)] = vector(s.oldStps[i])
+	}
+	sd.State[0] = &ParamState{
+		Step:    s.nIter,
+		Buffers: buffers,
+		Values: map[string]float64{
+			"func_evals": float64(s.funcEvals),
+			"t":          s.t,
+			"H_diag":     s.hDiag,
+			"prev_loss":  s.prevLoss,
+		},
+	}
+	return sd
+}
+
+// LoadStateDict restores the options and history saved by StateDict.
+func (o *LBFGS) LoadStateDict(state StateDict) error {
+	if len(state.Groups) != 1 || len(state.Groups[0].Params) != len(o.groups[0].Params) {
+		return errors.New("LBFGS: state dict does not match the parameter group")
+	}
+	opts, ok := state.Groups[0].Options.(LBFGSOptions)
+	if !ok {
+		return fmt.Errorf("LBFGS: state dict holds options of type %T", state.Groups[0].Options)
+	}
+	var s lbfgsState
+	if ps, ok := state.State[0]; ok {
+		size := 0
+		for _, p := range o.groups[0].Params {
+			size += shapeSize(p.Shape)
+		}
+		flat := func(name string, n int) ([]float64, error) {
+			b, ok := ps.Buffers[name]
+			if !ok {
+				return nil, fmt.Errorf("LBFGS: state dict lacks %q", name)
+			}
+			data, ok := b.Data.([]float64)
+			if !ok || len(data) != n {
+				return nil, fmt.Errorf("LBFGS: state %q does not hold %d float64 values", name, n)
+			}
+			return append([]float64{}, data...), nil
+		}
+		var err error
+		if s.d, err = flat("d", size); err != nil {
+			return err
+		}
+		if s.prevFlatGrad, err = flat("prev_flat_grad", size); err != nil {
+			return err
+		}
+		b, ok := ps.Buffers["ro"]
+		if !ok {
+			return errors.New(`LBFGS: state dict lacks "ro"`)
+		}
+		if s.ro, err = flat("ro", shapeSize(b.Shape)); err != nil {
+			return err
+		}
+		for i := range s.ro {
+			dir, err := flat(fmt.Sprintf("old_dirs.%d", i), size)
+			if err != nil {
+				return err
+			}
+			stp, err := flat(fmt.Sprintf("old_stps.%d", i), size)
+			if err != nil {
+				return err
+			}
+			s.oldDirs, s.oldStps = append(s.oldDirs, dir), append(s.oldStps, stp)
+		}
+		s.nIter, s.funcEvals = ps.Step, int(ps.Values["func_evals"])
+		s.t, s.hDiag, s.prevLoss = ps.Values["t"], ps.Values["H_diag"], ps.Values["prev_loss"]
+	}
+	o.groups[0].Options = opts
+	o.s = s
+	return nil
+}
+
+// vector copies values into a 1-d float64 tensor.
+func vector(values []float64) *tensors.Tensor {
+	return &tensors.Tensor{Shape: []int{len(values)}, Data: append([]float64{}, values...), Dtype: tensors.Float64{}}
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// axpy adds alpha*x to y.
+func axpy(alpha float64, x, y []float64) {
+	for i := range x {
+		y[i] += alpha * x[i]
+	}
+}
+
+func scaled(x []float64, alpha float64) []float64 {
+	y := make([]float64, len(x))
+	for i, v := range x {
+		y[i] = alpha * v
+	}
+	return y
+}
+
+func maxAbs(x []float64) float64 {
+	m := 0.0
+	for _, v := range x {
+		m = max(m, math.Abs(v))
+	}
+	return m
+}
+
+func sumAbs(x []float64) float64 {
+	sum := 0.0
+	for _, v := range x {
+		sum += math.Abs(v)
+	}
+	return sum
+}
@@ -0,0 +1,157 @@
+package lrscheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// SequentialLR runs one scheduler after another: Schedulers[i+1] takes
+// over, restarting from the base learning rates, at step Milestones[i].
+type SequentialLR struct {
+	scheduler
+	Schedulers []Scheduler
+	Milestones []int // in increasing order, one fewer than Schedulers
+}
+
+// NewSequentialLR returns a SequentialLR over schedulers, which must drive
+// the same optimizer. The learning rates restart from those before the
+// first scheduler was created.
+func NewSequentialLR(schedulers []Scheduler, milestones []int) (*SequentialLR, error) {
+	if err := checkSchedulers(schedulers, "SequentialLR"); err != nil {
+		return nil, err
+	}
+	if len(milestones) != len(schedulers)-1 {
+		return nil, fmt.Errorf("SequentialLR expects %d milestones, got %d", len(schedulers)-1, len(milestones))
+	}
+	if !sort.IntsAreSorted(milestones) {
+		return nil, errors.New("SequentialLR requires increasing milestones")
+	}
+	first := schedulers[0].sched()
+	s := &SequentialLR{scheduler: *first, Schedulers: append([]Scheduler{}, schedulers...), Milestones: append([]int{}, milestones...)}
+	s.baseLRs = append([]float64{}, first.baseLRs...)
+	shareBaseLRs(schedulers)
+	s.restart()
+	return s, nil
+}
+
+func (s *SequentialLR) Step() {
+	s.lastEpoch++
+	i := sort.SearchInts(s.Milestones, s.lastEpoch+1)
+	if i > 0 && s.Milestones[i-1] == s.lastEpoch {
+		s.Schedulers[i].restart()
+	} else {
+		s.Schedulers[i].Step()
+	}
+	s.lastLRs = s.Schedulers[i].LastLR()
+}
+
+func (s *SequentialLR) restart() {
+	for _, child := range s.Schedulers[1:] {
+		child.sched().lastEpoch = -1
+	}
+	s.Schedulers[0].restart()
+	s.lastEpoch = 0
+	s.lastLRs = s.Schedulers[0].LastLR()
+}
+
+func (s *SequentialLR) StateDict() StateDict {
+	return composedState(&s.scheduler, s.Schedulers)
+}
+
+func (s *SequentialLR) LoadStateDict(state StateDict) error {
+	return loadComposedState(&s.scheduler, s.Schedulers, state)
+}
+
+// ChainedScheduler steps several chainable schedulers together, so their
+// changes to the learning rates compound.
+type ChainedScheduler struct {
+	scheduler
+	Schedulers []Scheduler
+}
+
+// NewChainedScheduler returns a ChainedScheduler over schedulers, which
+// must drive the same optimizer.
+func NewChainedScheduler(schedulers ...Scheduler) (*ChainedScheduler, error) {
+	if err := checkSchedulers(schedulers, "ChainedScheduler"); err != nil {
+		return nil, err
+	}
+	first := schedulers[0].sched()
+	s := &ChainedScheduler{scheduler: *first, Schedulers: append([]Scheduler{}, schedulers...)}
+	s.baseLRs = append([]float64{}, first.baseLRs...)
+	s.lastEpoch = 0
+	s.lastLRs = currentLRs(s.opt)
+	shareBaseLRs(schedulers)
+	return s, nil
+}
+
+func (s *ChainedScheduler) Step() {
+	s.lastEpoch++
+	for _, child := range s.Schedulers {
+		child.Step()
+	}
+	s.lastLRs = currentLRs(s.opt)
+}
+
+func (s *ChainedScheduler) restart() {
+	for _, child := range s.Schedulers {
+		child.restart()
+	}
+	s.lastEpoch = 0
+	s.lastLRs = currentLRs(s.opt)
+}
+
+func (s *ChainedScheduler) StateDict() StateDict {
+	return composedState(&s.scheduler, s.Schedulers)
+}
+
+func (s *ChainedScheduler) LoadStateDict(state StateDict) error {
+	return loadComposedState(&s.scheduler, s.Schedulers, state)
+}
+
+func checkSchedulers(schedulers []Scheduler, name string) error {
+	if len(schedulers) == 0 {
+		return fmt.Errorf("%s expects at least one scheduler", name)
+	}
+	for _, child := range schedulers[1:] {
+		if child.sched().opt != schedulers[0].sched().opt {
+			return fmt.Errorf("%s expects all schedulers to drive the same optimizer", name)
+		}
+	}
+	return nil
+}
+
+// shareBaseLRs gives every scheduler the base learning rates of the first,
+// which was created before the others changed the learning rates.
+func shareBaseLRs(schedulers []Scheduler) {
+	base := schedulers[0].sched().baseLRs
+	for _, child := range schedulers[1:] {
+		child.sched().baseLRs = append([]float64{}, base...)
+	}
+}
+
+func composedState(s *scheduler, schedulers []Scheduler) StateDict {
+	state := s.StateDict()
+	for _, child := range schedulers {
+		state.Schedulers = append(state.Schedulers, child.StateDict())
+	}
+	return state
+}
+
+func loadComposedState(s *scheduler, schedulers []Scheduler, state StateDict) error {
+	if len(state.Schedulers) != len(schedulers) {
+		return fmt.Errorf("lrscheduler: state dict holds %d schedulers, expected %d", len(state.Schedulers), len(schedulers))
+	}
+	if err := s.LoadStateDict(state); err != nil {
+		return err
+	}
+	for i, child := range schedulers {
+		if err := child.LoadStateDict(state.Schedulers[i]); err != nil {
+			return err
+		}
+	}
+	// Every child set the learning rates it last computed, which for an
+	// inactive child of SequentialLR are stale.
+	s.apply(append([]float64{}, state.LastLRs...))
+	return nil
+}
@@ -0,0 +1,280 @@
+package lrscheduler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gotorch/optim"
+)
+
+// CosineAnnealingLR anneals the learning rates from their base values to
+// EtaMin along half a cosine period of TMax steps, then back up over the
+// next TMax steps, and so on.
+type CosineAnnealingLR struct {
+	scheduler
+	TMax   int
+	EtaMin float64
+}
+
+// NewCosineAnnealingLR returns a CosineAnnealingLR scheduler. PyTorch
+// defaults etaMin to 0.
+func NewCosineAnnealingLR(opt optim.Optimizer, tMax int, etaMin float64) (*CosineAnnealingLR, error) {
+	if tMax <= 0 {
+		return nil, fmt.Errorf("CosineAnnealingLR requires a positive TMax, got %d", tMax)
+	}
+	s := &CosineAnnealingLR{scheduler: newScheduler(opt), TMax: tMax, EtaMin: etaMin}
+	s.restart()
+	return s, nil
+}
+
+func (s *CosineAnnealingLR) Step() {
+	s.lastEpoch++
+	epoch, period := float64(s.lastEpoch), float64(s.TMax)
+	s.scale(func(i int, lr float64) float64 {
+		if (s.lastEpoch-1-s.TMax)%(2*s.TMax) == 0 {
+			// Leaving the minimum, where the ratio below is undefined.
+			return lr + (s.baseLRs[i]-s.EtaMin)*(1-math.Cos(math.Pi/period))/2
+		}
+		ratio := (1 + math.Cos(math.Pi*epoch/period)) / (1 + math.Cos(math.Pi*(epoch-1)/period))
+		return ratio*(lr-s.EtaMin) + s.EtaMin
+	})
+}
+
+func (s *CosineAnnealingLR) restart() { s.reset(1) }
+
+// CosineAnnealingWarmRestarts anneals the learning rates from their base
+// values towards EtaMin along half a cosine period and restarts from the
+// base values at the end of every period. The first period lasts T0 steps
+// and every following one TMult times longer than the last.
+type CosineAnnealingWarmRestarts struct {
+	scheduler
+	T0     int
+	TMult  int
+	EtaMin float64
+
+	tI, tCur int // length of the current period and steps taken in it
+}
+
+// NewCosineAnnealingWarmRestarts returns a CosineAnnealingWarmRestarts
+// scheduler. PyTorch defaults tMult to 1 and etaMin to 0.
+func NewCosineAnnealingWarmRestarts(opt optim.Optimizer, t0, tMult int, etaMin float64) (*CosineAnnealingWarmRestarts, error) {
+	if t0 <= 0 {
+		return nil, fmt.Errorf("CosineAnnealingWarmRestarts requires a positive T0, got %d", t0)
+	}
+	if tMult < 1 {
+		return nil, fmt.Errorf("CosineAnnealingWarmRestarts requires TMult of at least 1, got %d", tMult)
+	}
+	s := &CosineAnnealingWarmRestarts{scheduler: newScheduler(opt), T0: t0, TMult: tMult, EtaMin: etaMin}
+	s.restart()
+	return s, nil
+}
+
+func (s *CosineAnnealingWarmRestarts) Step() {
+	s.lastEpoch++
+	s.tCur++
+	if s.tCur >= s.tI {
+		s.tCur -= s.tI
+		s.tI *= s.TMult
+	}
+	lrs := make([]float64, len(s.baseLRs))
+	for i, base := range s.baseLRs {
+		lrs[i] = s.EtaMin + (base-s.EtaMin)*(1+math.Cos(math.Pi*float64(s.tCur)/float64(s.tI)))/2
+	}
+	s.apply(lrs)
+}
+
+func (s *CosineAnnealingWarmRestarts) restart() {
+	s.tI, s.tCur = s.T0, 0
+	s.reset(1)
+}
+
+func (s *CosineAnnealingWarmRestarts) StateDict() StateDict {
+	state := s.scheduler.StateDict()
+	state.Values = map[string]float64{"T_i": float64(s.tI), "T_cur": float64(s.tCur)}
+	return state
+}
+
+func (s *CosineAnnealingWarmRestarts) LoadStateDict(state StateDict) error {
+	if err := s.scheduler.LoadStateDict(state); err != nil {
+		return err
+	}
+	s.tI, s.tCur = int(state.Values["T_i"]), int(state.Values["T_cur"])
+	if s.tI <= 0 {
+		return errors.New("CosineAnnealingWarmRestarts: state dict lacks the period length")
+	}
+	return nil
+}
+
+// momentumOptimizer is an optimizer with a momentum per parameter group,
+// the first beta for Adam.
+type momentumOptimizer interface {
+	optim.Optimizer
+	Momentum(i int) float64
+	SetMomentum(i int, momentum float64)
+}
+
+// OneCycleOptions configure OneCycleLR. Zero PctStart, BaseMomentum,
+// MaxMomentum, DivFactor and FinalDivFactor select the defaults of 0.3,
+// 0.85, 0.95, 25 and 1e4. PyTorch cycles the momentum by default.
+type OneCycleOptions struct {
+	PctStart       float64 // fraction of the steps spent increasing the learning rate
+	AnnealStrategy string  // "cos", the default, or "linear"
+	CycleMomentum  bool    // move the momentum inversely to the learning rate
+	BaseMomentum   float64
+	MaxMomentum    float64
+	DivFactor      float64 // the initial learning rate is the maximum divided by DivFactor
+	FinalDivFactor float64 // the final learning rate is the initial one divided by FinalDivFactor
+	ThreePhase     bool    // anneal back to the initial learning rate before the final one
+}
+
+// OneCycleLR follows the 1cycle policy: the learning rates rise from
+// MaxLR/DivFactor to MaxLR and then anneal far below the start, over
+// TotalSteps steps, while the momentum, if cycled, does the opposite. The
+// rates are set from the step number rather than chained. Steps past the
+// last keep the final learning rates.
+type OneCycleLR struct {
+	scheduler
+	MaxLR      float64
+	TotalSteps int
+	Options    OneCycleOptions
+
+	phases   []cyclePhase
+	momentum momentumOptimizer // nil unless the momentum is cycled
+}
+
+// cyclePhase anneals between two learning rates, each the initial, maximum
+// or final one, and two momenta, ending at step end.
+type cyclePhase struct {
+	end                        float64
+	startLR, endLR             cycleLR
+	startMomentum, endMomentum float64
+}
+
+type cycleLR int
+
+const (
+	cycleInitial cycleLR = iota
+	cycleMax
+	cycleFinal
+)
+
+// NewOneCycleLR returns a OneCycleLR scheduler that sets the learning rates
+// of opt to maxLR/DivFactor. Cycling the momentum requires an optimizer
+// with momentum such as SGD or Adam.
+func NewOneCycleLR(opt optim.Optimizer, maxLR float64, totalSteps int, opts OneCycleOptions) (*OneCycleLR, error) {
+	if opts.PctStart == 0 {
+		opts.PctStart = 0.3
+	}
+	if opts.AnnealStrategy == "" {
+		opts.AnnealStrategy = "cos"
+	}
+	if opts.BaseMomentum == 0 {
+		opts.BaseMomentum = 0.85
+	}
+	if opts.MaxMomentum == 0 {
+		opts.MaxMomentum = 0.95
+	}
+	if opts.DivFactor == 0 {
+		opts.DivFactor = 25
+	}
+	if opts.FinalDivFactor == 0 {
+		opts.FinalDivFactor = 1e4
+	}
+	switch {
+	case totalSteps <= 0:
+		return nil, fmt.Errorf("OneCycleLR requires a positive number of steps, got %d", totalSteps)
+	case opts.PctStart < 0 || opts.PctStart > 1:
+		return nil, fmt.Errorf("OneCycleLR requires PctStart in [0, 1], got %g", opts.PctStart)
+	case opts.AnnealStrategy != "cos" && opts.AnnealStrategy != "linear":
+		return nil, fmt.Errorf("OneCycleLR: unknown anneal strategy %q", opts.AnnealStrategy)
+	}
+
+	s := &OneCycleLR{MaxLR: maxLR, TotalSteps: totalSteps, Options: opts}
+	if opts.CycleMomentum {
+		m, ok := opt.(momentumOptimizer)
+		if !ok {
+			return nil, fmt.Errorf("OneCycleLR cannot cycle the momentum of %T", opt)
+		}
+		s.momentum = m
+	}
+	for i := 0; i < opt.NumGroups(); i++ {
+		opt.SetLR(i, maxLR/opts.DivFactor)
+		if s.momentum != nil {
+			s.momentum.SetMomentum(i, opts.MaxMomentum)
+		}
+	}
+	s.scheduler = newScheduler(opt)
+
+	steps := float64(totalSteps)
+	lo, hi := opts.BaseMomentum, opts.MaxMomentum
+	if opts.ThreePhase {
+		s.phases = []cyclePhase{
+			{opts.PctStart*steps - 1, cycleInitial, cycleMax, hi, lo},
+			{2*opts.PctStart*steps - 2, cycleMax, cycleInitial, lo, hi},
+			{steps - 1, cycleInitial, cycleFinal, hi, hi},
+		}
+	} else {
+		s.phases = []cyclePhase{
+			{opts.PctStart*steps - 1, cycleInitial, cycleMax, hi, lo},
+			{steps - 1, cycleMax, cycleFinal, lo, hi},
+		}
+	}
+	s.restart()
+	return s, nil
+}
+
+func (s *OneCycleLR) Step() {
+	s.lastEpoch++
+	s.set()
+}
+
+// LoadStateDict restores the step count and then sets the learning rates
+// and momenta of that step, since the state dict holds no momenta.
+func (s *OneCycleLR) LoadStateDict(state StateDict) error {
+	if err := s.scheduler.LoadStateDict(state); err != nil {
+		return err
+	}
+	s.set()
+	return nil
+}
+
+func (s *OneCycleLR) restart() {
+	s.lastEpoch = 0
+	s.set()
+}
+
+// set applies the learning rates and momenta of the current step.
+func (s *OneCycleLR) set() {
+	step := float64(min(s.lastEpoch, s.TotalSteps-1))
+	start := 0.0
+	var phase cyclePhase
+	var pct float64
+	for i, p := range s.phases {
+		if step <= p.end || i == len(s.phases)-1 {
+			phase, pct = p, 1
+			if p.end > start {
+				pct = (step - start) / (p.end - start)
+			}
+			break
+		}
+		start = p.end
+	}
+	lrs := make([]float64, len(s.baseLRs))
+	for i, initial := range s.baseLRs {
+		values := [...]float64{initial, s.MaxLR, initial / s.Options.FinalDivFactor}
+		lrs[i] = s.anneal(values[phase.startLR], values[phase.endLR], pct)
+		if s.momentum != nil {
+			s.momentum.SetMomentum(i, s.anneal(phase.startMomentum, phase.endMomentum, pct))
+		}
+	}
+	s.apply(lrs)
+}
+
+// anneal moves from start at pct 0 to end at pct 1.
+func (s *OneCycleLR) anneal(start, end, pct float64) float64 {
+	if s.Options.AnnealStrategy == "linear" {
+		return (end-start)*pct + start
+	}
+	return end + (start-end)/2*(math.Cos(math.Pi*pct)+1)
+}
@@ -0,0 +1,249 @@
+// Package lrscheduler adjusts the learning rates of an optimizer's
+// parameter groups as training progresses.
+//
+// A scheduler sets the learning rates for step 0 when it is created and
+// moves them on every call to Step, usually once per epoch after the
+// optimizer step. Most schedulers are chainable: they scale the current
+// learning rate rather than recomputing it, so several can drive the same
+// optimizer through ChainedScheduler.
+package lrscheduler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"gotorch/optim"
+)
+
+// Scheduler adjusts the learning rates of an optimizer every step.
+type Scheduler interface {
+	// Step advances the schedule by one step and updates the learning
+	// rates.
+	Step()
+	// LastLR returns the learning rates set by the last step, one per
+	// parameter group.
+	LastLR() []float64
+	// StateDict returns the progress of the schedule.
+	StateDict() StateDict
+	// LoadStateDict restores progress saved by StateDict of a scheduler of
+	// the same type and configuration, and sets the learning rates of the
+	// optimizer to those of the last step.
+	LoadStateDict(state StateDict) error
+
+	sched() *scheduler
+	// restart moves the schedule back to step 0 from the base learning
+	// rates.
+	restart()
+}
+
+// StateDict is the progress of a scheduler. The configuration, such as
+// the step size or the milestones, is not included: a checkpoint is
+// resumed by building the same scheduler and loading its state.
+type StateDict struct {
+	LastEpoch  int
+	BaseLRs    []float64
+	LastLRs    []float64
+	Values     map[string]float64 // scheduler specific counters, such as "T_cur"
+	Schedulers []StateDict        // state of the schedulers combined by SequentialLR and ChainedScheduler
+}
+
+// scheduler holds the state common to every scheduler. Concrete schedulers
+// embed it.
+type scheduler struct {
+	opt       optim.Optimizer
+	baseLRs   []float64 // learning rates at step 0
+	lastLRs   []float64
+	lastEpoch int
+}
+
+func newScheduler(opt optim.Optimizer) scheduler {
+	return scheduler{opt: opt, baseLRs: currentLRs(opt), lastEpoch: -1}
+}
+
+func currentLRs(opt optim.Optimizer) []float64 {
+	lrs := make([]float64, opt.NumGroups())
+	for i := range lrs {
+		lrs[i] = opt.LR(i)
+	}
+	return lrs
+}
+
+func (s *scheduler) sched() *scheduler {
+	return s
+}
+
+func (s *scheduler) LastLR() []float64 {
+	return append([]float64{}, s.lastLRs...)
+}
+
+// apply sets the learning rates of the optimizer to lrs.
+func (s *scheduler) apply(lrs []float64) {
+	for i, lr := range lrs {
+		s.opt.SetLR(i, lr)
+	}
+	s.lastLRs = lrs
+}
+
+// scale replaces the learning rate of every group i by f(i, lr).
+func (s *scheduler) scale(f func(i int, lr float64) float64) {
+	lrs := currentLRs(s.opt)
+	for i, lr := range lrs {
+		lrs[i] = f(i, lr)
+	}
+	s.apply(lrs)
+}
+
+// reset moves back to step 0 with the base learning rates times factor.
+func (s *scheduler) reset(factor float64) {
+	s.lastEpoch = 0
+	lrs := make([]float64, len(s.baseLRs))
+	for i, lr := range s.baseLRs {
+		lrs[i] = lr * factor
+	}
+	s.apply(lrs)
+}
+
+func (s *scheduler) StateDict() StateDict {
+	return StateDict{
+		LastEpoch: s.lastEpoch,
+		BaseLRs:   append([]float64{}, s.baseLRs...),
+		LastLRs:   append([]float64{}, s.lastLRs...),
+	}
+}
+
+func (s *scheduler) LoadStateDict(state StateDict) error {
+	if len(state.BaseLRs) != s.opt.NumGroups() || len(state.LastLRs) != s.opt.NumGroups() {
+		return fmt.Errorf("lrscheduler: state dict has %d learning rates, optimizer has %d parameter groups", len(state.BaseLRs), s.opt.NumGroups())
+	}
+	s.lastEpoch = state.LastEpoch
+	s.baseLRs = append([]float64{}, state.BaseLRs...)
+	s.apply(append([]float64{}, state.LastLRs...))
+	return nil
+}
+
+// StepLR multiplies the learning rates by Gamma every StepSize steps.
+type StepLR struct {
+	scheduler
+	StepSize int
+	Gamma    float64
+}
+
+// NewStepLR returns a StepLR scheduler. PyTorch defaults gamma to 0.1.
+func NewStepLR(opt optim.Optimizer, stepSize int, gamma float64) (*StepLR, error) {
+	if stepSize <= 0 {
+		return nil, fmt.Errorf("StepLR requires a positive step size, got %d", stepSize)
+	}
+	s := &StepLR{scheduler: newScheduler(opt), StepSize: stepSize, Gamma: gamma}
+	s.restart()
+	return s, nil
+}
+
+func (s *StepLR) Step() {
+	s.lastEpoch++
+	s.scale(func(_ int, lr float64) float64 {
+		if s.lastEpoch%s.StepSize == 0 {
+			return lr * s.Gamma
+		}
+		return lr
+	})
+}
+
+func (s *StepLR) restart() { s.reset(1) }
+
+// MultiStepLR multiplies the learning rates by Gamma at every milestone.
+// A milestone listed twice applies Gamma twice.
+type MultiStepLR struct {
+	scheduler
+	Milestones []int // in increasing order
+	Gamma      float64
+}
+
+// NewMultiStepLR returns a MultiStepLR scheduler. PyTorch defaults gamma
+// to 0.1.
+func NewMultiStepLR(opt optim.Optimizer, milestones []int, gamma float64) (*MultiStepLR, error) {
+	milestones = append([]int{}, milestones...)
+	sort.Ints(milestones)
+	s := &MultiStepLR{scheduler: newScheduler(opt), Milestones: milestones, Gamma: gamma}
+	s.restart()
+	return s, nil
+}
+
+func (s *MultiStepLR) Step() {
+	s.lastEpoch++
+	hits := 0
+	for _, m := range s.Milestones {
+		if m == s.lastEpoch {
+			hits++
+		}
+	}
+	if hits == 0 {
+		s.scale(func(_ int, lr float64) float64 { return lr })
+		return
+	}
+	factor := math.Pow(s.Gamma, float64(hits))
+	s.scale(func(_ int, lr float64) float64 { return lr * factor })
+}
+
+func (s *MultiStepLR) restart() { s.reset(1) }
+
+// ExponentialLR multiplies the learning rates by Gamma every step.
+type ExponentialLR struct {
+	scheduler
+	Gamma float64
+}
+
+// NewExponentialLR returns an ExponentialLR scheduler.
+func NewExponentialLR(opt optim.Optimizer, gamma float64) *ExponentialLR {
+	s := &ExponentialLR{scheduler: newScheduler(opt), Gamma: gamma}
+	s.restart()
+	return s
+}
+
+func (s *ExponentialLR) Step() {
+	s.lastEpoch++
+	s.scale(func(_ int, lr float64) float64 { return lr * s.Gamma })
+}
+
+func (s *ExponentialLR) restart() { s.reset(1) }
+
+// LinearWarmup scales the learning rates by a factor that moves linearly
+// from StartFactor to EndFactor over TotalIters steps and then stays at
+// EndFactor, like PyTorch's LinearLR.
+type LinearWarmup struct {
+	scheduler
+	StartFactor float64
+	EndFactor   float64
+	TotalIters  int
+}
+
+// NewLinearWarmup returns a LinearWarmup scheduler. PyTorch defaults the
+// factors to 1/3 and 1 and totalIters to 5.
+func NewLinearWarmup(opt optim.Optimizer, startFactor, endFactor float64, totalIters int) (*LinearWarmup, error) {
+	switch {
+	case startFactor <= 0 || startFactor > 1:
+		return nil, errors.New("LinearWarmup requires a start factor in (0, 1]")
+	case endFactor < 0 || endFactor > 1:
+		return nil, errors.New("LinearWarmup requires an end factor in [0, 1]")
+	case totalIters <= 0:
+		return nil, errors.New("LinearWarmup requires a positive number of iterations")
+	}
+	s := &LinearWarmup{scheduler: newScheduler(opt), StartFactor: startFactor, EndFactor: endFactor, TotalIters: totalIters}
+	s.restart()
+	return s, nil
+}
+
+func (s *LinearWarmup) Step() {
+	s.lastEpoch++
+	if s.lastEpoch > s.TotalIters {
+		s.scale(func(_ int, lr float64) float64 { return lr })
+		return
+	}
+	// The ratio of the factors at this step and the previous one.
+	change := s.EndFactor - s.StartFactor
+	ratio := 1 + change/(float64(s.TotalIters)*s.StartFactor+float64(s.lastEpoch-1)*change)
+	s.scale(func(_ int, lr float64) float64 { return lr * ratio })
+}
+
+func (s *LinearWarmup) restart() { s.reset(s.StartFactor) }
@@ -0,0 +1,131 @@
+package lrscheduler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gotorch/optim"
+)
+
+// PlateauOptions configure ReduceLROnPlateau. A zero Factor selects the
+// default of 0.1. PyTorch defaults Patience to 10, Threshold to 1e-4 and
+// Eps to 1e-8.
+type PlateauOptions struct {
+	Mode          string  // "min", the default, when lower metrics are better, or "max"
+	Factor        float64 // the learning rates are multiplied by Factor on a plateau
+	Patience      int     // steps without improvement tolerated before reducing
+	Threshold     float64 // smallest change that counts as an improvement
+	ThresholdMode string  // "rel", the default, for a change relative to the best metric, or "abs"
+	Cooldown      int     // steps to wait after a reduction before counting again
+	MinLR         float64 // lower bound of the learning rates
+	Eps           float64 // reductions smaller than Eps are skipped
+}
+
+// ReduceLROnPlateau reduces the learning rates when a metric, such as the
+// validation loss, has stopped improving for a number of steps. Its Step
+// takes the metric, so it does not implement Scheduler.
+type ReduceLROnPlateau struct {
+	scheduler
+	Options PlateauOptions
+
+	best            float64
+	numBadEpochs    int
+	cooldownCounter int
+}
+
+// NewReduceLROnPlateau returns a ReduceLROnPlateau scheduler.
+func NewReduceLROnPlateau(opt optim.Optimizer, opts PlateauOptions) (*ReduceLROnPlateau, error) {
+	if opts.Mode == "" {
+		opts.Mode = "min"
+	}
+	if opts.ThresholdMode == "" {
+		opts.ThresholdMode = "rel"
+	}
+	if opts.Factor == 0 {
+		opts.Factor = 0.1
+	}
+	switch {
+	case opts.Mode != "min" && opts.Mode != "max":
+		return nil, fmt.Errorf("ReduceLROnPlateau: unknown mode %q", opts.Mode)
+	case opts.ThresholdMode != "rel" && opts.ThresholdMode != "abs":
+		return nil, fmt.Errorf("ReduceLROnPlateau: unknown threshold mode %q", opts.ThresholdMode)
+	case opts.Factor >= 1 || opts.Factor < 0:
+		return nil, errors.New("ReduceLROnPlateau requires a factor in [0, 1)")
+	}
+	s := &ReduceLROnPlateau{scheduler: newScheduler(opt), Options: opts}
+	s.lastEpoch = 0
+	s.lastLRs = currentLRs(opt)
+	s.best = s.worst()
+	return s, nil
+}
+
+// Step records metric and reduces the learning rates once it has not
+// improved for more than Patience steps.
+func (s *ReduceLROnPlateau) Step(metric float64) {
+	s.lastEpoch++
+	if s.isBetter(metric) {
+		s.best, s.numBadEpochs = metric, 0
+	} else {
+		s.numBadEpochs++
+	}
+	if s.cooldownCounter > 0 {
+		s.cooldownCounter--
+		s.numBadEpochs = 0
+	}
+	if s.numBadEpochs > s.Options.Patience {
+		s.scale(func(_ int, lr float64) float64 {
+			if reduced := max(lr*s.Options.Factor, s.Options.MinLR); lr-reduced > s.Options.Eps {
+				return reduced
+			}
+			return lr
+		})
+		s.cooldownCounter, s.numBadEpochs = s.Options.Cooldown, 0
+	}
+	s.lastLRs = currentLRs(s.opt)
+}
+
+func (s *ReduceLROnPlateau) worst() float64 {
+	if s.Options.Mode == "min" {
+		return math.Inf(1)
+	}
+	return math.Inf(-1)
+}
+
+func (s *ReduceLROnPlateau) isBetter(metric float64) bool {
+	o := s.Options
+	switch {
+	case o.Mode == "min" && o.ThresholdMode == "rel":
+		return metric < s.best*(1-o.Threshold)
+	case o.Mode == "min":
+		return metric < s.best-o.Threshold
+	case o.ThresholdMode == "rel":
+		return metric > s.best*(1+o.Threshold)
+	default:
+		return metric > s.best+o.Threshold
+	}
+}
+
+func (s *ReduceLROnPlateau) StateDict() StateDict {
+	state := s.scheduler.StateDict()
+	state.Values = map[string]float64{
+		"best":             s.best,
+		"num_bad_epochs":   float64(s.numBadEpochs),
+		"cooldown_counter": float64(s.cooldownCounter),
+	}
+	return state
+}
+
+func (s *ReduceLROnPlateau) LoadStateDict(state StateDict) error {
+	if err := s.scheduler.LoadStateDict(state); err != nil {
+		return err
+	}
+	best, ok := state.Values["best"]
+	if !ok {
+		return errors.New("ReduceLROnPlateau: state dict lacks the best metric")
+	}
+	s.best = best
+	s.numBadEpochs = int(state.Values["num_bad_epochs"])
+	s.cooldownCounter = int(state.Values["cooldown_counter"])
+	return nil
+}
@@ -0,0 +1,306 @@
+// Package optim provides optimizers that update the parameters of nn
+// modules from their gradients.
+package optim
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+
+	"gotorch/internal/parallel"
+	"gotorch/nn"
+	"gotorch/tensors"
+)
+
+// Closure re-evaluates the model: it clears the gradients, computes the
+// loss, runs Backward and returns the loss.
+type Closure func() (float64, error)
+
+// Optimizer updates a set of parameters, organised in parameter groups that
+// each have their own hyperparameters.
+type Optimizer interface {
+	// Step updates every parameter that has a gradient. closure may be nil
+	// unless the optimizer re-evaluates the loss, as LBFGS does; Step
+	// returns the loss of closure, or 0 without one.
+	Step(closure Closure) (float64, error)
+	// ZeroGrad resets the gradients of every parameter to nil.
+	ZeroGrad()
+	// NumGroups returns the number of parameter groups.
+	NumGroups() int
+	// LR returns the learning rate of parameter group i.
+	LR(i int) float64
+	// SetLR sets the learning rate of parameter group i.
+	SetLR(i int, lr float64)
+	// StateDict returns the hyperparameters and per-parameter state.
+	StateDict() StateDict
+	// LoadStateDict restores state saved by StateDict of an optimizer of
+	// the same type over parameters of the same shapes.
+	LoadStateDict(state StateDict) error
+}
+
+// ParamGroup is a set of parameters updated with the same options.
+type ParamGroup[O any] struct {
+	Params  []*nn.Parameter
+	Options O
+}
+
+// ParamState is the state an optimizer keeps for a single parameter. The
+// buffers and values use the names of PyTorch, such as "exp_avg" or
+// "momentum_buffer".
+type ParamState struct {
+	Step    int
+	Buffers map[string]*tensors.Tensor
+	Values  map[string]float64 // scalar state other than the step
+}
+
+// GroupState is a parameter group in a state dict. Params holds the indices
+// of its parameters and Options the options of the optimizer type, such as
+// SGDOptions.
+type GroupState struct {
+	Params  []int
+	Options any
+}
+
+// StateDict is the state of an optimizer. Parameters are identified by
+// their position when the groups are listed in order, so the state can be
+// loaded into an optimizer over another copy of the model.
+type StateDict struct {
+	State  map[int]*ParamState
+	Groups []GroupState
+}
+
+// optimizer holds the parameter groups and state common to every
+// optimizer. Concrete optimizers embed it and implement Step.
+type optimizer[O any] struct {
+	name   string
+	groups []*ParamGroup[O]
+	state  map[*nn.Parameter]*ParamState
+	// prepare replaces unset options by their defaults and validates them.
+	prepare func(O) (O, error)
+	// lr points at the learning rate of a group's options.
+	lr func(*O) *float64
+}
+
+func newOptimizer[O any](name string, params []*nn.Parameter, opts O, prepare func(O) (O, error), lr func(*O) *float64) (optimizer[O], error) {
+	o := optimizer[O]{name: name, state: map[*nn.Parameter]*ParamState{}, prepare: prepare, lr: lr}
+	err := o.AddParamGroup(params, opts)
+	return o, err
+}
+
+// AddParamGroup adds params as a new group with its own options. A
+// parameter can belong to a single group only.
+func (o *optimizer[O]) AddParamGroup(params []*nn.Parameter, opts O) error {
+	if len(params) == 0 {
+		return fmt.Errorf("%s got an empty parameter list", o.name)
+	}
+	opts, err := o.prepare(opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", o.name, err)
+	}
+	seen := map[*nn.Parameter]bool{}
+	for _, g := range o.groups {
+		for _, p := range g.Params {
+			seen[p] = true
+		}
+	}
+	for _, p := range params {
+		if p == nil {
+			return fmt.Errorf("%s got a nil parameter", o.name)
+		}
+		if seen[p] {
+			return fmt.Errorf("%s: a parameter appears in more than one group", o.name)
+		}
+		seen[p] = true
+	}
+	o.groups = append(o.groups, &ParamGroup[O]{Params: append([]*nn.Parameter{}, params...), Options: opts})
+	return nil
+}
+
+// ParamGroups returns the parameter groups. Their options may be changed
+// between steps.
+func (o *optimizer[O]) ParamGroups() []*ParamGroup[O] {
+	return o.groups
+}
+
+func (o *optimizer[O]) ZeroGrad() {
+	for _, g := range o.groups {
+		for _, p := range g.Params {
+			p.Grad = nil
+		}
+	}
+}
+
+func (o *optimizer[O]) NumGroups() int {
+	return len(o.groups)
+}
+
+func (o *optimizer[O]) LR(i int) float64 {
+	return *o.lr(&o.groups[i].Options)
+}
+
+func (o *optimizer[O]) SetLR(i int, lr float64) {
+	*o.lr(&o.groups[i].Options) = lr
+}
+
+// StateDict returns the state of the optimizer. The buffers are shared with
+// the optimizer, not copied.
+func (o *optimizer[O]) StateDict() StateDict {
+	sd := StateDict{State: map[int]*ParamState{}}
+	index := 0
+	for _, g := range o.groups {
+		gs := GroupState{Options: g.Options}
+		for _, p := range g.Params {
+			if s, ok := o.state[p]; ok {
+				sd.State[index] = &ParamState{Step: s.Step, Buffers: s.Buffers, Values: s.Values}
+			}
+			gs.Params = append(gs.Params, index)
+			index++
+		}
+		sd.Groups = append(sd.Groups, gs)
+	}
+	return sd
+}
+
+// LoadStateDict copies the options and buffers of state into the
+// optimizer, replacing its current state. The groups must match the ones
+// of the optimizer in number and size.
+func (o *optimizer[O]) LoadStateDict(state StateDict) error {
+	if len(state.Groups) != len(o.groups) {
+		return fmt.Errorf("%s: state dict has %d parameter groups, optimizer has %d", o.name, len(state.Groups), len(o.groups))
+	}
+	options := make([]O, len(o.groups))
+	loaded := map[*nn.Parameter]*ParamState{}
+	for i, gs := range state.Groups {
+		g := o.groups[i]
+		opts, ok := gs.Options.(O)
+		if !ok {
+			return fmt.Errorf("%s: state dict holds options of type %T", o.name, gs.Options)
+		}
+		if len(gs.Params) != len(g.Params) {
+			return fmt.Errorf("%s: parameter group %d has %d parameters in the state dict and %d in the optimizer", o.name, i, len(gs.Params), len(g.Params))
+		}
+		options[i] = opts
+		for j, index := range gs.Params {
+			s, ok := state.State[index]
+			if !ok {
+				continue
+			}
+			p := g.Params[j]
+			buffers := make(map[string]*tensors.Tensor, len(s.Buffers))
+			for name, b := range s.Buffers {
+				if b.Dtype != p.Dtype {
+					return fmt.Errorf("%s: buffer %q has data type %s, parameter has %s", o.name, name, b.Dtype.DataType(), p.Dtype.DataType())
+				}
+				buffers[name] = cloneTensor(b)
+			}
+			loaded[p] = &ParamState{Step: s.Step, Buffers: buffers, Values: maps.Clone(s.Values)}
+		}
+	}
+	for i, g := range o.groups {
+		g.Options = options[i]
+	}
+	o.state = loaded
+	return nil
+}
+
+// update calls fn on every parameter that has a gradient, with the options
+// of its group and its state. The calls run in parallel across parameters
+// with graph recording turned off.
+func (o *optimizer[O]) update(fn func(p, grad *tensors.Tensor, opts O, s *ParamState) error) error {
+	type item struct {
+		p    *nn.Parameter
+		opts O
+		s    *ParamState
+	}
+	var items []item
+	size := 0
+	for _, g := range o.groups {
+		for _, p := range g.Params {
+			if p.Grad == nil {
+				continue
+			}
+			if p.Grad.Dtype != p.Dtype || shapeSize(p.Grad.Shape) != shapeSize(p.Shape) {
+				return fmt.Errorf("%s: gradient does not match its parameter of shape %v", o.name, p.Shape)
+			}
+			s, ok := o.state[p]
+			if !ok {
+				s = &ParamState{Buffers: map[string]*tensors.Tensor{}}
+				o.state[p] = s
+			}
+			items = append(items, item{p: p, opts: g.Options, s: s})
+			size += shapeSize(p.Shape)
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	errs := make([]error, len(items))
+	tensors.NoGrad(func() {
+		parallel.For(len(items), 8*size/len(items), func(start, end int) {
+			for i := start; i < end; i++ {
+				it := items[i]
+				errs[i] = fn(it.p.Tensor, it.p.Grad, it.opts, it.s)
+			}
+		})
+	})
+	return errors.Join(errs...)
+}
+
+// evaluate calls closure when there is one.
+func evaluate(closure Closure) (float64, error) {
+	if closure == nil {
+		return 0, nil
+	}
+	return closure()
+}
+
+// buffer returns the state buffer of p called name, creating it filled with
+// value when it does not exist yet. created reports whether it did not.
+func buffer[T float32 | float64](s *ParamState, name string, p *tensors.Tensor, value float64) (data []T, created bool, err error) {
+	b, ok := s.Buffers[name]
+	if !ok {
+		values := make([]T, shapeSize(p.Shape))
+		if value != 0 {
+			for i := range values {
+				values[i] = T(value)
+			}
+		}
+		b = &tensors.Tensor{Shape: append([]int{}, p.Shape...), Data: values, Dtype: p.Dtype}
+		s.Buffers[name] = b
+		created = true
+	}
+	data, ok = b.Data.([]T)
+	if !ok || len(data) != shapeSize(p.Shape) {
+		return nil, false, fmt.Errorf("state buffer %q does not match its parameter of shape %v", name, p.Shape)
+	}
+	return data, created, nil
+}
+
+// addInPlace adds delta, laid out like p, to p.
+func addInPlace[T float32 | float64](p *tensors.Tensor, delta []T) error {
+	return p.AddInPlace(&tensors.Tensor{Shape: p.Shape, Data: delta, Dtype: p.Dtype})
+}
+
+var errDtype = errors.New("optimizers support float32 and float64 parameters only")
+
+func cloneTensor(t *tensors.Tensor) *tensors.Tensor {
+	c := &tensors.Tensor{Shape: append([]int{}, t.Shape...), Dtype: t.Dtype, Device: t.Device}
+	switch data := t.Data.(type) {
+	case []float32:
+		c.Data = append([]float32{}, data...)
+	case []float64:
+		c.Data = append([]float64{}, data...)
+	case []int64:
+		c.Data = append([]int64{}, data...)
+	}
+	return c
+}
+
+func shapeSize(shape []int) int {
+	size := 1
+	for _, d := range shape {
+		size *= d
+	}
+	return size
+}
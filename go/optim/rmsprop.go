@@ -0,0 +1,127 @@
+package optim
+
+import (
+	"errors"
+	"math"
+
+	"gotorch/nn"
+	"gotorch/tensors"
+)
+
+// RMSPropOptions are the hyperparameters of RMSProp. Zero Alpha and Eps
+// select the defaults of 0.99 and 1e-8. PyTorch defaults LR to 1e-2.
+type RMSPropOptions struct {
+	LR          float64
+	Alpha       float64 // smoothing constant of the squared gradient average
+	Eps         float64
+	WeightDecay float64
+	Momentum    float64
+	Centered    bool // normalise by the estimated variance instead of the second moment
+	Maximize    bool
+}
+
+// RMSProp divides every gradient by a running root mean square of its past
+// values.
+type RMSProp struct {
+	optimizer[RMSPropOptions]
+}
+
+// NewRMSProp returns an RMSProp optimizer over params.
+func NewRMSProp(params []*nn.Parameter, opts RMSPropOptions) (*RMSProp, error) {
+	o, err := newOptimizer("RMSProp", params, opts, prepareRMSProp, func(o *RMSPropOptions) *float64 { return &o.LR })
+	if err != nil {
+		return nil, err
+	}
+	return &RMSProp{o}, nil
+}
+
+func prepareRMSProp(opts RMSPropOptions) (RMSPropOptions, error) {
+	if opts.Alpha == 0 {
+		opts.Alpha = 0.99
+	}
+	if opts.Eps == 0 {
+		opts.Eps = 1e-8
+	}
+	switch {
+	case opts.LR < 0:
+		return opts, errors.New("learning rate must be non-negative")
+	case opts.Eps < 0:
+		return opts, errors.New("eps must be non-negative")
+	case opts.Alpha < 0:
+		return opts, errors.New("alpha must be non-negative")
+	case opts.Momentum < 0:
+		return opts, errors.New("momentum must be non-negative")
+	case opts.WeightDecay < 0:
+		return opts, errors.New("weight decay must be non-negative")
+	}
+	return opts, nil
+}
+
+// Momentum returns the momentum of parameter group i.
+func (o *RMSProp) Momentum(i int) float64 {
+	return o.groups[i].Options.Momentum
+}
+
+// SetMomentum sets the momentum of parameter group i.
+func (o *RMSProp) SetMomentum(i int, momentum float64) {
+	o.groups[i].Options.Momentum = momentum
+}
+
+func (o *RMSProp) Step(closure Closure) (float64, error) {
+	loss, err := evaluate(closure)
+	if err != nil {
+		return 0, err
+	}
+	return loss, o.update(func(p, grad *tensors.Tensor, opts RMSPropOptions, s *ParamState) error {
+		switch p.Data.(type) {
+		case []float32:
+			return rmspropStep[float32](p, grad, opts, s)
+		case []float64:
+			return rmspropStep[float64](p, grad, opts, s)
+		}
+		return errDtype
+	})
+}
+
+func rmspropStep[T float32 | float64](p, grad *tensors.Tensor, opts RMSPropOptions, s *ParamState) error {
+	x, g := p.Data.([]T), grad.Data.([]T)
+	sq, _, err := buffer[T](s, "square_avg", p, 0)
+	if err != nil {
+		return err
+	}
+	var avg, buf []T
+	if opts.Centered {
+		if avg, _, err = buffer[T](s, "grad_avg", p, 0); err != nil {
+			return err
+		}
+	}
+	if opts.Momentum > 0 {
+		if buf, _, err = buffer[T](s, "momentum_buffer", p, 0); err != nil {
+			return err
+		}
+	}
+	s.Step++
+	alpha := opts.Alpha
+	delta := make([]T, len(x))
+	for i := range x {
+		d := float64(g[i])
+		if opts.Maximize {
+			d = -d
+		}
+		d += opts.WeightDecay * float64(x[i])
+		v := alpha*float64(sq[i]) + (1-alpha)*d*d
+		sq[i] = T(v)
+		if avg != nil {
+			a := alpha*float64(avg[i]) + (1-alpha)*d
+			avg[i] = T(a)
+			v -= a * a
+		}
+		d /= math.Sqrt(v) + opts.Eps
+		if buf != nil {
+			d += opts.Momentum * float64(buf[i])
+			buf[i] = T(d)
+		}
+		delta[i] = T(-opts.LR * d)
+	}
+	return addInPlace(p, delta)
+}
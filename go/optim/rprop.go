@@ -0,0 +1,105 @@
+package optim
+
+import (
+	"errors"
+	"math"
+
+	"gotorch/nn"
+	"gotorch/tensors"
+)
+
+// RpropOptions are the hyperparameters of Rprop. Zero Etas and StepSizes
+// select the defaults of (0.5, 1.2) and (1e-6, 50). PyTorch defaults LR,
+// the initial step size, to 1e-2.
+type RpropOptions struct {
+	LR        float64
+	Etas      [2]float64 // factors shrinking and growing the step sizes
+	StepSizes [2]float64 // bounds of the step sizes
+	Maximize  bool
+}
+
+// Rprop is resilient backpropagation: every element moves against the sign
+// of its gradient by a step size of its own, which grows while the sign
+// stays the same and shrinks when it flips.
+type Rprop struct {
+	optimizer[RpropOptions]
+}
+
+// NewRprop returns an Rprop optimizer over params.
+func NewRprop(params []*nn.Parameter, opts RpropOptions) (*Rprop, error) {
+	o, err := newOptimizer("Rprop", params, opts, prepareRprop, func(o *RpropOptions) *float64 { return &o.LR })
+	if err != nil {
+		return nil, err
+	}
+	return &Rprop{o}, nil
+}
+
+func prepareRprop(opts RpropOptions) (RpropOptions, error) {
+	if opts.Etas == [2]float64{} {
+		opts.Etas = [2]float64{0.5, 1.2}
+	}
+	if opts.StepSizes == [2]float64{} {
+		opts.StepSizes = [2]float64{1e-6, 50}
+	}
+	switch {
+	case opts.LR < 0:
+		return opts, errors.New("learning rate must be non-negative")
+	case opts.Etas[0] <= 0 || opts.Etas[0] >= 1 || opts.Etas[1] <= 1:
+		return opts, errors.New("etas must satisfy 0 < etaminus < 1 < etaplus")
+	case opts.StepSizes[0] > opts.StepSizes[1]:
+		return opts, errors.New("the minimum step size exceeds the maximum")
+	}
+	return opts, nil
+}
+
+func (o *Rprop) Step(closure Closure) (float64, error) {
+	loss, err := evaluate(closure)
+	if err != nil {
+		return 0, err
+	}
+	return loss, o.update(func(p, grad *tensors.Tensor, opts RpropOptions, s *ParamState) error {
+		switch p.Data.(type) {
+		case []float32:
+			return rpropStep[float32](p, grad, opts, s)
+		case []float64:
+			return rpropStep[float64](p, grad, opts, s)
+		}
+		return errDtype
+	})
+}
+
+func rpropStep[T float32 | float64](p, grad *tensors.Tensor, opts RpropOptions, s *ParamState) error {
+	x, g := p.Data.([]T), grad.Data.([]T)
+	prev, _, err := buffer[T](s, "prev", p, 0)
+	if err != nil {
+		return err
+	}
+	steps, _, err := buffer[T](s, "step_size", p, opts.LR)
+	if err != nil {
+		return err
+	}
+	s.Step++
+	delta := make([]T, len(x))
+	for i := range x {
+		d := float64(g[i])
+		if opts.Maximize {
+			d = -d
+		}
+		step := float64(steps[i])
+		switch product := d * float64(prev[i]); {
+		case product > 0:
+			step *= opts.Etas[1]
+		case product < 0:
+			step *= opts.Etas[0]
+			// The previous step overshot: skip this one and do not count
+			// the sign change again next time.
+			d = 0
+		}
+		step = min(max(step, opts.StepSizes[0]), opts.StepSizes[1])
+		steps[i], prev[i] = T(step), T(d)
+		if d != 0 {
+			delta[i] = T(-math.Copysign(step, d))
+		}
+	}
+	return addInPlace(p, delta)
+}
@@ -0,0 +1,109 @@
+package optim
+
+import (
+	"errors"
+
+	"gotorch/nn"
+	"gotorch/tensors"
+)
+
+// SGDOptions are the hyperparameters of SGD. PyTorch defaults every option
+// but LR to zero or false.
+type SGDOptions struct {
+	LR          float64
+	Momentum    float64
+	Dampening   float64
+	WeightDecay float64
+	Nesterov    bool
+	Maximize    bool
+}
+
+// SGD is stochastic gradient descent with optional momentum, Nesterov
+// momentum and L2 weight decay.
+type SGD struct {
+	optimizer[SGDOptions]
+}
+
+// NewSGD returns an SGD optimizer over params.
+func NewSGD(params []*nn.Parameter, opts SGDOptions) (*SGD, error) {
+	o, err := newOptimizer("SGD", params, opts, prepareSGD, func(o *SGDOptions) *float64 { return &o.LR })
+	if err != nil {
+		return nil, err
+	}
+	return &SGD{o}, nil
+}
+
+func prepareSGD(opts SGDOptions) (SGDOptions, error) {
+	switch {
+	case opts.LR < 0:
+		return opts, errors.New("learning rate must be non-negative")
+	case opts.Momentum < 0:
+		return opts, errors.New("momentum must be non-negative")
+	case opts.WeightDecay < 0:
+		return opts, errors.New("weight decay must be non-negative")
+	case opts.Nesterov && (opts.Momentum == 0 || opts.Dampening != 0):
+		return opts, errors.New("Nesterov momentum requires a momentum and zero dampening")
+	}
+	return opts, nil
+}
+
+// Momentum returns the momentum of parameter group i.
+func (o *SGD) Momentum(i int) float64 {
+	return o.groups[i].Options.Momentum
+}
+
+// SetMomentum sets the momentum of parameter group i.
+func (o *SGD) SetMomentum(i int, momentum float64) {
+	o.groups[i].Options.Momentum = momentum
+}
+
+func (o *SGD) Step(closure Closure) (float64, error) {
+	loss, err := evaluate(closure)
+	if err != nil {
+		return 0, err
+	}
+	return loss, o.update(func(p, grad *tensors.Tensor, opts SGDOptions, s *ParamState) error {
+		switch p.Data.(type) {
+		case []float32:
+			return sgdStep[float32](p, grad, opts, s)
+		case []float64:
+			return sgdStep[float64](p, grad, opts, s)
+		}
+		return errDtype
+	})
+}
+
+func sgdStep[T float32 | float64](p, grad *tensors.Tensor, opts SGDOptions, s *ParamState) error {
+	x, g := p.Data.([]T), grad.Data.([]T)
+	var buf []T
+	first := false
+	if opts.Momentum != 0 {
+		var err error
+		if buf, first, err = buffer[T](s, "momentum_buffer", p, 0); err != nil {
+			return err
+		}
+	}
+	s.Step++
+	delta := make([]T, len(x))
+	for i := range x {
+		d := float64(g[i])
+		if opts.Maximize {
+			d = -d
+		}
+		d += opts.WeightDecay * float64(x[i])
+		if buf != nil {
+			b := d
+			if !first {
+				b = opts.Momentum*float64(buf[i]) + (1-opts.Dampening)*d
+			}
+			buf[i] = T(b)
+			if opts.Nesterov {
+				d += opts.Momentum * b
+			} else {
+				d = b
+			}
+		}
+		delta[i] = T(-opts.LR * d)
+	}
+	return addInPlace(p, delta)
+}
@@ -0,0 +1,127 @@
+package tensors
+
+import (
+	"errors"
+	"fmt"
+
+	"gotorch/internal/parallel"
+)
+
+// Addmm returns beta*input + alpha*(mat1 @ mat2) for an n x m matrix mat1
+// and an m x p matrix mat2. input is broadcast to n x p; when beta is zero
+// it is ignored, so NaNs in it do not propagate.
+func Addmm(input, mat1, mat2 *Tensor, beta, alpha float64) (*Tensor, error) {
+	if len(mat1.Shape) != 2 || len(mat2.Shape) != 2 {
+		return nil, errors.New("Addmm requires 2-d matrices")
+	}
+	if input.Dtype != mat1.Dtype || mat1.Dtype != mat2.Dtype {
+		return nil, errors.New("tensors must have the same data type")
+	}
+	n, m, p := mat1.Shape[0], mat1.Shape[1], mat2.Shape[1]
+	if mat2.Shape[0] != m {
+		return nil, fmt.Errorf("shapes %v and %v are not aligned for Addmm", mat1.Shape, mat2.Shape)
+	}
+	outShape := []int{n, p}
+	if shape, err := broadcastShapes(input.Shape, outShape); err != nil || !equalShapes(shape, outShape) {
+		return nil, fmt.Errorf("input of shape %v cannot be broadcast to %v", input.Shape, outShape)
+	}
+
+	var data interface{}
+	switch x := mat1.Data.(type) {
+	case []float32:
+		data = addmmKernel(input.Data.([]float32), x, mat2.Data.([]float32), input.Shape, n, m, p, float32(beta), float32(alpha))
+	case []float64:
+		data = addmmKernel(input.Data.([]float64), x, mat2.Data.([]float64), input.Shape, n, m, p, beta, alpha)
+	default:
+		return nil, errors.New("unsupported data type for Addmm")
+	}
+
+	out := &Tensor{
+		Shape:     outShape,
+		Data:      data,
+		Dtype:     mat1.Dtype,
+		PinMemory: mat1.PinMemory,
+	}
+	p1, p2 := mat1.primal(), mat2.primal()
+	inputShape := input.Shape
+	err := recordBackward(out, "AddmmBackward", []*Tensor{input, mat1, mat2}, []*Tensor{p1, p2}, func(grad *Tensor) ([]*Tensor, error) {
+		var gi *Tensor
+		if beta != 0 {
+			g, err := scale(grad, beta)
+			if err != nil {
+				return nil, err
+			}
+			if gi, err = sumTo(g, inputShape); err != nil {
+				return nil, err
+			}
+		}
+		g, err := scale(grad, alpha)
+		if err != nil {
+			return nil, err
+		}
+		gs, err := matMulBackward(g, p1, p2, p1.Shape, p2.Shape, outShape)
+		if err != nil {
+			return nil, err
+		}
+		return []*Tensor{gi, gs[0], gs[1]}, nil
+	})
+	if err != nil || !hasTangent(input, mat1, mat2) {
+		return out, err
+	}
+
+	// d(beta*C + alpha*AB) = beta*dC + alpha*(dA*B + A*dB)
+	ti, err := tangentOf(input)
+	if err != nil {
+		return nil, err
+	}
+	t1, t2, err := tangentsOf(mat1, mat2)
+	if err != nil {
+		return nil, err
+	}
+	left, err := Addmm(ti, t1, p2, beta, alpha)
+	if err != nil {
+		return nil, err
+	}
+	zero, err := zerosLike(out)
+	if err != nil {
+		return nil, err
+	}
+	right, err := Addmm(zero, p1, t2, 0, alpha)
+	if err != nil {
+		return nil, err
+	}
+	if out.tangent, err = Add(left, right); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func addmmKernel[T float32 | float64](input, a, b []T, inputShape []int, n, m, p int, beta, alpha T) []T {
+	result := make([]T, n*p)
+	gemm(a, b, result, n, m, p)
+	if alpha == 1 && beta == 0 {
+		return result
+	}
+	var in []T
+	if beta != 0 {
+		in = broadcastKernel(input, inputShape, []int{n, p})
+	}
+	parallel.For(len(result), 1, func(start, end int) {
+		for i := start; i < end; i++ {
+			v := alpha * result[i]
+			if beta != 0 {
+				v += beta * in[i]
+			}
+			result[i] = v
+		}
+	})
+	return result
+}
+
+// scale returns t multiplied by the scalar s without recording it.
+func scale(t *Tensor, s float64) (*Tensor, error) {
+	if s == 1 {
+		return t, nil
+	}
+	return unaryOp(t, func(x float32) float32 { return x * float32(s) }, func(x float64) float64 { return x * s })
+}
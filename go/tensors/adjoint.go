@@ -4,6 +4,8 @@ import (
 	"errors"
 )
 
+// Adjoint swaps the last two dimensions of a tensor. Any leading dimensions
+// are treated as a batch of matrices.
 func Adjoint(t *Tensor) (*Tensor, error) {
 	if len(t.Shape) < 2 {
 		return nil, errors.New("Adjoint is only valid for tensors with at least 2 dimensions")
@@ -20,28 +22,48 @@ func Adjoint(t *Tensor) (*Tensor, error) {
 	var newData interface{}
 	switch data := t.Data.(type) {
 	case []float32:
-		newData = make([]float32, len(data))
-		for i := 0; i < rows; i++ {
-			for j := 0; j < cols; j++ {
-				newData.([]float32)[j*rows+i] = data[i*cols+j]
-			}
-		}
+		newData = adjointKernel(data, rows, cols)
 	case []float64:
-		newData = make([]float64, len(data))
+		newData = adjointKernel(data, rows, cols)
+	default:
+		return nil, errors.New("unsupported data type for Adjoint")
+	}
+
+	out := &Tensor{
+		Shape:     newShape,
+		Data:      newData,
+		Dtype:     t.Dtype,
+		PinMemory: t.PinMemory,
+	}
+	err := recordBackward(out, "AdjointBackward", []*Tensor{t}, nil, func(grad *Tensor) ([]*Tensor, error) {
+		g, err := Adjoint(grad)
+		return []*Tensor{g}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if t.tangent != nil {
+		tangent, err := Adjoint(t.tangent)
+		if err != nil {
+			return nil, err
+		}
+		out.tangent = tangent
+	}
+	return out, nil
+}
+
+func adjointKernel[T float32 | float64](data []T, rows, cols int) []T {
+	result := make([]T, len(data))
+	size := rows * cols
+	if size == 0 {
+		return result
+	}
+	for b := 0; b+size <= len(data); b += size {
 		for i := 0; i < rows; i++ {
 			for j := 0; j < cols; j++ {
-				newData.([]float64)[j*rows+i] = data[i*cols+j]
+				result[b+j*rows+i] = data[b+i*cols+j]
 			}
 		}
-	default:
-		return nil, errors.New("unsupported data type for Adjoint")
 	}
-
-	return &Tensor{
-		Shape:        newShape,
-		Data:         newData,
-		Dtype:        t.Dtype,
-		RequiresGrad: t.RequiresGrad,
-		PinMemory:    t.PinMemory,
-	}, nil
+	return result
 }
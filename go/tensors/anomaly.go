@@ -0,0 +1,79 @@
+package tensors
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+var anomalyEnabled atomic.Bool
+
+// IsAnomalyEnabled reports whether anomaly detection is on.
+func IsAnomalyEnabled() bool {
+	return anomalyEnabled.Load()
+}
+
+// SetDetectAnomaly turns anomaly detection on or off and returns the
+// previous setting. While it is on, ops capture the stack trace that created
+// their backward node and Backward checks every gradient a node produces for
+// NaN and Inf values.
+func SetDetectAnomaly(enabled bool) bool {
+	return anomalyEnabled.Swap(enabled)
+}
+
+// DetectAnomaly runs fn with anomaly detection turned on.
+func DetectAnomaly(fn func()) {
+	defer SetDetectAnomaly(SetDetectAnomaly(true))
+	fn()
+}
+
+// AnomalyError is returned by Backward when anomaly detection finds a NaN or
+// Inf in a gradient. Stack is the Go stack trace of the forward op that
+// created the offending node.
+type AnomalyError struct {
+	Op     string
+	Output int    // index of the offending gradient among the node's outputs
+	Value  string // "nan" or "inf"
+	Stack  []byte
+}
+
+func (e *AnomalyError) Error() string {
+	msg := fmt.Sprintf("function %s returned %s values in its %d-th output", e.Op, e.Value, e.Output)
+	if len(e.Stack) == 0 {
+		return msg
+	}
+	return fmt.Sprintf("%s\nforward call that created the node:\n%s", msg, e.Stack)
+}
+
+func checkAnomaly(node *Node, grads []*Tensor) error {
+	for i, g := range grads {
+		if g == nil || i >= len(node.next) || !node.next[i].valid() {
+			continue
+		}
+		var value string
+		switch data := g.Data.(type) {
+		case []float32:
+			value = nonFinite(data)
+		case []float64:
+			value = nonFinite(data)
+		}
+		if value != "" {
+			return &AnomalyError{Op: node.Name, Output: i, Value: value, Stack: node.stack}
+		}
+	}
+	return nil
+}
+
+// nonFinite returns "nan" or "inf" for the first non-finite value in data,
+// or "" when every value is finite.
+func nonFinite[T float32 | float64](data []T) string {
+	for _, v := range data {
+		switch {
+		case math.IsNaN(float64(v)):
+			return "nan"
+		case math.IsInf(float64(v), 0):
+			return "inf"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,280 @@
+package tensors
+
+import (
+	"errors"
+)
+
+// Add returns the elementwise sum a + b. The operands are broadcast
+// against each other.
+func Add(a, b *Tensor) (*Tensor, error) {
+	out, err := binaryOp(a, b, add[float32], add[float64])
+	if err != nil {
+		return nil, err
+	}
+	aShape, bShape := a.Shape, b.Shape
+	err = recordBackward(out, "AddBackward", []*Tensor{a, b}, nil, func(grad *Tensor) ([]*Tensor, error) {
+		ga, err := sumTo(grad, aShape)
+		if err != nil {
+			return nil, err
+		}
+		gb, err := sumTo(grad, bShape)
+		return []*Tensor{ga, gb}, err
+	})
+	if err != nil || !hasTangent(a, b) {
+		return out, err
+	}
+
+	ta, tb, err := tangentsOf(a, b)
+	if err != nil {
+		return nil, err
+	}
+	if out.tangent, err = Add(ta, tb); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Sub returns the elementwise difference a - b. The operands are broadcast
+// against each other.
+func Sub(a, b *Tensor) (*Tensor, error) {
+	out, err := binaryOp(a, b, sub[float32], sub[float64])
+	if err != nil {
+		return nil, err
+	}
+	aShape, bShape := a.Shape, b.Shape
+	err = recordBackward(out, "SubBackward", []*Tensor{a, b}, nil, func(grad *Tensor) ([]*Tensor, error) {
+		ga, err := sumTo(grad, aShape)
+		if err != nil {
+			return nil, err
+		}
+		neg, err := Neg(grad)
+		if err != nil {
+			return nil, err
+		}
+		gb, err := sumTo(neg, bShape)
+		return []*Tensor{ga, gb}, err
+	})
+	if err != nil || !hasTangent(a, b) {
+		return out, err
+	}
+
+	ta, tb, err := tangentsOf(a, b)
+	if err != nil {
+		return nil, err
+	}
+	if out.tangent, err = Sub(ta, tb); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Mul returns the elementwise product a * b. The operands are broadcast
+// against each other.
+func Mul(a, b *Tensor) (*Tensor, error) {
+	out, err := binaryOp(a, b, mul[float32], mul[float64])
+	if err != nil {
+		return nil, err
+	}
+	pa, pb := a.primal(), b.primal()
+	err = recordBackward(out, "MulBackward", []*Tensor{a, b}, []*Tensor{pa, pb}, func(grad *Tensor) ([]*Tensor, error) {
+		ga, err := Mul(grad, pb)
+		if err != nil {
+			return nil, err
+		}
+		if ga, err = sumTo(ga, pa.Shape); err != nil {
+			return nil, err
+		}
+		gb, err := Mul(grad, pa)
+		if err != nil {
+			return nil, err
+		}
+		gb, err = sumTo(gb, pb.Shape)
+		return []*Tensor{ga, gb}, err
+	})
+	if err != nil || !hasTangent(a, b) {
+		return out, err
+	}
+
+	// d(ab) = da*b + a*db
+	ta, tb, err := tangentsOf(a, b)
+	if err != nil {
+		return nil, err
+	}
+	left, err := Mul(ta, b.primal())
+	if err != nil {
+		return nil, err
+	}
+	right, err := Mul(a.primal(), tb)
+	if err != nil {
+		return nil, err
+	}
+	if out.tangent, err = Add(left, right); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Div returns the elementwise quotient a / b. The operands are broadcast
+// against each other.
+func Div(a, b *Tensor) (*Tensor, error) {
+	out, err := binaryOp(a, b, div[float32], div[float64])
+	if err != nil {
+		return nil, err
+	}
+	pa, pb, quotient := a.primal(), b.primal(), out.primal()
+	err = recordBackward(out, "DivBackward", []*Tensor{a, b}, []*Tensor{pb, quotient}, func(grad *Tensor) ([]*Tensor, error) {
+		// d/da = 1/b, d/db = -(a/b)/b
+		ga, err := Div(grad, pb)
+		if err != nil {
+			return nil, err
+		}
+		gb, err := Mul(ga, quotient)
+		if err != nil {
+			return nil, err
+		}
+		if gb, err = Neg(gb); err != nil {
+			return nil, err
+		}
+		if ga, err = sumTo(ga, pa.Shape); err != nil {
+			return nil, err
+		}
+		gb, err = sumTo(gb, pb.Shape)
+		return []*Tensor{ga, gb}, err
+	})
+	if err != nil || !hasTangent(a, b) {
+		return out, err
+	}
+
+	// d(a/b) = (da - (a/b)*db) / b
+	ta, tb, err := tangentsOf(a, b)
+	if err != nil {
+		return nil, err
+	}
+	scaled, err := Mul(out.primal(), tb)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := Sub(ta, scaled)
+	if err != nil {
+		return nil, err
+	}
+	if out.tangent, err = Div(diff, b.primal()); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Neg returns the elementwise negation of t.
+func Neg(t *Tensor) (*Tensor, error) {
+	out, err := unaryOp(t, neg[float32], neg[float64])
+	if err != nil {
+		return nil, err
+	}
+	err = recordBackward(out, "NegBackward", []*Tensor{t}, nil, func(grad *Tensor) ([]*Tensor, error) {
+		g, err := Neg(grad)
+		return []*Tensor{g}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if t.tangent != nil {
+		if out.tangent, err = Neg(t.tangent); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func add[T float32 | float64](x, y T) T { return x + y }
+func sub[T float32 | float64](x, y T) T { return x - y }
+func mul[T float32 | float64](x, y T) T { return x * y }
+func div[T float32 | float64](x, y T) T { return x / y }
+func neg[T float32 | float64](x T) T    { return -x }
+
+// unaryOp applies an elementwise function to a tensor.
+func unaryOp(t *Tensor, f32 func(x float32) float32, f64 func(x float64) float64) (*Tensor, error) {
+	var data interface{}
+	switch x := t.Data.(type) {
+	case []float32:
+		data = unaryKernel(x, f32)
+	case []float64:
+		data = unaryKernel(x, f64)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+
+	return &Tensor{
+		Shape:     append([]int{}, t.Shape...),
+		Data:      data,
+		Dtype:     t.Dtype,
+		PinMemory: t.PinMemory,
+	}, nil
+}
+
+func unaryKernel[T float32 | float64](x []T, f func(a T) T) []T {
+	result := make([]T, len(x))
+	for i, v := range x {
+		result[i] = f(v)
+	}
+	return result
+}
+
+// binaryOp applies an elementwise function to two broadcastable tensors of
+// the same data type.
+func binaryOp(a, b *Tensor, f32 func(x, y float32) float32, f64 func(x, y float64) float64) (*Tensor, error) {
+	if a.Dtype != b.Dtype {
+		return nil, errors.New("tensors must have the same data type")
+	}
+	outShape, err := broadcastShapes(a.Shape, b.Shape)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+	switch x := a.Data.(type) {
+	case []float32:
+		data = binaryKernel(x, b.Data.([]float32), a.Shape, b.Shape, outShape, f32)
+	case []float64:
+		data = binaryKernel(x, b.Data.([]float64), a.Shape, b.Shape, outShape, f64)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+
+	return &Tensor{
+		Shape:     outShape,
+		Data:      data,
+		Dtype:     a.Dtype,
+		PinMemory: a.PinMemory,
+	}, nil
+}
+
+func binaryKernel[T float32 | float64](x, y []T, xShape, yShape, outShape []int, f func(a, b T) T) []T {
+	result := make([]T, shapeSize(outShape))
+	if equalShapes(xShape, outShape) && equalShapes(yShape, outShape) {
+		for i := range result {
+			result[i] = f(x[i], y[i])
+		}
+		return result
+	}
+
+	xi := broadcastIndices(xShape, outShape)
+	yi := broadcastIndices(yShape, outShape)
+	for i := range result {
+		result[i] = f(x[xi[i]], y[yi[i]])
+	}
+	return result
+}
+
+// tangentsOf returns the tangents of a and b, filling in zeros for an
+// operand that carries none.
+func tangentsOf(a, b *Tensor) (*Tensor, *Tensor, error) {
+	ta, err := tangentOf(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	tb, err := tangentOf(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ta, tb, nil
+}
@@ -0,0 +1,354 @@
+package tensors
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// Node is a step of the backward graph. It is attached to the output of a
+// differentiable op as its GradFn and maps the gradient of that output to
+// the gradients of the op's inputs.
+type Node struct {
+	Name   string
+	Inputs []*Tensor
+	Saved  []*Tensor // tensors the backward function reads
+
+	backward      func(grad *Tensor) ([]*Tensor, error)
+	next          []vertex // where the gradient of each input goes, bound when the node is recorded
+	hooks         []gradHook
+	savedVersions []int64
+	stack         []byte // stack trace of the forward op, captured in anomaly mode
+	reentrant     bool   // backward runs a backward pass of its own, which Grad cannot capture
+}
+
+// Record attaches a backward node to out when grad mode is enabled and any
+// of inputs requires grad. backward receives the gradient of out and returns
+// one gradient per input, using nil for inputs it does not differentiate.
+// saved lists the tensors backward reads; Backward fails if any of them is
+// modified in place before the node runs. out.RequiresGrad is set to whether
+// the node was recorded.
+//
+// Record has no forward-mode rule, so it fails when any input is dual
+// rather than drop the tangent. Ops that support dual inputs use RecordJVP.
+func Record(out *Tensor, name string, inputs, saved []*Tensor, backward func(grad *Tensor) ([]*Tensor, error)) error {
+	return RecordJVP(out, name, inputs, saved, backward, nil)
+}
+
+// RecordJVP is Record for an op with a forward-mode rule. When any input is
+// dual, jvp receives the tangent of every input, nil for inputs without
+// one, and returns the tangent of out, the Jacobian-vector product of the
+// op. A nil jvp makes dual inputs an error, as with Record.
+func RecordJVP(out *Tensor, name string, inputs, saved []*Tensor, backward func(grad *Tensor) ([]*Tensor, error), jvp func(tangents []*Tensor) (*Tensor, error)) error {
+	out.tangent = nil
+	if hasTangent(inputs...) {
+		if jvp == nil {
+			return fmt.Errorf("%s does not support forward-mode differentiation of dual inputs", strings.TrimSuffix(name, "Backward"))
+		}
+		tangents := make([]*Tensor, len(inputs))
+		for i, in := range inputs {
+			tangents[i] = in.tangent
+		}
+		tangent, err := jvp(tangents)
+		if err != nil {
+			return err
+		}
+		if !equalShapes(tangent.Shape, out.Shape) || tangent.Dtype != out.Dtype {
+			return fmt.Errorf("%s: tangent must have the shape and data type of the output", name)
+		}
+		out.tangent = tangent.primal()
+	}
+	return recordBackward(out, name, inputs, saved, backward)
+}
+
+// recordBackward is Record for ops of this package, which set the tangent
+// of out themselves.
+func recordBackward(out *Tensor, name string, inputs, saved []*Tensor, backward func(grad *Tensor) ([]*Tensor, error)) error {
+	out.RequiresGrad = false
+	out.GradFn = nil
+	if IsInferenceMode() {
+		out.inference = true
+	}
+	if !IsGradEnabled() {
+		return nil
+	}
+
+	requiresGrad := false
+	for _, t := range inputs {
+		if t.RequiresGrad {
+			requiresGrad = true
+		}
+	}
+	if !requiresGrad {
+		return nil
+	}
+	return attach(out, &Node{Name: name, Inputs: inputs, Saved: saved, backward: backward})
+}
+
+// attach makes node the GradFn of out, failing if any input of node is an
+// inference tensor.
+func attach(out *Tensor, node *Node) error {
+	node.next = make([]vertex, len(node.Inputs))
+	for i, t := range node.Inputs {
+		if t.inference {
+			return errors.New("inference tensors cannot be used in an op recorded for backward")
+		}
+		if t.RequiresGrad {
+			node.next[i] = vertexOf(t)
+		}
+	}
+	node.savedVersions = make([]int64, len(node.Saved))
+	for i, t := range node.Saved {
+		node.savedVersions[i] = t.Version()
+	}
+	if IsAnomalyEnabled() {
+		node.stack = debug.Stack()
+	}
+	out.RequiresGrad = true
+	out.GradFn = node
+	return nil
+}
+
+// checkSavedVersions fails if a saved tensor was modified in place after
+// the node was recorded.
+func (n *Node) checkSavedVersions() error {
+	for i, t := range n.Saved {
+		if v := t.Version(); v != n.savedVersions[i] {
+			return fmt.Errorf("one of the tensors needed for gradient computation has been modified by an in-place operation: "+
+				"%s saved a tensor of shape %v at version %d, but it is now at version %d", n.Name, t.Shape, n.savedVersions[i], v)
+		}
+	}
+	return nil
+}
+
+// IsLeaf reports whether t was created by the user rather than by a
+// recorded op. Only leaves that require grad have their Grad populated.
+func (t *Tensor) IsLeaf() bool {
+	return t.GradFn == nil
+}
+
+// Detach returns a view of t that shares its data but is cut off from the
+// backward graph.
+func (t *Tensor) Detach() *Tensor {
+	return &Tensor{
+		Shape:     t.Shape,
+		Data:      t.Data,
+		Dtype:     t.Dtype,
+		Device:    t.Device,
+		PinMemory: t.PinMemory,
+		inference: t.inference,
+		version:   t.versionCounter(),
+	}
+}
+
+// Backward computes the gradient of t with respect to every leaf tensor in
+// its graph that requires grad, accumulating into their Grad fields. grad
+// is the gradient of t itself and may be nil when t holds a single element.
+func (t *Tensor) Backward(grad *Tensor) error {
+	_, err := runBackward(t, grad, nil)
+	return err
+}
+
+// Grad computes and returns the gradients of output with respect to inputs
+// without touching any Grad field. grad is the gradient of output and may
+// be nil when output holds a single element. The gradient of an input that
+// output does not depend on is nil.
+func Grad(output, grad *Tensor, inputs []*Tensor) ([]*Tensor, error) {
+	if inputs == nil {
+		inputs = []*Tensor{}
+	}
+	return runBackward(output, grad, inputs)
+}
+
+// runBackward propagates grad from root through the backward graph. With
+// nil inputs it accumulates into leaves, otherwise it returns the gradients
+// reaching inputs and accumulates nothing.
+func runBackward(root, grad *Tensor, inputs []*Tensor) ([]*Tensor, error) {
+	if !root.RequiresGrad {
+		return nil, errors.New("tensor does not require grad")
+	}
+	if grad == nil {
+		if shapeSize(root.Shape) != 1 {
+			return nil, errors.New("grad can be implicitly created only for single element outputs")
+		}
+		ones, err := NewOnes(append([]int{}, root.Shape...), root.Dtype, false, root.PinMemory)
+		if err != nil {
+			return nil, err
+		}
+		grad = ones
+	} else if !equalShapes(grad.Shape, root.Shape) {
+		return nil, errors.New("grad must have the same shape as the tensor")
+	}
+
+	defer SetGradEnabled(SetGradEnabled(false))
+	pass := beginPass()
+	defer endPass(pass)
+
+	captured := make(map[vertex][]int)
+	for i, in := range inputs {
+		captured[vertexOf(in)] = append(captured[vertexOf(in)], i)
+	}
+	results := make([]*Tensor, len(inputs))
+
+	rootVertex := vertexOf(root)
+	grads := map[vertex]*Tensor{rootVertex: grad.primal()}
+	for _, v := range topologicalOrder(rootVertex) {
+		g, ok := grads[v]
+		if !ok {
+			continue
+		}
+		delete(grads, v)
+
+		g, err := runHooks(v.hooks(), g)
+		if err != nil {
+			return nil, err
+		}
+		if indices, ok := captured[v]; ok {
+			for _, i := range indices {
+				results[i] = g
+			}
+		}
+		if v.fn == nil {
+			if inputs != nil {
+				continue
+			}
+			if err := v.leaf.accumulateGrad(g); err != nil {
+				return nil, err
+			}
+			for _, h := range v.leaf.postAccumulateHooks {
+				h.fn(v.leaf)
+			}
+			continue
+		}
+
+		n := v.fn
+		if n.reentrant && inputs != nil {
+			return nil, fmt.Errorf("%s is not supported by Grad, use Backward", n.Name)
+		}
+		if err := n.checkSavedVersions(); err != nil {
+			return nil, err
+		}
+		inputGrads, err := n.backward(g)
+		if err != nil {
+			return nil, err
+		}
+		if IsAnomalyEnabled() {
+			if err := checkAnomaly(n, inputGrads); err != nil {
+				return nil, err
+			}
+		}
+		for i, next := range n.next {
+			if i >= len(inputGrads) || inputGrads[i] == nil || !next.valid() {
+				continue
+			}
+			if prev, ok := grads[next]; ok {
+				if inputGrads[i], err = Add(prev, inputGrads[i]); err != nil {
+					return nil, err
+				}
+			}
+			grads[next] = inputGrads[i]
+		}
+	}
+	endPass(pass)
+	for _, fn := range pass.callbacks {
+		fn()
+	}
+	return results, nil
+}
+
+func (t *Tensor) accumulateGrad(grad *Tensor) error {
+	if !t.RequiresGrad {
+		return nil
+	}
+	if t.Grad == nil {
+		// Copy so later in-place updates of Grad cannot reach other
+		// tensors sharing the incoming buffer.
+		t.Grad = clone(grad)
+		return nil
+	}
+	sum, err := Add(t.Grad, grad)
+	if err != nil {
+		return err
+	}
+	t.Grad = sum
+	return nil
+}
+
+// clone returns a copy of t with its own data buffer.
+func clone(t *Tensor) *Tensor {
+	var data interface{}
+	switch src := t.Data.(type) {
+	case []float32:
+		data = append([]float32(nil), src...)
+	case []float64:
+		data = append([]float64(nil), src...)
+	default:
+		data = t.Data
+	}
+	return &Tensor{
+		Shape:     append([]int{}, t.Shape...),
+		Data:      data,
+		Dtype:     t.Dtype,
+		Device:    t.Device,
+		PinMemory: t.PinMemory,
+	}
+}
+
+// vertex is a point of the backward graph: the node that produced a
+// non-leaf tensor, or a leaf tensor. Edges are bound to vertices when an op
+// is recorded, so an in-place update that gives a tensor a new node leaves
+// the gradient of ops that read the old values on the old node.
+type vertex struct {
+	fn   *Node
+	leaf *Tensor
+}
+
+func vertexOf(t *Tensor) vertex {
+	if t.GradFn != nil {
+		return vertex{fn: t.GradFn}
+	}
+	return vertex{leaf: t}
+}
+
+func (v vertex) valid() bool {
+	return v.fn != nil || v.leaf != nil
+}
+
+// hooks returns the gradient hooks of v: those of the node for a non-leaf
+// tensor, those of the tensor for a leaf.
+func (v vertex) hooks() []gradHook {
+	if v.fn != nil {
+		return v.fn.hooks
+	}
+	return v.leaf.hooks
+}
+
+// topologicalOrder returns the vertices reachable from root through the
+// backward graph, ordered so that every vertex comes before its inputs.
+func topologicalOrder(root vertex) []vertex {
+	var order []vertex
+	visited := make(map[vertex]bool)
+
+	var visit func(v vertex)
+	visit = func(v vertex) {
+		if visited[v] {
+			return
+		}
+		visited[v] = true
+		if v.fn != nil {
+			for _, next := range v.fn.next {
+				if next.valid() {
+					visit(next)
+				}
+			}
+		}
+		order = append(order, v)
+	}
+	visit(root)
+
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	return order
+}
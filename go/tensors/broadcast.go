@@ -0,0 +1,163 @@
+package tensors
+
+import (
+	"errors"
+	"fmt"
+)
+
+// BroadcastTo expands a tensor to the given shape using the usual
+// right-aligned broadcasting rules. Dimensions of size 1 are repeated.
+func BroadcastTo(t *Tensor, shape []int) (*Tensor, error) {
+	outShape, err := broadcastShapes(t.Shape, shape)
+	if err != nil {
+		return nil, err
+	}
+	if !equalShapes(outShape, shape) {
+		return nil, fmt.Errorf("cannot broadcast shape %v to %v", t.Shape, shape)
+	}
+
+	var data interface{}
+	switch src := t.Data.(type) {
+	case []float32:
+		data = broadcastKernel(src, t.Shape, outShape)
+	case []float64:
+		data = broadcastKernel(src, t.Shape, outShape)
+	default:
+		return nil, errors.New("unsupported data type for BroadcastTo")
+	}
+
+	out := &Tensor{
+		Shape:     outShape,
+		Data:      data,
+		Dtype:     t.Dtype,
+		PinMemory: t.PinMemory,
+	}
+	inShape := t.Shape
+	err = recordBackward(out, "BroadcastToBackward", []*Tensor{t}, nil, func(grad *Tensor) ([]*Tensor, error) {
+		g, err := sumTo(grad, inShape)
+		return []*Tensor{g}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if t.tangent != nil {
+		if out.tangent, err = BroadcastTo(t.tangent, outShape); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// broadcastShapes returns the shape two tensors broadcast to.
+func broadcastShapes(a, b []int) ([]int, error) {
+	n := max(len(a), len(b))
+	out := make([]int, n)
+	for i := 0; i < n; i++ {
+		da, db := 1, 1
+		if j := len(a) - n + i; j >= 0 {
+			da = a[j]
+		}
+		if j := len(b) - n + i; j >= 0 {
+			db = b[j]
+		}
+		switch {
+		case da == db:
+			out[i] = da
+		case da == 1:
+			out[i] = db
+		case db == 1:
+			out[i] = da
+		default:
+			return nil, fmt.Errorf("shapes %v and %v cannot be broadcast together", a, b)
+		}
+	}
+	return out, nil
+}
+
+// broadcastIndices maps every element of a tensor with shape out to the
+// offset of the element it reads from in a tensor with shape.
+func broadcastIndices(shape, out []int) []int {
+	strides := make([]int, len(out))
+	stride := 1
+	for i := len(out) - 1; i >= 0; i-- {
+		j := len(shape) - len(out) + i
+		if j < 0 {
+			break
+		}
+		if shape[j] != 1 {
+			strides[i] = stride
+		}
+		stride *= shape[j]
+	}
+
+	n := shapeSize(out)
+	indices := make([]int, n)
+	counter := make([]int, len(out))
+	offset := 0
+	for i := 0; i < n; i++ {
+		indices[i] = offset
+		for d := len(out) - 1; d >= 0; d-- {
+			counter[d]++
+			offset += strides[d]
+			if counter[d] < out[d] {
+				break
+			}
+			offset -= strides[d] * out[d]
+			counter[d] = 0
+		}
+	}
+	return indices
+}
+
+func broadcastKernel[T float32 | float64](data []T, shape, out []int) []T {
+	result := make([]T, shapeSize(out))
+	if equalShapes(shape, out) {
+		copy(result, data)
+		return result
+	}
+	for i, j := range broadcastIndices(shape, out) {
+		result[i] = data[j]
+	}
+	return result
+}
+
+func shapeSize(shape []int) int {
+	size := 1
+	for _, dim := range shape {
+		size *= dim
+	}
+	return size
+}
+
+// sumTo reduces t, the gradient of a broadcast result, back to shape by
+// summing over the broadcast dimensions.
+func sumTo(t *Tensor, shape []int) (*Tensor, error) {
+	if equalShapes(t.Shape, shape) {
+		return t, nil
+	}
+
+	var data interface{}
+	switch src := t.Data.(type) {
+	case []float32:
+		data = sumToKernel(src, t.Shape, shape)
+	case []float64:
+		data = sumToKernel(src, t.Shape, shape)
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+
+	return &Tensor{
+		Shape:     append([]int{}, shape...),
+		Data:      data,
+		Dtype:     t.Dtype,
+		PinMemory: t.PinMemory,
+	}, nil
+}
+
+func sumToKernel[T float32 | float64](data []T, from, shape []int) []T {
+	result := make([]T, shapeSize(shape))
+	for i, j := range broadcastIndices(shape, from) {
+		result[j] += data[i]
+	}
+	return result
+}
@@ -0,0 +1,119 @@
+package tensors
+
+import "errors"
+
+// Checkpoint runs fn on inputs without keeping its intermediate activations
+// for backward. When the gradient reaches the result, fn is run again with
+// grad enabled to rebuild them. Every generator fn drew from is rewound to
+// the state it had before the first run, so random ops such as dropout draw
+// the same values on recompute. Draws are recorded process wide, so a
+// generator used concurrently by another goroutine, such as a data loader,
+// is rewound too; give such goroutines their own generators and do not
+// draw from them while a checkpoint runs or recomputes.
+//
+// Leaf tensors that fn reads without receiving them as inputs, such as the
+// parameters of a layer, receive their gradients as usual. They are only
+// found on recompute, so with grad enabled the result always requires grad
+// and its gradient cannot be taken with Grad.
+func Checkpoint(fn func(inputs ...*Tensor) (*Tensor, error), inputs ...*Tensor) (*Tensor, error) {
+	if !IsGradEnabled() {
+		return fn(inputs...)
+	}
+
+	scope, end := beginRNGScope()
+	var full *Tensor
+	var err error
+	NoGrad(func() {
+		full, err = fn(detachInputs(inputs)...)
+	})
+	end()
+	if err != nil {
+		return nil, err
+	}
+	out := full.Detach()
+	out.tangent = full.tangent
+
+	node := &Node{Name: "CheckpointBackward", Inputs: inputs, Saved: inputs, reentrant: true}
+	node.backward = func(grad *Tensor) ([]*Tensor, error) {
+		recomputeInputs := detachInputs(inputs)
+		var recomputed *Tensor
+		var err error
+		replayErr := scope.replay(func() {
+			EnableGrad(func() {
+				recomputed, err = fn(recomputeInputs...)
+			})
+		})
+		if replayErr != nil {
+			return nil, replayErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !recomputed.RequiresGrad {
+			return nil, nil
+		}
+		// Accumulate into the leaves fn read and collect the gradients of
+		// the inputs from their detached copies.
+		if _, err := runBackward(recomputed, grad, nil); err != nil {
+			return nil, err
+		}
+		grads := make([]*Tensor, len(inputs))
+		for i, in := range recomputeInputs {
+			grads[i] = in.Grad
+		}
+		return grads, nil
+	}
+	if err := attach(out, node); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CheckpointSequential runs functions in order, feeding each one the result
+// of the previous. The functions are split into segments of equal size and
+// every segment but the last is run through Checkpoint, so only the inputs
+// of each segment are kept for backward.
+func CheckpointSequential(functions []func(x *Tensor) (*Tensor, error), segments int, input *Tensor) (*Tensor, error) {
+	if segments <= 0 {
+		return nil, errors.New("segments must be positive")
+	}
+
+	runSegment := func(fns []func(x *Tensor) (*Tensor, error)) func(inputs ...*Tensor) (*Tensor, error) {
+		return func(inputs ...*Tensor) (*Tensor, error) {
+			x := inputs[0]
+			for _, fn := range fns {
+				var err error
+				if x, err = fn(x); err != nil {
+					return nil, err
+				}
+			}
+			return x, nil
+		}
+	}
+
+	size := len(functions) / segments
+	end := 0
+	if size > 0 {
+		for start := 0; start < size*(segments-1); start += size {
+			end = start + size
+			var err error
+			if input, err = Checkpoint(runSegment(functions[start:end]), input); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return runSegment(functions[end:])(input)
+}
+
+// detachInputs returns leaf copies of inputs that keep their RequiresGrad
+// setting and forward-mode tangent, so fn builds a graph ending at them.
+func detachInputs(inputs []*Tensor) []*Tensor {
+	detached := make([]*Tensor, len(inputs))
+	for i, in := range inputs {
+		d := in.Detach()
+		d.RequiresGrad = in.RequiresGrad
+		d.tangent = in.tangent
+		detached[i] = d
+	}
+	return detached
+}
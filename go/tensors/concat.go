@@ -24,6 +24,12 @@ func Cat(tensors []*Tensor, dim int) (*Tensor, error) {
 
 	baseShape := tensors[0].Shape
 	baseDtype := tensors[0].Dtype
+	if dim < 0 {
+		dim += len(baseShape)
+	}
+	if dim < 0 || dim >= len(baseShape) {
+		return nil, errors.New("dimension out of range")
+	}
 	for _, t := range tensors {
 		if len(t.Shape) != len(baseShape) {
 			return nil, errors.New("tensors must have the same number of dimensions")
@@ -40,61 +46,108 @@ func Cat(tensors []*Tensor, dim int) (*Tensor, error) {
 
 	newShape := make([]int, len(baseShape))
 	copy(newShape, baseShape)
+	newShape[dim] = 0
 	for _, t := range tensors {
 		newShape[dim] += t.Shape[dim]
 	}
 
+	var out *Tensor
 	switch baseDtype {
 	case Float32{}:
 		concatenatedData, err := concatenateFloat32(tensors, dim)
 		if err != nil {
 			return nil, err
 		}
-		return &Tensor{
-			Shape:        newShape,
-			Data:         concatenatedData,
-			Dtype:        Float32{},
-			RequiresGrad: tensors[0].RequiresGrad,
-			PinMemory:    tensors[0].PinMemory,
-		}, nil
+		out = &Tensor{
+			Shape:     newShape,
+			Data:      concatenatedData,
+			Dtype:     Float32{},
+			PinMemory: tensors[0].PinMemory,
+		}
 	case Float64{}:
 		concatenatedData, err := concatenateFloat64(tensors, dim)
 		if err != nil {
 			return nil, err
 		}
-		return &Tensor{
-			Shape:        newShape,
-			Data:         concatenatedData,
-			Dtype:        Float64{},
-			RequiresGrad: tensors[0].RequiresGrad,
-			PinMemory:    tensors[0].PinMemory,
-		}, nil
+		out = &Tensor{
+			Shape:     newShape,
+			Data:      concatenatedData,
+			Dtype:     Float64{},
+			PinMemory: tensors[0].PinMemory,
+		}
 	default:
 		return nil, errors.New("unsupported data type")
 	}
-}
 
-func concatenateFloat32(tensors []*Tensor, dim int) ([]float32, error) {
-	var result []float32
-	for _, t := range tensors {
-		data, err := t.GetData()
+	sizes := make([]int, len(tensors))
+	for i, t := range tensors {
+		sizes[i] = t.Shape[dim]
+	}
+	err := recordBackward(out, "CatBackward", tensors, nil, func(grad *Tensor) ([]*Tensor, error) {
+		grads := make([]*Tensor, len(sizes))
+		start := 0
+		for i, size := range sizes {
+			g, err := Narrow(grad, dim, start, size)
+			if err != nil {
+				return nil, err
+			}
+			grads[i] = g
+			start += size
+		}
+		return grads, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if hasTangent(tensors...) {
+		tangents := make([]*Tensor, len(tensors))
+		for i, t := range tensors {
+			tangent, err := tangentOf(t)
+			if err != nil {
+				return nil, err
+			}
+			tangents[i] = tangent
+		}
+		tangent, err := Cat(tangents, dim)
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, data.([]float32)...)
+		out.tangent = tangent
 	}
-	return result, nil
+	return out, nil
+}
+
+func concatenateFloat32(tensors []*Tensor, dim int) ([]float32, error) {
+	return concatenate[float32](tensors, dim)
 }
 
 // Helper to concatenate float64 tensors along a dimension
 func concatenateFloat64(tensors []*Tensor, dim int) ([]float64, error) {
-	var result []float64
-	for _, t := range tensors {
+	return concatenate[float64](tensors, dim)
+}
+
+func concatenate[T float32 | float64](tensors []*Tensor, dim int) ([]T, error) {
+	parts := make([][]T, len(tensors))
+	total := 0
+	for i, t := range tensors {
 		data, err := t.GetData()
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, data.([]float64)...)
+		parts[i] = data.([]T)
+		total += len(parts[i])
+	}
+
+	// Interleave blocks of each input for every index of the leading dims.
+	outer := shapeSize(tensors[0].Shape[:dim])
+	inner := shapeSize(tensors[0].Shape[dim+1:])
+	result := make([]T, 0, total)
+	for o := 0; o < outer; o++ {
+		for i, t := range tensors {
+			block := t.Shape[dim] * inner
+			result = append(result, parts[i][o*block:(o+1)*block]...)
+		}
 	}
 	return result, nil
 }
@@ -0,0 +1,81 @@
+package tensors
+
+import "errors"
+
+// MakeDual pairs a primal tensor with a tangent for forward-mode automatic
+// differentiation. Every op applied to the result propagates the tangent, so
+// the tangent of an output is the Jacobian-vector product of the op with the
+// input tangents. Ops recorded through Record without a forward-mode rule
+// fail on dual inputs instead.
+func MakeDual(primal, tangent *Tensor) (*Tensor, error) {
+	if !equalShapes(primal.Shape, tangent.Shape) {
+		return nil, errors.New("tangent must have the same shape as the primal")
+	}
+	if primal.Dtype != tangent.Dtype {
+		return nil, errors.New("tangent must have the same data type as the primal")
+	}
+
+	return &Tensor{
+		Shape:        primal.Shape,
+		Data:         primal.Data,
+		Dtype:        primal.Dtype,
+		RequiresGrad: primal.RequiresGrad,
+		PinMemory:    primal.PinMemory,
+		tangent:      tangent.primal(),
+		version:      primal.versionCounter(),
+	}, nil
+}
+
+// UnpackDual splits a dual tensor into its primal and tangent. The tangent
+// is nil when t does not carry one.
+func UnpackDual(t *Tensor) (primal, tangent *Tensor) {
+	return t.primal(), t.tangent
+}
+
+// IsDual reports whether t carries a forward-mode tangent.
+func (t *Tensor) IsDual() bool {
+	return t.tangent != nil
+}
+
+// primal returns a view of t that shares its data but drops the tangent.
+func (t *Tensor) primal() *Tensor {
+	if t.tangent == nil {
+		return t
+	}
+	return &Tensor{
+		Shape:        t.Shape,
+		Data:         t.Data,
+		Dtype:        t.Dtype,
+		Device:       t.Device,
+		RequiresGrad: t.RequiresGrad,
+		PinMemory:    t.PinMemory,
+		Grad:         t.Grad,
+		GradFn:       t.GradFn,
+		inference:    t.inference,
+		version:      t.versionCounter(),
+	}
+}
+
+// hasTangent reports whether any of ts carries a tangent.
+func hasTangent(ts ...*Tensor) bool {
+	for _, t := range ts {
+		if t.tangent != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// tangentOf returns the tangent of t, or zeros shaped like t if it has none.
+func tangentOf(t *Tensor) (*Tensor, error) {
+	if t.tangent != nil {
+		return t.tangent, nil
+	}
+	return zerosLike(t)
+}
+
+func zerosLike(t *Tensor) (*Tensor, error) {
+	shape := make([]int, len(t.Shape))
+	copy(shape, t.Shape)
+	return NewZeroes(shape, t.Dtype, false, t.PinMemory)
+}
@@ -0,0 +1,58 @@
+package tensors
+
+import "sync/atomic"
+
+// Grad modes are process wide: toggling one from a goroutine affects ops
+// running concurrently on other goroutines. The same holds for the
+// generator recording of Checkpoint, which captures draws made by any
+// goroutine while the checkpointed function runs and rewinds those
+// generators during its backward.
+var (
+	gradDisabled  atomic.Bool
+	inferenceMode atomic.Bool
+)
+
+// IsGradEnabled reports whether ops currently record the backward graph.
+func IsGradEnabled() bool {
+	return !gradDisabled.Load()
+}
+
+// SetGradEnabled turns graph recording on or off and returns the previous
+// setting, so a scope can be written as
+//
+//	defer tensors.SetGradEnabled(tensors.SetGradEnabled(false))
+func SetGradEnabled(enabled bool) bool {
+	return !gradDisabled.Swap(!enabled)
+}
+
+// NoGrad runs fn with graph recording turned off. Results of ops inside fn
+// do not require grad and hold no references for backward.
+func NoGrad(fn func()) {
+	defer SetGradEnabled(SetGradEnabled(false))
+	fn()
+}
+
+// EnableGrad runs fn with graph recording turned on, even inside NoGrad.
+func EnableGrad(fn func()) {
+	defer SetGradEnabled(SetGradEnabled(true))
+	fn()
+}
+
+// IsInferenceMode reports whether ops are running under InferenceMode.
+func IsInferenceMode() bool {
+	return inferenceMode.Load()
+}
+
+// InferenceMode runs fn with graph recording turned off, like NoGrad, and
+// additionally marks every op result as an inference tensor. Inference
+// tensors can never be used later in an op recorded for backward.
+func InferenceMode(fn func()) {
+	defer inferenceMode.Store(inferenceMode.Swap(true))
+	defer SetGradEnabled(SetGradEnabled(false))
+	fn()
+}
+
+// IsInference reports whether t was produced under InferenceMode.
+func (t *Tensor) IsInference() bool {
+	return t.inference
+}
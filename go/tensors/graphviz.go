@@ -0,0 +1,86 @@
+package tensors
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteDOT writes the backward graph that ends at t as a Graphviz DOT
+// document. Op nodes are labelled with their name and the shape and dtype
+// of every tensor they saved for backward; leaf tensors are labelled with
+// their shape, dtype and whether they require grad. Edges point in the
+// direction of the forward pass. Render the output with, for example,
+//
+//	dot -Tsvg graph.dot -o graph.svg
+func WriteDOT(w io.Writer, t *Tensor) error {
+	if t.GradFn == nil {
+		return errors.New("tensor has no backward graph")
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph backward {")
+	fmt.Fprintln(bw, "\tnode [fontname=\"monospace\", fontsize=10, shape=box, style=filled, fillcolor=lightgrey];")
+
+	ids := make(map[interface{}]string)
+	id := func(key interface{}) string {
+		if name, ok := ids[key]; ok {
+			return name
+		}
+		name := fmt.Sprintf("n%d", len(ids))
+		ids[key] = name
+		return name
+	}
+	out := id(t)
+	fmt.Fprintf(bw, "\t%s [label=%q, fillcolor=darkolivegreen1];\n", out, "output\n"+describeTensor(t))
+
+	// Non-leaf tensors are identified by their node, so views that share a
+	// node are drawn once.
+	order := topologicalOrder(vertexOf(t))
+	for _, v := range order {
+		name := id(v)
+		if v.fn == nil {
+			label := "leaf\n" + describeTensor(v.leaf)
+			if v.leaf.RequiresGrad {
+				label += "\nrequires grad"
+			}
+			fmt.Fprintf(bw, "\t%s [label=%q, fillcolor=lightblue];\n", name, label)
+			continue
+		}
+
+		lines := []string{v.fn.Name}
+		for _, s := range v.fn.Saved {
+			lines = append(lines, "saved: "+describeTensor(s))
+		}
+		fmt.Fprintf(bw, "\t%s [label=%q];\n", name, strings.Join(lines, "\n"))
+	}
+
+	root := id(vertexOf(t))
+	fmt.Fprintf(bw, "\t%s -> %s;\n", root, out)
+	for _, v := range order {
+		if v.fn == nil {
+			continue
+		}
+		to := id(v)
+		for _, next := range v.fn.next {
+			if !next.valid() {
+				continue
+			}
+			from := id(next)
+			fmt.Fprintf(bw, "\t%s -> %s;\n", from, to)
+		}
+	}
+
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+func describeTensor(t *Tensor) string {
+	dtype := "unknown"
+	if t.Dtype != nil {
+		dtype = t.Dtype.DataType()
+	}
+	return fmt.Sprintf("%v %s", t.Shape, dtype)
+}
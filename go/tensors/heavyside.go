@@ -66,13 +66,27 @@ func Heavyside(input, values Tensor) (Tensor, error) {
 	default:
 		return Tensor{}, fmt.Errorf("unsupported data type")
 	}
-	return Tensor{
-		Shape:        input.Shape,
-		Data:         inputData,
-		Dtype:        input.Dtype,
-		RequiresGrad: input.RequiresGrad,
-		PinMemory:    input.PinMemory,
-	}, nil
+	out := Tensor{
+		Shape:     input.Shape,
+		Data:      inputData,
+		Dtype:     input.Dtype,
+		PinMemory: input.PinMemory,
+	}
+	err = recordBackward(&out, "HeavysideBackward", []*Tensor{&input, &values}, nil, func(grad *Tensor) ([]*Tensor, error) {
+		return nil, errors.New("the derivative of Heavyside is not implemented")
+	})
+	if err != nil {
+		return Tensor{}, err
+	}
+	// The step function is piecewise constant, so its tangent is zero.
+	if hasTangent(&input, &values) {
+		tangent, err := zerosLike(&input)
+		if err != nil {
+			return Tensor{}, err
+		}
+		out.tangent = tangent
+	}
+	return out, nil
 }
 
 func heavysideValidShape(input, values Tensor) bool {
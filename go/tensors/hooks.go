@@ -0,0 +1,145 @@
+package tensors
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var nextHookID atomic.Int64
+
+// HookHandle is returned when a hook is registered and removes it again.
+type HookHandle struct {
+	once   sync.Once
+	remove func()
+}
+
+// NewHookHandle returns a handle that calls remove the first time Remove is
+// called.
+func NewHookHandle(remove func()) *HookHandle {
+	return &HookHandle{remove: remove}
+}
+
+// Remove unregisters the hook. Calling it more than once has no effect.
+func (h *HookHandle) Remove() {
+	h.once.Do(h.remove)
+}
+
+// backwardPass holds the callbacks queued while a backward pass runs.
+type backwardPass struct {
+	callbacks []func()
+}
+
+// passes are the backward passes running, innermost last. A pass nests in
+// another when a backward function, such as that of Checkpoint, runs one.
+var (
+	passesMu sync.Mutex
+	passes   []*backwardPass
+)
+
+// QueueCallback schedules fn to run once the innermost backward pass that
+// is running has computed every gradient. A gradient hook can use it to act
+// on several gradients of the same pass, including the case where some of
+// them are never computed. It fails outside a backward pass.
+func QueueCallback(fn func()) error {
+	passesMu.Lock()
+	defer passesMu.Unlock()
+	if len(passes) == 0 {
+		return errors.New("callbacks can only be queued during a backward pass")
+	}
+	pass := passes[len(passes)-1]
+	pass.callbacks = append(pass.callbacks, fn)
+	return nil
+}
+
+func beginPass() *backwardPass {
+	pass := &backwardPass{}
+	passesMu.Lock()
+	passes = append(passes, pass)
+	passesMu.Unlock()
+	return pass
+}
+
+// endPass removes pass from the running passes. It may be called more than
+// once.
+func endPass(pass *backwardPass) {
+	passesMu.Lock()
+	defer passesMu.Unlock()
+	for i, p := range passes {
+		if p == pass {
+			passes = append(passes[:i:i], passes[i+1:]...)
+			return
+		}
+	}
+}
+
+type gradHook struct {
+	id int64
+	fn func(grad *Tensor) *Tensor
+}
+
+type postAccumulateGradHook struct {
+	id int64
+	fn func(t *Tensor)
+}
+
+// RegisterHook registers fn to be called with the gradient of t every time
+// it is computed during Backward. If fn returns a non-nil tensor, it
+// replaces the gradient that is propagated further and accumulated. A hook
+// registered before t is modified in place receives the gradient of the
+// values t held before the update.
+func (t *Tensor) RegisterHook(fn func(grad *Tensor) *Tensor) (*HookHandle, error) {
+	if !t.RequiresGrad {
+		return nil, errors.New("cannot register a hook on a tensor that does not require grad")
+	}
+
+	hooks := &t.hooks
+	if t.GradFn != nil {
+		hooks = &t.GradFn.hooks
+	}
+	id := nextHookID.Add(1)
+	*hooks = append(*hooks, gradHook{id: id, fn: fn})
+	return NewHookHandle(func() {
+		for i, h := range *hooks {
+			if h.id == id {
+				*hooks = append((*hooks)[:i:i], (*hooks)[i+1:]...)
+				return
+			}
+		}
+	}), nil
+}
+
+// RegisterPostAccumulateGradHook registers fn to be called on a leaf tensor
+// after Backward has accumulated a new gradient into its Grad field.
+func (t *Tensor) RegisterPostAccumulateGradHook(fn func(t *Tensor)) (*HookHandle, error) {
+	if !t.RequiresGrad {
+		return nil, errors.New("cannot register a hook on a tensor that does not require grad")
+	}
+	if !t.IsLeaf() {
+		return nil, errors.New("post accumulate grad hooks can only be registered on leaf tensors")
+	}
+
+	id := nextHookID.Add(1)
+	t.postAccumulateHooks = append(t.postAccumulateHooks, postAccumulateGradHook{id: id, fn: fn})
+	return NewHookHandle(func() {
+		for i, h := range t.postAccumulateHooks {
+			if h.id == id {
+				t.postAccumulateHooks = append(t.postAccumulateHooks[:i:i], t.postAccumulateHooks[i+1:]...)
+				return
+			}
+		}
+	}), nil
+}
+
+// runHooks passes grad through hooks in registration order.
+func runHooks(hooks []gradHook, grad *Tensor) (*Tensor, error) {
+	for _, h := range hooks {
+		if replaced := h.fn(grad); replaced != nil {
+			if !equalShapes(replaced.Shape, grad.Shape) || replaced.Dtype != grad.Dtype {
+				return nil, errors.New("hook returned a gradient with a different shape or data type")
+			}
+			grad = replaced.primal()
+		}
+	}
+	return grad, nil
+}
@@ -0,0 +1,336 @@
+package tensors
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// Version returns the number of times the data of t, or of any view
+// sharing it, has been modified in place.
+func (t *Tensor) Version() int64 {
+	if t.version == nil {
+		return 0
+	}
+	return t.version.Load()
+}
+
+// versionCounter returns the counter of t, creating it on first use so views
+// can share it.
+func (t *Tensor) versionCounter() *atomic.Int64 {
+	if t.version == nil {
+		t.version = new(atomic.Int64)
+	}
+	return t.version
+}
+
+func (t *Tensor) bumpVersion() {
+	t.versionCounter().Add(1)
+}
+
+// AddInPlace adds other to t in place. other is broadcast to the shape of t.
+func (t *Tensor) AddInPlace(other *Tensor) error {
+	prev, record, err := t.beginInPlace(other)
+	if err != nil {
+		return err
+	}
+	if err := t.inPlaceBinary(other, add[float32], add[float64]); err != nil {
+		return err
+	}
+	if hasTangent(prev, other) {
+		tp, to, err := tangentsOf(prev, other)
+		if err != nil {
+			return err
+		}
+		if t.tangent, err = Add(tp, to); err != nil {
+			return err
+		}
+	}
+	t.bumpVersion()
+	if !record {
+		return nil
+	}
+
+	otherShape := other.Shape
+	return recordBackward(t, "AddInPlaceBackward", []*Tensor{prev, other}, nil, func(grad *Tensor) ([]*Tensor, error) {
+		g, err := sumTo(grad, otherShape)
+		return []*Tensor{grad, g}, err
+	})
+}
+
+// MulInPlace multiplies t by other in place. other is broadcast to the
+// shape of t.
+func (t *Tensor) MulInPlace(other *Tensor) error {
+	prev, record, err := t.beginInPlace(other)
+	if err != nil {
+		return err
+	}
+
+	// The old values of t are only needed for the gradient of other and for
+	// tangents, so copy them only then.
+	var old *Tensor
+	if (record && other.RequiresGrad) || hasTangent(t, other) {
+		old = clone(t)
+	}
+	po := other.primal()
+	if record && po.versionCounter() == t.versionCounter() {
+		po = clone(other)
+	}
+	if err := t.inPlaceBinary(other, mul[float32], mul[float64]); err != nil {
+		return err
+	}
+	if hasTangent(prev, other) {
+		tp, to, err := tangentsOf(prev, other)
+		if err != nil {
+			return err
+		}
+		left, err := Mul(tp, po)
+		if err != nil {
+			return err
+		}
+		right, err := Mul(old, to)
+		if err != nil {
+			return err
+		}
+		if t.tangent, err = Add(left, right); err != nil {
+			return err
+		}
+	}
+	t.bumpVersion()
+	if !record {
+		return nil
+	}
+
+	return recordBackward(t, "MulInPlaceBackward", []*Tensor{prev, other}, []*Tensor{po}, func(grad *Tensor) ([]*Tensor, error) {
+		gSelf, err := Mul(grad, po)
+		if err != nil {
+			return nil, err
+		}
+		if old == nil {
+			return []*Tensor{gSelf, nil}, nil
+		}
+		gOther, err := Mul(grad, old)
+		if err != nil {
+			return nil, err
+		}
+		gOther, err = sumTo(gOther, po.Shape)
+		return []*Tensor{gSelf, gOther}, err
+	})
+}
+
+// ClampInPlace limits every element of t to [min, max] in place. Use
+// math.Inf to leave a side unbounded.
+func (t *Tensor) ClampInPlace(min, max float64) error {
+	if min > max {
+		return errors.New("min must not be greater than max")
+	}
+	prev, record, err := t.beginInPlace()
+	if err != nil {
+		return err
+	}
+
+	// mask marks the elements that pass through unchanged.
+	var mask *Tensor
+	if record || t.tangent != nil {
+		if mask, err = zerosLike(t); err != nil {
+			return err
+		}
+	}
+	switch data := t.Data.(type) {
+	case []float32:
+		clampKernel(data, float32(min), float32(max), mask)
+	case []float64:
+		clampKernel(data, min, max, mask)
+	default:
+		return errors.New("unsupported data type")
+	}
+	if t.tangent != nil {
+		tangent, err := Mul(t.tangent, mask)
+		if err != nil {
+			return err
+		}
+		t.tangent = tangent
+	}
+	t.bumpVersion()
+	if !record {
+		return nil
+	}
+
+	return recordBackward(t, "ClampInPlaceBackward", []*Tensor{prev}, nil, func(grad *Tensor) ([]*Tensor, error) {
+		g, err := Mul(grad, mask)
+		return []*Tensor{g}, err
+	})
+}
+
+// Fill sets every element of t to value in place.
+func (t *Tensor) Fill(value float64) error {
+	prev, record, err := t.beginInPlace()
+	if err != nil {
+		return err
+	}
+	switch data := t.Data.(type) {
+	case []float32:
+		for i := range data {
+			data[i] = float32(value)
+		}
+	case []float64:
+		for i := range data {
+			data[i] = value
+		}
+	case []int64:
+		for i := range data {
+			data[i] = int64(value)
+		}
+	default:
+		return errors.New("unsupported data type")
+	}
+	t.tangent = nil
+	t.bumpVersion()
+	if !record {
+		return nil
+	}
+
+	return recordBackward(t, "FillBackward", []*Tensor{prev}, nil, func(grad *Tensor) ([]*Tensor, error) {
+		return []*Tensor{nil}, nil
+	})
+}
+
+// Zero sets every element of t to zero in place.
+func (t *Tensor) Zero() error {
+	return t.Fill(0)
+}
+
+// CopyFrom copies the values of src into t in place. src is broadcast to the
+// shape of t.
+func (t *Tensor) CopyFrom(src *Tensor) error {
+	prev, record, err := t.beginInPlace(src)
+	if err != nil {
+		return err
+	}
+	if dst, ok := t.Data.([]int64); ok {
+		// Integer tensors never take part in autograd.
+		values, ok := src.Data.([]int64)
+		if !ok {
+			return errors.New("tensors must have the same data type")
+		}
+		if shape, _ := broadcastShapes(t.Shape, src.Shape); !equalShapes(shape, t.Shape) {
+			return errors.New("in-place operand cannot change the shape of the tensor")
+		}
+		inPlaceKernel(dst, values, src.Shape, t.Shape, second[int64])
+		t.bumpVersion()
+		return nil
+	}
+	if err := t.inPlaceBinary(src, second[float32], second[float64]); err != nil {
+		return err
+	}
+	t.tangent = nil
+	if src.tangent != nil {
+		if t.tangent, err = BroadcastTo(src.tangent, t.Shape); err != nil {
+			return err
+		}
+	}
+	t.bumpVersion()
+	if !record {
+		return nil
+	}
+
+	srcShape := src.Shape
+	return recordBackward(t, "CopyFromBackward", []*Tensor{prev, src}, nil, func(grad *Tensor) ([]*Tensor, error) {
+		g, err := sumTo(grad, srcShape)
+		return []*Tensor{nil, g}, err
+	})
+}
+
+func second[T float32 | float64 | int64](_, y T) T { return y }
+
+// beginInPlace checks that t may be modified in place and, when the update
+// has to be recorded for backward, returns a tensor that stands in for t as
+// it was before the update.
+func (t *Tensor) beginInPlace(others ...*Tensor) (*Tensor, bool, error) {
+	for _, o := range others {
+		if o.Dtype != t.Dtype {
+			return nil, false, errors.New("tensors must have the same data type")
+		}
+		if _, err := broadcastShapes(o.Shape, t.Shape); err != nil {
+			return nil, false, err
+		}
+	}
+	if t.inference && !IsInferenceMode() {
+		return nil, false, errors.New("inference tensors cannot be modified in place outside InferenceMode")
+	}
+
+	record := false
+	if IsGradEnabled() {
+		if t.RequiresGrad && t.IsLeaf() {
+			return nil, false, errors.New("a leaf tensor that requires grad cannot be modified in place outside NoGrad")
+		}
+		record = t.RequiresGrad
+		for _, o := range others {
+			record = record || o.RequiresGrad
+		}
+	}
+
+	prev := &Tensor{
+		Shape:        t.Shape,
+		Data:         t.Data,
+		Dtype:        t.Dtype,
+		Device:       t.Device,
+		RequiresGrad: t.RequiresGrad,
+		PinMemory:    t.PinMemory,
+		GradFn:       t.GradFn,
+		tangent:      t.tangent,
+		version:      t.versionCounter(),
+	}
+	return prev, record, nil
+}
+
+// inPlaceBinary overwrites t with f(t, other), broadcasting other.
+func (t *Tensor) inPlaceBinary(other *Tensor, f32 func(x, y float32) float32, f64 func(x, y float64) float64) error {
+	outShape, err := broadcastShapes(t.Shape, other.Shape)
+	if err != nil {
+		return err
+	}
+	if !equalShapes(outShape, t.Shape) {
+		return errors.New("in-place operand cannot change the shape of the tensor")
+	}
+
+	switch dst := t.Data.(type) {
+	case []float32:
+		inPlaceKernel(dst, other.Data.([]float32), other.Shape, t.Shape, f32)
+	case []float64:
+		inPlaceKernel(dst, other.Data.([]float64), other.Shape, t.Shape, f64)
+	default:
+		return errors.New("unsupported data type")
+	}
+	return nil
+}
+
+func inPlaceKernel[T float32 | float64 | int64](dst, src []T, srcShape, shape []int, f func(a, b T) T) {
+	if equalShapes(srcShape, shape) {
+		for i := range dst {
+			dst[i] = f(dst[i], src[i])
+		}
+		return
+	}
+	for i, j := range broadcastIndices(srcShape, shape) {
+		dst[i] = f(dst[i], src[j])
+	}
+}
+
+// clampKernel clamps data in place. When mask is non-nil it is set to 1
+// where an element was inside the bounds and 0 where it was clamped.
+func clampKernel[T float32 | float64](data []T, min, max T, mask *Tensor) {
+	var keep []T
+	if mask != nil {
+		keep = mask.Data.([]T)
+	}
+	for i, v := range data {
+		switch {
+		case v < min:
+			data[i] = min
+		case v > max:
+			data[i] = max
+		case keep != nil:
+			keep[i] = 1
+		}
+	}
+}
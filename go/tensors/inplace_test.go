@@ -0,0 +1,73 @@
+package tensors
+
+import "testing"
+
+// An in-place update gives the tensor a new backward node. Ops that read the
+// tensor before the update must keep propagating through the old node.
+func TestInPlaceKeepsEarlierConsumersOnOldNode(t *testing.T) {
+	leaf := func(values ...float64) *Tensor {
+		x, err := NewTensor(values, []int{len(values)}, "float64", true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return x
+	}
+	a, b, c, z := leaf(1, 2), leaf(3, 4), leaf(5, 6), leaf(7, 8)
+
+	x, err := Mul(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := Add(x, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.AddInPlace(z); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Add(y, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ones, err := NewOnes([]int{2}, Float64{}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Backward(ones); err != nil {
+		t.Fatal(err)
+	}
+
+	if z.Grad != nil {
+		t.Errorf("z.Grad = %v, want nil", z.Grad.Data)
+	}
+	for _, tc := range []struct {
+		name string
+		grad *Tensor
+		want []float64
+	}{
+		{"a", a.Grad, []float64{6, 8}},
+		{"b", b.Grad, []float64{2, 4}},
+		{"c", c.Grad, []float64{2, 2}},
+	} {
+		if tc.grad == nil {
+			t.Errorf("%s.Grad = nil, want %v", tc.name, tc.want)
+			continue
+		}
+		got := tc.grad.Data.([]float64)
+		for i := range tc.want {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s.Grad = %v, want %v", tc.name, got, tc.want)
+				break
+			}
+		}
+	}
+
+	// The updated tensor itself propagates through the new node.
+	a.Grad, b.Grad = nil, nil
+	if err := x.Backward(ones); err != nil {
+		t.Fatal(err)
+	}
+	if z.Grad == nil || a.Grad == nil {
+		t.Fatal("backward through the updated tensor did not reach a and z")
+	}
+}
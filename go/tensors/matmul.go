@@ -0,0 +1,167 @@
+package tensors
+
+import (
+	"errors"
+	"fmt"
+
+	"gotorch/internal/parallel"
+)
+
+// MatMul returns the matrix product of two tensors. 1-d operands are
+// treated as a row vector on the left and a column vector on the right,
+// and any leading batch dimensions are broadcast against each other.
+func MatMul(a, b *Tensor) (*Tensor, error) {
+	if len(a.Shape) == 0 || len(b.Shape) == 0 {
+		return nil, errors.New("MatMul requires tensors with at least 1 dimension")
+	}
+	if a.Dtype != b.Dtype {
+		return nil, errors.New("tensors must have the same data type")
+	}
+
+	aShape, bShape := a.Shape, b.Shape
+	if len(aShape) == 1 {
+		aShape = []int{1, aShape[0]}
+	}
+	if len(bShape) == 1 {
+		bShape = []int{bShape[0], 1}
+	}
+
+	m, k := aShape[len(aShape)-2], aShape[len(aShape)-1]
+	if bShape[len(bShape)-2] != k {
+		return nil, fmt.Errorf("shapes %v and %v are not aligned for MatMul", a.Shape, b.Shape)
+	}
+	n := bShape[len(bShape)-1]
+
+	batchShape, err := broadcastShapes(aShape[:len(aShape)-2], bShape[:len(bShape)-2])
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+	switch x := a.Data.(type) {
+	case []float32:
+		data = batchedMatMul(x, b.Data.([]float32), aShape, bShape, batchShape, m, k, n)
+	case []float64:
+		data = batchedMatMul(x, b.Data.([]float64), aShape, bShape, batchShape, m, k, n)
+	default:
+		return nil, errors.New("unsupported data type for MatMul")
+	}
+
+	outShape := append(append([]int{}, batchShape...), m, n)
+	if len(a.Shape) == 1 {
+		outShape = append(outShape[:len(outShape)-2], outShape[len(outShape)-1])
+	}
+	if len(b.Shape) == 1 {
+		outShape = outShape[:len(outShape)-1]
+	}
+
+	out := &Tensor{
+		Shape:     outShape,
+		Data:      data,
+		Dtype:     a.Dtype,
+		PinMemory: a.PinMemory,
+	}
+	pa, pb := a.primal(), b.primal()
+	gradShape := append(append([]int{}, batchShape...), m, n)
+	err = recordBackward(out, "MatMulBackward", []*Tensor{a, b}, []*Tensor{pa, pb}, func(grad *Tensor) ([]*Tensor, error) {
+		return matMulBackward(grad, pa, pb, aShape, bShape, gradShape)
+	})
+	if err != nil || !hasTangent(a, b) {
+		return out, err
+	}
+
+	// d(AB) = dA*B + A*dB
+	ta, tb, err := tangentsOf(a, b)
+	if err != nil {
+		return nil, err
+	}
+	left, err := MatMul(ta, b.primal())
+	if err != nil {
+		return nil, err
+	}
+	right, err := MatMul(a.primal(), tb)
+	if err != nil {
+		return nil, err
+	}
+	if out.tangent, err = Add(left, right); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// matMulBackward computes dA = G*B^T and dB = A^T*G on the operands promoted
+// to matrices, then reduces over broadcast batch dimensions.
+func matMulBackward(grad, a, b *Tensor, aShape, bShape, gradShape []int) ([]*Tensor, error) {
+	g, err := Reshape(grad, gradShape)
+	if err != nil {
+		return nil, err
+	}
+	am, err := Reshape(a, aShape)
+	if err != nil {
+		return nil, err
+	}
+	bm, err := Reshape(b, bShape)
+	if err != nil {
+		return nil, err
+	}
+
+	bt, err := Adjoint(bm)
+	if err != nil {
+		return nil, err
+	}
+	ga, err := MatMul(g, bt)
+	if err != nil {
+		return nil, err
+	}
+	if ga, err = sumTo(ga, aShape); err != nil {
+		return nil, err
+	}
+	if ga, err = Reshape(ga, a.Shape); err != nil {
+		return nil, err
+	}
+
+	at, err := Adjoint(am)
+	if err != nil {
+		return nil, err
+	}
+	gb, err := MatMul(at, g)
+	if err != nil {
+		return nil, err
+	}
+	if gb, err = sumTo(gb, bShape); err != nil {
+		return nil, err
+	}
+	if gb, err = Reshape(gb, b.Shape); err != nil {
+		return nil, err
+	}
+	return []*Tensor{ga, gb}, nil
+}
+
+func batchedMatMul[T float32 | float64](a, b []T, aShape, bShape, batchShape []int, m, k, n int) []T {
+	batches := shapeSize(batchShape)
+	result := make([]T, batches*m*n)
+
+	aBatches := broadcastIndices(aShape[:len(aShape)-2], batchShape)
+	bBatches := broadcastIndices(bShape[:len(bShape)-2], batchShape)
+	for i := 0; i < batches; i++ {
+		gemm(a[aBatches[i]*m*k:], b[bBatches[i]*k*n:], result[i*m*n:], m, k, n)
+	}
+	return result
+}
+
+// gemm accumulates the product of the m x k matrix a and the k x n
+// matrix b into the m x n matrix c. Rows of c are computed in parallel.
+func gemm[T float32 | float64](a, b, c []T, m, k, n int) {
+	parallel.For(m, k*n, func(start, end int) {
+		for i := start; i < end; i++ {
+			row := c[i*n : (i+1)*n]
+			for p := 0; p < k; p++ {
+				av := a[i*k+p]
+				col := b[p*n : (p+1)*n]
+				for j := range row {
+					row[j] += av * col[j]
+				}
+			}
+		}
+	})
+}
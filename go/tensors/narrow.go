@@ -0,0 +1,97 @@
+package tensors
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Narrow returns the slice [start, start+length) of a tensor along dim.
+func Narrow(t *Tensor, dim, start, length int) (*Tensor, error) {
+	if dim < 0 {
+		dim += len(t.Shape)
+	}
+	if dim < 0 || dim >= len(t.Shape) {
+		return nil, errors.New("dimension out of range")
+	}
+	if start < 0 || length < 0 || start+length > t.Shape[dim] {
+		return nil, fmt.Errorf("narrow [%d, %d) is out of range for dimension of size %d", start, start+length, t.Shape[dim])
+	}
+
+	newShape := make([]int, len(t.Shape))
+	copy(newShape, t.Shape)
+	newShape[dim] = length
+
+	var data interface{}
+	switch src := t.Data.(type) {
+	case []float32:
+		data = narrowKernel(src, t.Shape, dim, start, length)
+	case []float64:
+		data = narrowKernel(src, t.Shape, dim, start, length)
+	default:
+		return nil, errors.New("unsupported data type for Narrow")
+	}
+
+	out := &Tensor{
+		Shape:     newShape,
+		Data:      data,
+		Dtype:     t.Dtype,
+		PinMemory: t.PinMemory,
+	}
+	inShape := t.Shape
+	err := recordBackward(out, "NarrowBackward", []*Tensor{t}, nil, func(grad *Tensor) ([]*Tensor, error) {
+		g, err := unnarrow(grad, inShape, dim, start)
+		return []*Tensor{g}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if t.tangent != nil {
+		if out.tangent, err = Narrow(t.tangent, dim, start, length); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// unnarrow places t into a zero tensor of shape at offset start along dim,
+// undoing Narrow.
+func unnarrow(t *Tensor, shape []int, dim, start int) (*Tensor, error) {
+	var data interface{}
+	switch src := t.Data.(type) {
+	case []float32:
+		data = unnarrowKernel(src, shape, dim, start, t.Shape[dim])
+	case []float64:
+		data = unnarrowKernel(src, shape, dim, start, t.Shape[dim])
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+
+	return &Tensor{
+		Shape:     append([]int{}, shape...),
+		Data:      data,
+		Dtype:     t.Dtype,
+		PinMemory: t.PinMemory,
+	}, nil
+}
+
+func narrowKernel[T float32 | float64](data []T, shape []int, dim, start, length int) []T {
+	outer := shapeSize(shape[:dim])
+	inner := shapeSize(shape[dim+1:])
+	result := make([]T, 0, outer*length*inner)
+	for o := 0; o < outer; o++ {
+		base := (o*shape[dim] + start) * inner
+		result = append(result, data[base:base+length*inner]...)
+	}
+	return result
+}
+
+func unnarrowKernel[T float32 | float64](data []T, shape []int, dim, start, length int) []T {
+	outer := shapeSize(shape[:dim])
+	inner := shapeSize(shape[dim+1:])
+	result := make([]T, shapeSize(shape))
+	for o := 0; o < outer; o++ {
+		base := (o*shape[dim] + start) * inner
+		copy(result[base:base+length*inner], data[o*length*inner:(o+1)*length*inner])
+	}
+	return result
+}
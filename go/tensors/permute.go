@@ -0,0 +1,102 @@
+package tensors
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Permute reorders the dimensions of a tensor so that dimension i of the
+// result is dimension dims[i] of the input.
+func Permute(t *Tensor, dims []int) (*Tensor, error) {
+	if len(dims) != len(t.Shape) {
+		return nil, fmt.Errorf("permutation %v does not match %d dimensions", dims, len(t.Shape))
+	}
+	seen := make([]bool, len(dims))
+	perm := make([]int, len(dims))
+	for i, d := range dims {
+		if d < 0 {
+			d += len(dims)
+		}
+		if d < 0 || d >= len(dims) || seen[d] {
+			return nil, fmt.Errorf("invalid permutation %v", dims)
+		}
+		seen[d] = true
+		perm[i] = d
+	}
+
+	newShape := make([]int, len(perm))
+	for i, d := range perm {
+		newShape[i] = t.Shape[d]
+	}
+
+	var data interface{}
+	switch src := t.Data.(type) {
+	case []float32:
+		data = permuteKernel(src, t.Shape, perm)
+	case []float64:
+		data = permuteKernel(src, t.Shape, perm)
+	default:
+		return nil, errors.New("unsupported data type for Permute")
+	}
+
+	out := &Tensor{
+		Shape:     newShape,
+		Data:      data,
+		Dtype:     t.Dtype,
+		PinMemory: t.PinMemory,
+	}
+	inverse := make([]int, len(perm))
+	for i, d := range perm {
+		inverse[d] = i
+	}
+	err := recordBackward(out, "PermuteBackward", []*Tensor{t}, nil, func(grad *Tensor) ([]*Tensor, error) {
+		g, err := Permute(grad, inverse)
+		return []*Tensor{g}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if t.tangent != nil {
+		if out.tangent, err = Permute(t.tangent, perm); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func permuteKernel[T float32 | float64](data []T, shape, perm []int) []T {
+	result := make([]T, len(data))
+	if len(data) == 0 {
+		return result
+	}
+
+	strides := make([]int, len(shape))
+	stride := 1
+	for i := len(shape) - 1; i >= 0; i-- {
+		strides[i] = stride
+		stride *= shape[i]
+	}
+
+	// Walk the output in order while tracking the matching input offset.
+	outShape := make([]int, len(perm))
+	outStrides := make([]int, len(perm))
+	for i, d := range perm {
+		outShape[i] = shape[d]
+		outStrides[i] = strides[d]
+	}
+	counter := make([]int, len(perm))
+	offset := 0
+	for i := range result {
+		result[i] = data[offset]
+		for d := len(outShape) - 1; d >= 0; d-- {
+			counter[d]++
+			offset += outStrides[d]
+			if counter[d] < outShape[d] {
+				break
+			}
+			offset -= outStrides[d] * outShape[d]
+			counter[d] = 0
+		}
+	}
+	return result
+}
@@ -0,0 +1,207 @@
+package tensors
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultSeed matches the seed PyTorch gives its default CPU generator.
+const defaultSeed = 67280421310721
+
+// Generator is a seedable source of random numbers for tensor ops. Its
+// state can be saved and restored so a sequence of draws can be replayed.
+type Generator struct {
+	mu   sync.Mutex
+	seed uint64
+	src  *rand.PCG
+	rng  *rand.Rand
+}
+
+// DefaultGenerator is used by ops that are not given an explicit generator.
+var DefaultGenerator = NewGenerator(defaultSeed)
+
+// NewGenerator returns a generator seeded with seed.
+func NewGenerator(seed uint64) *Generator {
+	src := rand.NewPCG(seed, seed)
+	return &Generator{seed: seed, src: src, rng: rand.New(src)}
+}
+
+// ManualSeed seeds the default generator.
+func ManualSeed(seed uint64) {
+	DefaultGenerator.ManualSeed(seed)
+}
+
+// ManualSeed resets g to the start of the sequence for seed.
+func (g *Generator) ManualSeed(seed uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.seed = seed
+	g.src.Seed(seed, seed)
+}
+
+// InitialSeed returns the seed g was last seeded with.
+func (g *Generator) InitialSeed() uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.seed
+}
+
+// State returns an opaque snapshot of the generator state.
+func (g *Generator) State() []byte {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	state, _ := g.src.MarshalBinary()
+	return state
+}
+
+// SetState restores a snapshot taken with State.
+func (g *Generator) SetState(state []byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.src.UnmarshalBinary(state); err != nil {
+		return errors.New("invalid generator state")
+	}
+	return nil
+}
+
+// Float64 returns a uniform sample from [0, 1).
+func (g *Generator) Float64() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.noteDraw()
+	return g.rng.Float64()
+}
+
+// NormFloat64 returns a sample from the standard normal distribution.
+func (g *Generator) NormFloat64() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.noteDraw()
+	return g.rng.NormFloat64()
+}
+
+// Fill calls sample once per element of dst, in order, while holding the
+// generator, so the draws do not interleave with other users of g.
+func (g *Generator) Fill(dst []float64, sample func(r *rand.Rand) float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.noteDraw()
+	for i := range dst {
+		dst[i] = sample(g.rng)
+	}
+}
+
+// rngScope records the state every generator had before its first draw
+// while the scope was active, so the draws can be replayed. Checkpoint uses
+// it to give a recomputed function the random values of the first run.
+type rngScope struct {
+	states map[*Generator][]byte
+}
+
+var (
+	rngScopesMu  sync.Mutex
+	rngScopes    []*rngScope
+	activeScopes atomic.Int32 // len(rngScopes), read without the lock on every draw
+)
+
+// beginRNGScope starts recording generator draws. end stops it.
+func beginRNGScope() (scope *rngScope, end func()) {
+	scope = &rngScope{states: make(map[*Generator][]byte)}
+	rngScopesMu.Lock()
+	rngScopes = append(rngScopes, scope)
+	activeScopes.Add(1)
+	rngScopesMu.Unlock()
+	return scope, func() {
+		rngScopesMu.Lock()
+		defer rngScopesMu.Unlock()
+		for i, s := range rngScopes {
+			if s == scope {
+				rngScopes = append(rngScopes[:i:i], rngScopes[i+1:]...)
+				activeScopes.Add(-1)
+				break
+			}
+		}
+	}
+}
+
+// noteDraw records the state of g in every active scope that has not seen
+// it yet. g.mu must be held.
+func (g *Generator) noteDraw() {
+	if activeScopes.Load() == 0 {
+		return
+	}
+	rngScopesMu.Lock()
+	defer rngScopesMu.Unlock()
+	for _, s := range rngScopes {
+		if _, ok := s.states[g]; !ok {
+			s.states[g], _ = g.src.MarshalBinary()
+		}
+	}
+}
+
+// replay rewinds every recorded generator to its state at the start of the
+// scope, runs fn and then restores the generators to where they were.
+func (s *rngScope) replay(fn func()) error {
+	current := make(map[*Generator][]byte, len(s.states))
+	for g, state := range s.states {
+		current[g] = g.State()
+		if err := g.SetState(state); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		for g, state := range current {
+			g.SetState(state)
+		}
+	}()
+	fn()
+	return nil
+}
+
+func generatorOrDefault(g *Generator) *Generator {
+	if g == nil {
+		return DefaultGenerator
+	}
+	return g
+}
+
+// NewRand returns a tensor filled with samples from the uniform
+// distribution on [0, 1). A nil generator uses DefaultGenerator.
+func NewRand(shape []int, dtype Dtype, generator *Generator, requiresGrad, pinMemory bool) (*Tensor, error) {
+	return newRandom(shape, dtype, generator, requiresGrad, pinMemory, (*rand.Rand).Float64)
+}
+
+// NewRandn returns a tensor filled with samples from the standard normal
+// distribution. A nil generator uses DefaultGenerator.
+func NewRandn(shape []int, dtype Dtype, generator *Generator, requiresGrad, pinMemory bool) (*Tensor, error) {
+	return newRandom(shape, dtype, generator, requiresGrad, pinMemory, (*rand.Rand).NormFloat64)
+}
+
+func newRandom(shape []int, dtype Dtype, generator *Generator, requiresGrad, pinMemory bool, sample func(r *rand.Rand) float64) (*Tensor, error) {
+	values := make([]float64, shapeSize(shape))
+	generatorOrDefault(generator).Fill(values, sample)
+
+	var data interface{}
+	switch dtype.DataType() {
+	case "float32":
+		matrix := make([]float32, len(values))
+		for i, v := range values {
+			matrix[i] = float32(v)
+		}
+		data = matrix
+	case "float64":
+		data = values
+	default:
+		return nil, errors.New("unsupported data type")
+	}
+
+	return &Tensor{
+		Shape:        shape,
+		Data:         data,
+		Dtype:        dtype,
+		RequiresGrad: requiresGrad,
+		PinMemory:    pinMemory,
+	}, nil
+}
@@ -40,11 +40,27 @@ func Reshape(t *Tensor, newShape []int) (*Tensor, error) {
 		return nil, errors.New("total number of elements must remain constant")
 	}
 
-	return &Tensor{
-		Shape:        newShape,
-		Data:         t.Data,
-		Dtype:        t.Dtype,
-		RequiresGrad: t.RequiresGrad,
-		PinMemory:    t.PinMemory,
-	}, nil
+	out := &Tensor{
+		Shape:     newShape,
+		Data:      t.Data,
+		Dtype:     t.Dtype,
+		PinMemory: t.PinMemory,
+		version:   t.versionCounter(),
+	}
+	inShape := t.Shape
+	err := recordBackward(out, "ReshapeBackward", []*Tensor{t}, nil, func(grad *Tensor) ([]*Tensor, error) {
+		g, err := Reshape(grad, inShape)
+		return []*Tensor{g}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if t.tangent != nil {
+		tangent, err := Reshape(t.tangent, out.Shape)
+		if err != nil {
+			return nil, err
+		}
+		out.tangent = tangent
+	}
+	return out, nil
 }
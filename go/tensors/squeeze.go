@@ -8,11 +8,27 @@ func Squeeze(t *Tensor) (*Tensor, error) {
 		}
 	}
 
-	return &Tensor{
-		Shape:        newShape,
-		Data:         t.Data,
-		Dtype:        t.Dtype,
-		RequiresGrad: t.RequiresGrad,
-		PinMemory:    t.PinMemory,
-	}, nil
+	out := &Tensor{
+		Shape:     newShape,
+		Data:      t.Data,
+		Dtype:     t.Dtype,
+		PinMemory: t.PinMemory,
+		version:   t.versionCounter(),
+	}
+	inShape := t.Shape
+	err := recordBackward(out, "SqueezeBackward", []*Tensor{t}, nil, func(grad *Tensor) ([]*Tensor, error) {
+		g, err := Reshape(grad, inShape)
+		return []*Tensor{g}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if t.tangent != nil {
+		tangent, err := Squeeze(t.tangent)
+		if err != nil {
+			return nil, err
+		}
+		out.tangent = tangent
+	}
+	return out, nil
 }
@@ -20,84 +20,28 @@ func Stack(tensors []*Tensor, dim int) (*Tensor, error) {
 		}
 	}
 
-	newShape := make([]int, len(baseShape)+1)
-	copy(newShape, baseShape)
 	if dim < 0 {
-		dim += len(newShape)
+		dim += len(baseShape) + 1
 	}
-	if dim < 0 || dim > len(newShape) {
+	if dim < 0 || dim > len(baseShape) {
 		return nil, errors.New("dimension out of range")
 	}
-	newShape = append(newShape[:dim], append([]int{len(tensors)}, newShape[dim:]...)...)
 
-	stackedData := stackData(tensors, baseDtype, dim)
-
-	return &Tensor{
-		Shape:        newShape,
-		Data:         stackedData,
-		Dtype:        baseDtype,
-		RequiresGrad: tensors[0].RequiresGrad,
-		PinMemory:    tensors[0].PinMemory,
-	}, nil
-}
-
-func stackData(tensors []*Tensor, dtype Dtype, dim int) interface{} {
-	switch dtype {
-	case Float32{}:
-		return stackRecursive(tensors, dim, Float32{}).([]interface{})
-	case Float64{}:
-		return stackRecursive(tensors, dim, Float64{}).([]interface{})
-	default:
-		return nil
-	}
-}
-
-func stackRecursive(tensors []*Tensor, dim int, dtype Dtype) interface{} {
-	if len(tensors) == 1 {
-		return tensors[0].Data
-	}
-
-	for _, t := range tensors {
-		if len(t.Shape) != len(tensors[0].Shape) {
-			panic("all tensors must have the same number of dimensions")
-		}
-		for i := range t.Shape {
-			if i != dim && t.Shape[i] != tensors[0].Shape[i] {
-				panic("all tensors must have the same shape except in the stacking dimension")
-			}
-		}
-	}
-
-	result := make([]interface{}, len(tensors))
+	// Insert a dimension of size 1 into every input and concatenate along it.
+	expanded := make([]*Tensor, len(tensors))
 	for i, t := range tensors {
-		data := t.Data
-		if len(t.Shape) > 1 && dim > 0 {
-			subTensors := extractSubTensors(t, dim)
-			result[i] = stackRecursive(subTensors, dim-1, dtype)
-		} else {
-			result[i] = data
+		shape := make([]int, 0, len(baseShape)+1)
+		shape = append(shape, baseShape[:dim]...)
+		shape = append(shape, 1)
+		shape = append(shape, baseShape[dim:]...)
+		reshaped, err := Reshape(t, shape)
+		if err != nil {
+			return nil, err
 		}
+		expanded[i] = reshaped
 	}
 
-	return result
-}
-func extractSubTensors(t *Tensor, dim int) []*Tensor {
-	subTensors := []*Tensor{}
-	shape := t.Shape
-	size := shape[dim]
-	data := t.Data.([]interface{})
-
-	for i := 0; i < size; i++ {
-		subTensors = append(subTensors, &Tensor{
-			Shape:        append(shape[:dim], shape[dim+1:]...),
-			Data:         data[i],
-			Dtype:        t.Dtype,
-			RequiresGrad: t.RequiresGrad,
-			PinMemory:    t.PinMemory,
-		})
-	}
-
-	return subTensors
+	return Cat(expanded, dim)
 }
 
 func equalShapes(shape1, shape2 []int) bool {
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sync/atomic"
 )
 
 type Dtype interface {
@@ -22,6 +23,14 @@ func (i Float64) DataType() string {
 	return "float64"
 }
 
+// Int64 holds integer data such as indices. Int64 tensors cannot require
+// grad.
+type Int64 struct{}
+
+func (i Int64) DataType() string {
+	return "int64"
+}
+
 type Device interface {
 	Device() string
 }
@@ -34,11 +43,19 @@ func (c CPU) Device() string {
 
 type Tensor struct {
 	Shape        []int
-	Data         interface{} // Data can hold any type depending on the Dtype ([]float32, []float64)
+	Data         interface{} // Data can hold any type depending on the Dtype ([]float32, []float64, []int64)
 	Dtype        Dtype
 	Device       Device
 	RequiresGrad bool
 	PinMemory    bool
+	Grad         *Tensor // accumulated gradient of a leaf tensor after Backward
+	GradFn       *Node   // backward node of the op that produced the tensor, nil for leaves
+
+	tangent             *Tensor // forward-mode tangent, set through MakeDual
+	inference           bool    // created under InferenceMode
+	hooks               []gradHook
+	postAccumulateHooks []postAccumulateGradHook
+	version             *atomic.Int64 // shared by every view of the same data
 }
 
 func NewTensor(data interface{}, shape []int, dtype string, requiresGrad, pinMemory bool) (*Tensor, error) {
@@ -82,6 +99,23 @@ func NewTensor(data interface{}, shape []int, dtype string, requiresGrad, pinMem
 			RequiresGrad: requiresGrad,
 			PinMemory:    pinMemory,
 		}, nil
+	case "int64":
+		dataInt64, ok := data.([]int64)
+		if !ok {
+			return nil, errors.New("data is not of type int64")
+		}
+		if len(dataInt64) != expectedSize {
+			return nil, errors.New("data length does not match shape")
+		}
+		if requiresGrad {
+			return nil, errors.New("only floating point tensors can require grad")
+		}
+		return &Tensor{
+			Shape:     shape,
+			Data:      dataInt64,
+			Dtype:     Int64{},
+			PinMemory: pinMemory,
+		}, nil
 	default:
 		return nil, errors.New("unsupported data type")
 	}
@@ -103,6 +137,7 @@ func (t *Tensor) SetData(newData interface{}) error {
 			return errors.New("new data length does not match shape")
 		}
 		t.Data = newData
+		t.bumpVersion()
 	case "float64":
 		dataFloat64, ok := newData.([]float64)
 		if !ok {
@@ -112,6 +147,17 @@ func (t *Tensor) SetData(newData interface{}) error {
 			return errors.New("new data length does not match shape")
 		}
 		t.Data = newData
+		t.bumpVersion()
+	case "int64":
+		dataInt64, ok := newData.([]int64)
+		if !ok {
+			return errors.New("new data is not of type int64")
+		}
+		if len(dataInt64) != expectedSize {
+			return errors.New("new data length does not match shape")
+		}
+		t.Data = newData
+		t.bumpVersion()
 	default:
 		return errors.New("unsupported data type")
 	}
@@ -124,6 +170,8 @@ func (t *Tensor) GetData() (interface{}, error) {
 		return t.Data.([]float32), nil
 	case "float64":
 		return t.Data.([]float64), nil
+	case "int64":
+		return t.Data.([]int64), nil
 	default:
 		return nil, errors.New("unsupported data type")
 	}
@@ -152,6 +200,12 @@ func (t *Tensor) IsNonZero() (bool, error) {
 				return true, nil
 			}
 		}
+	case []int64:
+		for _, v := range data {
+			if v != 0 {
+				return true, nil
+			}
+		}
 	default:
 		return false, fmt.Errorf("unsupported data type")
 	}
@@ -169,6 +223,8 @@ func (t *Tensor) Numel() (int, error) {
 		return len(data), nil
 	case []float64:
 		return len(data), nil
+	case []int64:
+		return len(data), nil
 	default:
 		return 0, fmt.Errorf("unsupported data type")
 	}
@@ -189,6 +245,8 @@ func NewZeroes(shape []int, dtype Dtype, requiresGrad, pinMemory bool) (*Tensor,
 		data = make([]float32, size)
 	case "float64":
 		data = make([]float64, size)
+	case "int64":
+		data = make([]int64, size)
 	default:
 		return nil, errors.New("unsupported data type")
 	}